@@ -0,0 +1,2 @@
+ALTER TABLE orders
+    ADD COLUMN dispatch_mode VARCHAR(16) NOT NULL DEFAULT 'MANUAL' AFTER payment_status;
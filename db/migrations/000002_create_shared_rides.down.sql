@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS order_fare_splits;
+DROP TABLE IF EXISTS shared_ride_groups;
+
+ALTER TABLE orders
+    DROP INDEX idx_orders_shared_group_id,
+    DROP COLUMN shared_group_id,
+    DROP COLUMN ride_type;
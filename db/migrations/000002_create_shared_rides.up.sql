@@ -0,0 +1,32 @@
+ALTER TABLE orders
+    ADD COLUMN ride_type VARCHAR(16) NOT NULL DEFAULT 'PRIVATE' AFTER dispatch_mode,
+    ADD COLUMN shared_group_id VARCHAR(64) NULL AFTER ride_type,
+    ADD INDEX idx_orders_shared_group_id (shared_group_id);
+
+CREATE TABLE shared_ride_groups (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    group_id VARCHAR(64) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    seats_total INT NOT NULL,
+    seats_taken INT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'OPEN',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_shared_ride_groups_group_id (group_id),
+    KEY idx_shared_ride_groups_status (status)
+);
+
+CREATE TABLE order_fare_splits (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    order_id VARCHAR(64) NOT NULL,
+    group_id VARCHAR(64) NULL,
+    base_fare DECIMAL(15,2) NOT NULL,
+    discount_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    fare DECIMAL(15,2) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_order_fare_splits_order_id (order_id),
+    KEY idx_order_fare_splits_group_id (group_id)
+);
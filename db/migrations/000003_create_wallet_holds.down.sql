@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS wallet_holds;
+
+ALTER TABLE orders
+    DROP COLUMN final_fare;
+
+-- the original column only knew credit and debit, later entry types are folded
+-- into the direction they move the balance
+UPDATE wallet_transactions
+SET type = CASE WHEN type IN ('hold', 'capture', 'commission') THEN 'debit' ELSE 'credit' END
+WHERE type NOT IN ('credit', 'debit');
+
+ALTER TABLE wallet_transactions
+    MODIFY COLUMN type ENUM('credit', 'debit') NOT NULL,
+    DROP INDEX idx_wallet_transactions_order_id,
+    DROP INDEX uq_wallet_transactions_transaction_id,
+    DROP COLUMN order_id;
+
+ALTER TABLE wallets
+    DROP COLUMN held_balance;
@@ -0,0 +1,26 @@
+ALTER TABLE wallets
+    ADD COLUMN held_balance DECIMAL(15,2) NOT NULL DEFAULT 0 AFTER balance;
+
+ALTER TABLE wallet_transactions
+    MODIFY COLUMN type VARCHAR(16) NOT NULL,
+    ADD COLUMN order_id VARCHAR(64) NULL AFTER transaction_id,
+    ADD UNIQUE KEY uq_wallet_transactions_transaction_id (transaction_id),
+    ADD INDEX idx_wallet_transactions_order_id (order_id);
+
+ALTER TABLE orders
+    ADD COLUMN final_fare DECIMAL(15,2) NULL AFTER best_route_duration;
+
+CREATE TABLE wallet_holds (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    order_id VARCHAR(64) NOT NULL,
+    wallet_id VARCHAR(64) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    captured_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    released_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'HELD',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_wallet_holds_order_id (order_id),
+    KEY idx_wallet_holds_wallet_id_status (wallet_id, status)
+);
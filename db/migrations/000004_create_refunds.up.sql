@@ -0,0 +1,22 @@
+CREATE TABLE refunds (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    refund_id VARCHAR(64) NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    passenger_id VARCHAR(64) NOT NULL,
+    payment_method VARCHAR(16) NOT NULL,
+    payment_transaction_id BIGINT UNSIGNED NULL,
+    stage VARCHAR(16) NOT NULL,
+    reason VARCHAR(64) NOT NULL,
+    fee_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    provider_reference VARCHAR(128) NULL,
+    last_error VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_refunds_refund_id (refund_id),
+    KEY idx_refunds_order_id (order_id),
+    KEY idx_refunds_status (status)
+);
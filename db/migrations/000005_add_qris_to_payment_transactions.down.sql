@@ -0,0 +1,4 @@
+ALTER TABLE payment_transactions
+    DROP INDEX uq_payment_transactions_provider_ref,
+    DROP COLUMN expires_at,
+    DROP COLUMN qr_payload;
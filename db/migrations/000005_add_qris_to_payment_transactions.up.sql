@@ -0,0 +1,4 @@
+ALTER TABLE payment_transactions
+    ADD COLUMN qr_payload TEXT NULL AFTER provider_reference_id,
+    ADD COLUMN expires_at DATETIME(6) NULL AFTER qr_payload,
+    ADD UNIQUE KEY uq_payment_transactions_provider_ref (provider_name, provider_reference_id);
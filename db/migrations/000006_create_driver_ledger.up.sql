@@ -0,0 +1,16 @@
+CREATE TABLE driver_ledger (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    entry_id VARCHAR(96) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    order_id VARCHAR(64) NULL,
+    entry_type VARCHAR(16) NOT NULL,
+    fare DECIMAL(15,2) NOT NULL DEFAULT 0,
+    cash_collected DECIMAL(15,2) NOT NULL DEFAULT 0,
+    amount DECIMAL(15,2) NOT NULL,
+    debt_after DECIMAL(15,2) NOT NULL DEFAULT 0,
+    description VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_driver_ledger_entry_id (entry_id),
+    KEY idx_driver_ledger_driver_id (driver_id, created_at)
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS payout_items;
+DROP TABLE IF EXISTS payout_batches;
+
+ALTER TABLE orders
+    DROP INDEX idx_orders_completed_at,
+    DROP COLUMN completed_at;
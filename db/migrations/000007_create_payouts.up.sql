@@ -0,0 +1,42 @@
+ALTER TABLE orders
+    ADD COLUMN completed_at DATETIME(6) NULL AFTER final_fare,
+    ADD KEY idx_orders_completed_at (completed_at);
+
+CREATE TABLE payout_batches (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    batch_id VARCHAR(96) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    payout_date DATE NOT NULL,
+    trip_count INT NOT NULL DEFAULT 0,
+    gross_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    commission_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    net_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    failure_reason VARCHAR(255) NULL,
+    paid_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payout_batches_batch_id (batch_id),
+    UNIQUE KEY uq_payout_batches_driver_date (driver_id, payout_date),
+    KEY idx_payout_batches_date_status (payout_date, status)
+);
+
+CREATE TABLE payout_items (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    batch_id VARCHAR(96) NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    payment_method VARCHAR(16) NOT NULL,
+    city VARCHAR(64) NOT NULL DEFAULT '',
+    vehicle_type VARCHAR(32) NOT NULL DEFAULT '',
+    fare DECIMAL(15,2) NOT NULL,
+    commission_rate DECIMAL(5,2) NOT NULL,
+    commission DECIMAL(15,2) NOT NULL,
+    earning DECIMAL(15,2) NOT NULL,
+    completed_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payout_items_order_id (order_id),
+    KEY idx_payout_items_batch_id (batch_id)
+);
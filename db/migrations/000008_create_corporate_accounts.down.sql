@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS corporate_invoice_items;
+DROP TABLE IF EXISTS corporate_invoices;
+
+ALTER TABLE orders
+    DROP INDEX idx_orders_company_id,
+    DROP COLUMN cost_center,
+    DROP COLUMN company_id;
+
+DROP TABLE IF EXISTS company_policies;
+DROP TABLE IF EXISTS company_members;
+DROP TABLE IF EXISTS companies;
@@ -0,0 +1,74 @@
+CREATE TABLE companies (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    company_id VARCHAR(64) NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    billing_email VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_companies_company_id (company_id)
+);
+
+CREATE TABLE company_members (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    company_id VARCHAR(64) NOT NULL,
+    user_id VARCHAR(64) NOT NULL,
+    cost_center VARCHAR(64) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_company_members_user_id (user_id),
+    KEY idx_company_members_company_id (company_id)
+);
+
+CREATE TABLE company_policies (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    company_id VARCHAR(64) NOT NULL,
+    start_time CHAR(5) NULL,
+    end_time CHAR(5) NULL,
+    weekdays VARCHAR(32) NULL,
+    allowed_zones JSON NULL,
+    max_fare DECIMAL(15,2) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_company_policies_company_id (company_id)
+);
+
+ALTER TABLE orders
+    ADD COLUMN company_id VARCHAR(64) NULL AFTER payment_status,
+    ADD COLUMN cost_center VARCHAR(64) NULL AFTER company_id,
+    ADD KEY idx_orders_company_id (company_id, completed_at);
+
+CREATE TABLE corporate_invoices (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    invoice_id VARCHAR(96) NOT NULL,
+    company_id VARCHAR(64) NOT NULL,
+    period CHAR(7) NOT NULL,
+    trip_count INT NOT NULL DEFAULT 0,
+    total_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'ISSUED',
+    issued_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    due_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_corporate_invoices_invoice_id (invoice_id),
+    UNIQUE KEY uq_corporate_invoices_company_period (company_id, period)
+);
+
+CREATE TABLE corporate_invoice_items (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    invoice_id VARCHAR(96) NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    passenger_id VARCHAR(64) NOT NULL,
+    cost_center VARCHAR(64) NOT NULL DEFAULT '',
+    amount DECIMAL(15,2) NOT NULL,
+    completed_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_corporate_invoice_items_order_id (order_id),
+    KEY idx_corporate_invoice_items_invoice_id (invoice_id)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    topic VARCHAR(128) NOT NULL,
+    message_key VARCHAR(128) NOT NULL,
+    payload LONGBLOB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(512) NULL,
+    available_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    sent_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    KEY idx_outbox_messages_status_available (status, available_at, id),
+    KEY idx_outbox_messages_sent_at (sent_at)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE payment_collections (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    order_id VARCHAR(64) NOT NULL,
+    passenger_id VARCHAR(64) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    reason VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'OPEN',
+    payment_transaction_id BIGINT UNSIGNED NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payment_collections_order_id (order_id),
+    KEY idx_payment_collections_status (status)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE trip_tracks (
+    order_id VARCHAR(64) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    distance_km DECIMAL(10,3) NOT NULL DEFAULT 0,
+    point_count INT NOT NULL DEFAULT 0,
+    path JSON NOT NULL,
+    started_at DATETIME(6) NOT NULL,
+    ended_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (order_id),
+    KEY idx_trip_tracks_driver_id (driver_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE outbox_messages
+    ADD COLUMN headers JSON NULL AFTER payload;
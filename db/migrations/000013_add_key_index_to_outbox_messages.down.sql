@@ -0,0 +1,2 @@
+ALTER TABLE outbox_messages
+    DROP KEY idx_outbox_messages_key_status;
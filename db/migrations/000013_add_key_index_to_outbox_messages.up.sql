@@ -0,0 +1,2 @@
+ALTER TABLE outbox_messages
+    ADD KEY idx_outbox_messages_key_status (message_key, status, id);
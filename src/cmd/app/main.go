@@ -9,6 +9,7 @@ import (
 	"order-service/src/pkg/log"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
 	"github.com/hibiken/asynq"
@@ -25,6 +26,12 @@ func main() {
 	viperConfig.SetDefault("log.level", "DEBUG")
 	viperConfig.SetDefault("app.name", "ORDER_SERVICE")
 	viperConfig.SetDefault("web.port", 8080)
+	// dispatch.legacy_pickup_keys opts in to scanning the DRIVER:REQUEST-PICKUP keys
+	// of driver services not yet writing the offer index, it is removed once every
+	// driver service writes ORDER:PICKUP-OFFERS
+	viperConfig.SetDefault("dispatch.legacy_pickup_keys", false)
+	viperConfig.SetDefault("kafka.topics.payment_result", "payment-result")
+	viperConfig.SetDefault("kafka.topics.trip_tracking", "trip-tracking")
 	log.InitLogger(viperConfig)
 	config.NewKafkaConfig(viperConfig)
 	logger := log.GetLogger()
@@ -32,7 +39,8 @@ func main() {
 	config.LoadRedisConfig(viperConfig)
 	db := config.NewDatabase(viperConfig, logger)
 	redisClient := config.NewRedis()
-	producer := config.NewKafkaProducer(viperConfig, logger)
+	producer, consumer := config.NewMessageBus(viperConfig, redisClient, logger)
+	serializer := config.NewSerializer(viperConfig, logger)
 	validate := config.NewValidator(viperConfig)
 	geoservice, errG := config.NewGeoService(viperConfig)
 	if errG != nil {
@@ -40,6 +48,7 @@ func main() {
 		return
 	}
 	app := config.NewFiber(viperConfig)
+	app.Use(middleware.NewTracing())
 	app.Use(middleware.NewLogger())
 	redisOpt := asynq.RedisClientOpt{
 		Addr:     fmt.Sprintf("%s:%v", viperConfig.GetString("redis.host"), viperConfig.GetString("redis.port")),
@@ -70,7 +79,10 @@ func main() {
 		},
 	)
 
+	scheduler := asynq.NewScheduler(redisOpt, &asynq.SchedulerOpts{Location: loc})
 	mux := asynq.NewServeMux()
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	var background sync.WaitGroup
 	config.Bootstrap(&config.BootstrapConfig{
 		DB:          db,
 		App:         app,
@@ -78,10 +90,15 @@ func main() {
 		Validate:    validate,
 		Config:      viperConfig,
 		Producer:    producer,
+		Serializer:  serializer,
 		Redis:       redisClient,
 		Geoservice:  geoservice,
 		AsynqClient: asynqClient,
 		Async:       mux,
+		Scheduler:   scheduler,
+		Consumer:    consumer,
+		Context:     consumerCtx,
+		Background:  &background,
 	})
 	done := make(chan bool)
 	quit := make(chan os.Signal, 1)
@@ -101,6 +118,10 @@ func main() {
 		}
 	}()
 
+	if err := scheduler.Start(); err != nil {
+		logger.Error("main", fmt.Sprintf("Asynq scheduler failed to start: %v", err), "asynq", "")
+	}
+
 	go func() {
 		<-quit
 		logger.Info("main", "Server order-service is shutting down...", "gracefull", "")
@@ -111,6 +132,9 @@ func main() {
 		if err := app.Shutdown(); err != nil {
 			logger.Error("main", fmt.Sprintf("Error during shutdown: %v", err), "graceful", "")
 		}
+		scheduler.Shutdown()
+		stopConsumer()
+		background.Wait()
 		close(done)
 	}()
 
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"order-service/src/internal/delivery/http"
 	"order-service/src/internal/delivery/http/middleware"
 	"order-service/src/internal/delivery/http/route"
+	consumer "order-service/src/internal/delivery/messaging"
 	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/gateway/payment"
 
 	// "order-service/src/internal/gateway/messaging"
 	"order-service/src/internal/repository"
@@ -12,6 +16,8 @@ import (
 	"order-service/src/pkg/databases/mysql"
 	kafkaPkgConfluent "order-service/src/pkg/kafka/confluent"
 	"order-service/src/pkg/log"
+	"sync"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
@@ -28,32 +34,85 @@ type BootstrapConfig struct {
 	Validate    *validator.Validate
 	Config      *viper.Viper
 	Producer    kafkaPkgConfluent.Producer
+	Serializer  messaging.Serializer
 	Redis       redis.UniversalClient
 	Geoservice  *GeoService
 	AsynqClient *asynq.Client
 	Async       *asynq.ServeMux
+	Scheduler   *asynq.Scheduler
+	Consumer    kafkaPkgConfluent.Consumer
+	// Context stops the consumer, Background tracks it so shutdown can wait for
+	// the in-flight messages
+	Context    context.Context
+	Background *sync.WaitGroup
 }
 
 const (
-	TypeBroadcastDriver = "passanger:request-ride"
+	TypeBroadcastDriver  = "passanger:request-ride"
+	TypeAutoDispatch     = "order:auto-dispatch"
+	TypeBatchDispatch    = "order:batch-dispatch"
+	TypeQueueSweep       = "order:queue-sweep"
+	TypeHoldExpiry       = "wallet:hold-expiry"
+	TypeProcessRefund    = "refund:process"
+	TypeRefundSweep      = "refund:sweep"
+	TypePayoutSettle     = "payout:settle"
+	TypePayoutDisburse   = "payout:disburse"
+	TypeCorporateInvoice = "corporate:invoice"
+	TypeOutboxRelay      = "outbox:relay"
 )
 
 func Bootstrap(config *BootstrapConfig) {
+	// a payload changed without a new schema version breaks consumers silently
+	if err := messaging.CheckSchemas(); err != nil {
+		config.Log.Error("bootstrap", fmt.Sprintf("Event schemas are not compatible: %v", err), "messaging", "")
+	}
 	// setup repositories
 	userRepository := repository.NewUserRepository(config.DB)
 	walletRepository := repository.NewWalletRepository(config.DB)
 	orderRepository := repository.NewOrderRepository(config.DB)
 	driverRepository := repository.NewDriverRepository(config.DB)
-	userProducer := messaging.NewUserProducer(config.Producer, config.Log)
-	driverProducer := messaging.NewDriverProducer(config.Producer, config.Log)
+	sharedRideRepository := repository.NewSharedRideRepository(config.DB)
+	refundRepository := repository.NewRefundRepository(config.DB)
+	paymentRepository := repository.NewPaymentRepository(config.DB)
+	driverLedgerRepository := repository.NewDriverLedgerRepository(config.DB)
+	payoutRepository := repository.NewPayoutRepository(config.DB)
+	corporateRepository := repository.NewCorporateRepository(config.DB)
+	outboxRepository := repository.NewOutboxRepository(config.DB)
+	userProducer := messaging.NewUserProducer(config.Producer, config.Serializer, config.Log)
+	driverProducer := messaging.NewDriverProducer(config.Producer, config.Serializer, config.Log)
+	dispatchProducer := messaging.NewDispatchProducer(config.Producer, config.Serializer, config.Log)
+	receiptProducer := messaging.NewReceiptProducer(config.Producer, config.Serializer, config.Log)
+	outboxProducer := messaging.NewOutboxProducer(config.Producer, config.Log)
+	orderProducer := messaging.NewOrderProducer(config.Producer, config.Serializer, config.Log)
+	orderEvents := usecase.NewOrderEvents(orderRepository, outboxRepository, orderProducer)
+	paymentProvider, err := payment.NewProvider(config.Config, config.Log)
+	if err != nil {
+		panic(fmt.Errorf("payment provider: %w", err))
+	}
+	qrProvider, err := payment.NewQRProvider(config.Config, config.Log)
+	if err != nil {
+		panic(fmt.Errorf("QRIS provider: %w", err))
+	}
+	paymentMethods := usecase.NewPaymentMethods().
+		Register(usecase.NewWalletPaymentMethod(config.Log, walletRepository, config.AsynqClient), "WALLET").
+		Register(usecase.NewQrisPaymentMethod(config.Log, paymentRepository, qrProvider)).
+		Register(usecase.NewCashPaymentMethod(config.Log, driverRepository, driverLedgerRepository, config.Config, config.Redis)).
+		Register(usecase.NewCorporatePaymentMethod(config.Log, corporateRepository, config.Config), "COMPANY")
+	if err := config.Validate.RegisterValidation("payment_method", paymentMethods.ValidatePaymentMethod); err != nil {
+		config.Log.Error("bootstrap", fmt.Sprintf("Failed register payment_method validation: %v", err), "validator", "")
+	}
 	// setup use cases
 	userUseCase := usecase.NewUserUseCase(
 		config.Log,
 		config.Validate,
 		userRepository,
-		walletRepository,
+		paymentMethods,
 		orderRepository,
 		driverRepository,
+		sharedRideRepository,
+		refundRepository,
+		outboxRepository,
+		orderEvents,
 		config.Config,
 		config.Redis,
 		userProducer,
@@ -67,23 +126,214 @@ func Bootstrap(config *BootstrapConfig) {
 		userRepository,
 		driverRepository,
 		orderRepository,
-		walletRepository,
+		paymentMethods,
+		sharedRideRepository,
+		outboxRepository,
+		orderEvents,
 		config.Config,
 		config.Redis,
 		driverProducer,
+		receiptProducer,
+	)
+
+	dispatchUseCase := usecase.NewDispatchUseCase(
+		config.Log,
+		orderRepository,
+		driverRepository,
+		config.Config,
+		config.Redis,
+		dispatchProducer,
+	)
+
+	queueUseCase := usecase.NewQueueUseCase(
+		config.Log,
+		config.Validate,
+		driverRepository,
+		config.Config,
+		config.Redis,
+	)
+
+	walletUseCase := usecase.NewWalletUseCase(
+		config.Log,
+		config.Validate,
+		walletRepository,
+		config.Config,
+		config.Redis,
+		paymentProvider,
+	)
+
+	refundUseCase := usecase.NewRefundUseCase(
+		config.Log,
+		refundRepository,
+		config.Config,
+		paymentMethods,
+		config.AsynqClient,
+	)
+
+	driverLedgerUseCase := usecase.NewDriverLedgerUseCase(
+		config.Log,
+		config.Validate,
+		driverLedgerRepository,
+		config.Config,
+		config.Redis,
+		paymentProvider,
+	)
+
+	paymentUseCase := usecase.NewPaymentUseCase(
+		config.Log,
+		config.Validate,
+		paymentRepository,
+		orderRepository,
+		config.Config,
+		qrProvider,
+		receiptProducer,
+		outboxRepository,
+		orderEvents,
+		walletRepository,
+	)
+
+	payoutUseCase := usecase.NewPayoutUseCase(
+		config.Log,
+		config.Validate,
+		payoutRepository,
+		config.Config,
+		config.AsynqClient,
+	)
+
+	corporateUseCase := usecase.NewCorporateUseCase(
+		config.Log,
+		config.Validate,
+		corporateRepository,
+		config.Config,
+	)
+
+	receiptUseCase := usecase.NewReceiptUseCase(
+		config.Log,
+		config.Validate,
+		orderRepository,
+		config.Config,
+	)
+
+	outboxUseCase := usecase.NewOutboxUseCase(
+		config.Log,
+		outboxRepository,
+		outboxProducer,
+		config.Config,
 	)
 
 	// setup controller
 	userController := http.NewUserController(userUseCase, config.Log)
 	driverController := http.NewDriverController(driverUseCase, config.Log)
+	queueController := http.NewQueueController(queueUseCase, config.Log)
+	walletController := http.NewWalletController(walletUseCase, config.Log)
+	paymentController := http.NewPaymentController(paymentUseCase, config.Log)
+	driverLedgerController := http.NewDriverLedgerController(driverLedgerUseCase, config.Log)
+	payoutController := http.NewPayoutController(payoutUseCase, config.Log)
+	corporateController := http.NewCorporateController(corporateUseCase, config.Log)
+	receiptController := http.NewReceiptController(receiptUseCase, config.Log)
 	// setup middleware
 	authMiddleware := middleware.VerifyBearer(config.Config)
+	adminMiddleware := middleware.RequireAdmin(config.Config)
+	config.Async.Use(NewAsynqTracing())
 	config.Async.HandleFunc(TypeBroadcastDriver, userUseCase.RequestRide)
+	config.Async.HandleFunc(TypeAutoDispatch, userUseCase.AutoDispatch)
+	config.Async.HandleFunc(TypeBatchDispatch, dispatchUseCase.BatchDispatch)
+	config.Async.HandleFunc(TypeQueueSweep, queueUseCase.SweepQueues)
+	config.Async.HandleFunc(TypeHoldExpiry, userUseCase.ExpireHold)
+	config.Async.HandleFunc(TypeProcessRefund, refundUseCase.ProcessRefund)
+	config.Async.HandleFunc(TypeRefundSweep, refundUseCase.SweepRefunds)
+	config.Async.HandleFunc(TypePayoutSettle, payoutUseCase.SettlePayouts)
+	config.Async.HandleFunc(TypePayoutDisburse, payoutUseCase.DisbursePayout)
+	config.Async.HandleFunc(TypeCorporateInvoice, corporateUseCase.GenerateInvoices)
+	config.Async.HandleFunc(TypeOutboxRelay, outboxUseCase.RelayOutbox)
+	// order events only leave through the outbox, the relay always runs
+	relayInterval := config.Config.GetDuration("outbox.interval")
+	if relayInterval <= 0 {
+		relayInterval = 2 * time.Second
+	}
+	relayTask := asynq.NewTask(TypeOutboxRelay, nil, asynq.MaxRetry(0), asynq.Timeout(time.Minute), asynq.Unique(time.Minute))
+	if _, err := config.Scheduler.Register(fmt.Sprintf("@every %s", relayInterval), relayTask); err != nil {
+		config.Log.Error("bootstrap", fmt.Sprintf("Failed register outbox relay task: %v", err), "asynq", "")
+	}
+	// recorded refunds are always settled, the sweep picks up those never enqueued
+	sweepInterval := config.Config.GetDuration("refund.sweep_interval")
+	if sweepInterval <= 0 {
+		sweepInterval = time.Minute
+	}
+	sweepTask := asynq.NewTask(TypeRefundSweep, nil, asynq.MaxRetry(0), asynq.Timeout(sweepInterval), asynq.Unique(sweepInterval))
+	if _, err := config.Scheduler.Register(fmt.Sprintf("@every %s", sweepInterval), sweepTask); err != nil {
+		config.Log.Error("bootstrap", fmt.Sprintf("Failed register refund sweep task: %v", err), "asynq", "")
+	}
+	if config.Config.GetBool("dispatch.batch.enabled") {
+		interval := config.Config.GetDuration("dispatch.batch.interval")
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		task := asynq.NewTask(TypeBatchDispatch, nil, asynq.MaxRetry(0), asynq.Timeout(interval), asynq.Unique(interval))
+		if _, err := config.Scheduler.Register(fmt.Sprintf("@every %s", interval), task); err != nil {
+			config.Log.Error("bootstrap", fmt.Sprintf("Failed register batch dispatch task: %v", err), "asynq", "")
+		}
+	}
+	if config.Config.IsSet("dispatch.queue.zones") {
+		interval := config.Config.GetDuration("dispatch.queue.sweep_interval")
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		task := asynq.NewTask(TypeQueueSweep, nil, asynq.MaxRetry(0), asynq.Timeout(interval), asynq.Unique(interval))
+		if _, err := config.Scheduler.Register(fmt.Sprintf("@every %s", interval), task); err != nil {
+			config.Log.Error("bootstrap", fmt.Sprintf("Failed register queue sweep task: %v", err), "asynq", "")
+		}
+	}
+	if config.Config.GetBool("payout.enabled") {
+		interval := config.Config.GetDuration("payout.interval")
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		task := asynq.NewTask(TypePayoutSettle, nil, asynq.MaxRetry(0), asynq.Timeout(interval), asynq.Unique(interval))
+		if _, err := config.Scheduler.Register(fmt.Sprintf("@every %s", interval), task); err != nil {
+			config.Log.Error("bootstrap", fmt.Sprintf("Failed register payout settlement task: %v", err), "asynq", "")
+		}
+	}
+	if config.Config.GetBool("corporate.invoice.enabled") {
+		cronspec := config.Config.GetString("corporate.invoice.cron")
+		if cronspec == "" {
+			// 02:00 on the first day of the month
+			cronspec = "0 2 1 * *"
+		}
+		task := asynq.NewTask(TypeCorporateInvoice, nil, asynq.MaxRetry(5))
+		if _, err := config.Scheduler.Register(cronspec, task); err != nil {
+			config.Log.Error("bootstrap", fmt.Sprintf("Failed register corporate invoice task: %v", err), "asynq", "")
+		}
+	}
 	routeConfig := route.RouteConfig{
-		App:              config.App,
-		UserController:   userController,
-		DriverController: driverController,
-		AuthMiddleware:   authMiddleware,
+		App:                 config.App,
+		UserController:      userController,
+		DriverController:    driverController,
+		QueueController:     queueController,
+		WalletController:    walletController,
+		PaymentController:   paymentController,
+		LedgerController:    driverLedgerController,
+		PayoutController:    payoutController,
+		CorporateController: corporateController,
+		ReceiptController:   receiptController,
+		AuthMiddleware:      authMiddleware,
+		AdminMiddleware:     adminMiddleware,
 	}
 	routeConfig.Setup()
+
+	if config.Consumer != nil {
+		paymentConsumer := consumer.NewPaymentConsumer(paymentUseCase, config.Log)
+		trackingConsumer := consumer.NewTrackingConsumer(usecase.NewTripTrackingUseCase(config.Log, config.Config, config.Redis), config.Log)
+		router := kafkaPkgConfluent.NewRouter().
+			Handle(config.Config.GetString("kafka.topics.payment_result"), kafkaPkgConfluent.ConsumerHandlerFunc(paymentConsumer.ConsumePaymentResult)).
+			Handle(config.Config.GetString("kafka.topics.trip_tracking"), kafkaPkgConfluent.ConsumerHandlerFunc(trackingConsumer.ConsumeTripLocation))
+		config.Consumer.SetHandler(router)
+		config.Background.Add(1)
+		go func() {
+			defer config.Background.Done()
+			if err := config.Consumer.Subscribe(config.Context, router.Topics()...); err != nil {
+				config.Log.Error("bootstrap", fmt.Sprintf("Kafka consumer stopped with error: %v", err), "kafka", "")
+			}
+		}()
+	}
 }
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"order-service/src/pkg/tracing"
 	"time"
 
 	"github.com/hibiken/asynq"
@@ -34,3 +36,22 @@ func NewAsynqClient(v *viper.Viper) *asynq.Client {
 
 	return asynq.NewClient(redisOpt)
 }
+
+// NewAsynqTracing continues the trace a task was enqueued with, see
+// tracing.InjectPayload. A task enqueued outside a traced request, such as a
+// periodic one, starts a trace whose request id is the task id so the runs of
+// one task share it.
+func NewAsynqTracing() asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			trace, ok := tracing.ExtractPayload(t.Payload())
+			if !ok {
+				trace = tracing.New()
+				if taskID, ok := asynq.GetTaskID(ctx); ok {
+					trace.RequestID = taskID
+				}
+			}
+			return next.ProcessTask(tracing.WithTrace(ctx, trace), t)
+		})
+	}
+}
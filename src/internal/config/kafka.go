@@ -1,12 +1,24 @@
 package config
 
 import (
+	"fmt"
+	"order-service/src/internal/gateway/messaging"
 	kafkaPkgConfluent "order-service/src/pkg/kafka/confluent"
 	"order-service/src/pkg/log"
+	"order-service/src/pkg/schemaregistry"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
 
+// Backends of the message bus selected by kafka.backend.
+const (
+	BusKafka  = "kafka"
+	BusMemory = "memory"
+	BusRedis  = "redis"
+	BusFile   = "file"
+)
+
 func NewKafkaConfig(viper *viper.Viper) kafkaPkgConfluent.KafkaConfig {
 	configKafka := kafkaPkgConfluent.Cfg{
 		KafkaUrl:      viper.GetString("kafka.bootstrap.servers"),
@@ -19,10 +31,12 @@ func NewKafkaConfig(viper *viper.Viper) kafkaPkgConfluent.KafkaConfig {
 
 }
 
+// NewKafkaProducer returns the Kafka producer, or one discarding every message
+// when kafka.producer.enabled is off.
 func NewKafkaProducer(config *viper.Viper, log log.Log) kafkaPkgConfluent.Producer {
 	if !config.GetBool("kafka.producer.enabled") {
-		log.Info("kafka-config", "Kafka producer is disabled in configuration", "kafka", "")
-		return nil
+		log.Error("kafka-config", "Kafka producer is disabled in configuration, published messages are discarded", "kafka", "")
+		return kafkaPkgConfluent.DiscardProducer{}
 	}
 	kafkaProducer, err := kafkaPkgConfluent.NewProducer(kafkaPkgConfluent.GetConfig().GetKafkaConfig(), log)
 	if err != nil {
@@ -31,3 +45,114 @@ func NewKafkaProducer(config *viper.Viper, log log.Log) kafkaPkgConfluent.Produc
 
 	return kafkaProducer
 }
+
+// NewKafkaConsumer returns a consumer of the service group, messages failing
+// every retry are sent to the dead letter topic through the producer.
+func NewKafkaConsumer(config *viper.Viper, producer kafkaPkgConfluent.Producer, log log.Log) kafkaPkgConfluent.Consumer {
+	if !config.GetBool("kafka.consumer.enabled") {
+		log.Info("kafka-config", "Kafka consumer is disabled in configuration", "kafka", "")
+		return nil
+	}
+	options := newConsumerOptions(config)
+	options.DeadLetterProducer = producer
+	kafkaConsumer, err := kafkaPkgConfluent.NewConsumer(kafkaPkgConfluent.GetConfig().GetKafkaConfig(), options, log)
+	if err != nil {
+		panic(err)
+	}
+
+	return kafkaConsumer
+}
+
+func newConsumerOptions(config *viper.Viper) kafkaPkgConfluent.ConsumerOptions {
+	return kafkaPkgConfluent.ConsumerOptions{
+		Retries:          config.GetInt("kafka.consumer.retries"),
+		Backoff:          config.GetDuration("kafka.consumer.backoff"),
+		MaxBackoff:       config.GetDuration("kafka.consumer.max_backoff"),
+		DeadLetterSuffix: config.GetString("kafka.consumer.dlq_suffix"),
+		QueueSize:        config.GetInt("kafka.consumer.queue_size"),
+	}
+}
+
+// NewMessageBus returns the producer and consumer of the backend at
+// kafka.backend: kafka (the default), memory, redis for Redis streams, or file
+// to write the published messages to bus.file.path or stdout without consuming.
+func NewMessageBus(config *viper.Viper, redisClient redis.UniversalClient, log log.Log) (kafkaPkgConfluent.Producer, kafkaPkgConfluent.Consumer) {
+	backend := config.GetString("kafka.backend")
+	switch backend {
+	case "", BusKafka:
+		producer := NewKafkaProducer(config, log)
+		var deadLetter kafkaPkgConfluent.Producer
+		if config.GetBool("kafka.producer.enabled") {
+			deadLetter = producer
+		}
+		return producer, NewKafkaConsumer(config, deadLetter, log)
+	case BusMemory:
+		log.Info("kafka-config", "Message bus runs in memory", "kafka", "")
+		bus := kafkaPkgConfluent.NewMemoryBus()
+		return bus, newBusConsumer(config, log, func(options kafkaPkgConfluent.ConsumerOptions) kafkaPkgConfluent.Consumer {
+			return bus.NewConsumer(options, log)
+		})
+	case BusRedis:
+		prefix := config.GetString("bus.redis.prefix")
+		if prefix == "" {
+			prefix = "bus:"
+		}
+		maxLen := config.GetInt64("bus.redis.max_len")
+		if maxLen == 0 {
+			maxLen = 100000
+		}
+		log.Info("kafka-config", fmt.Sprintf("Message bus runs on Redis streams %s*", prefix), "kafka", "")
+		bus := kafkaPkgConfluent.NewRedisStreamBus(redisClient, prefix, maxLen)
+		return bus, newBusConsumer(config, log, func(options kafkaPkgConfluent.ConsumerOptions) kafkaPkgConfluent.Consumer {
+			return bus.NewConsumer(config.GetString("kafka.app.name"), options, log)
+		})
+	case BusFile:
+		path := config.GetString("bus.file.path")
+		sink, err := kafkaPkgConfluent.NewFileSink(path)
+		if err != nil {
+			panic(err)
+		}
+		if path == "" {
+			path = "stdout"
+		}
+		log.Info("kafka-config", fmt.Sprintf("Message bus writes to %s, nothing is consumed", path), "kafka", "")
+		return sink, nil
+	default:
+		panic(fmt.Errorf("unknown kafka.backend %q", backend))
+	}
+}
+
+func newBusConsumer(config *viper.Viper, log log.Log, consumer func(kafkaPkgConfluent.ConsumerOptions) kafkaPkgConfluent.Consumer) kafkaPkgConfluent.Consumer {
+	if !config.GetBool("kafka.consumer.enabled") {
+		log.Info("kafka-config", "Kafka consumer is disabled in configuration", "kafka", "")
+		return nil
+	}
+	return consumer(newConsumerOptions(config))
+}
+
+// NewSerializer returns the serializer of the published events. Avro registers
+// its schemas with the registry at schema_registry.url, or in the file at
+// schema_registry.file when no url is set.
+func NewSerializer(config *viper.Viper, log log.Log) messaging.Serializer {
+	if config.GetString("kafka.serializer") != messaging.SerializerAvro {
+		return messaging.NewJSONSerializer()
+	}
+	if url := config.GetString("schema_registry.url"); url != "" {
+		log.Info("kafka-config", fmt.Sprintf("Avro serializer uses schema registry %s", url), "kafka", "")
+		return messaging.NewAvroSerializer(schemaregistry.NewClient(
+			url,
+			config.GetString("schema_registry.username"),
+			config.GetString("schema_registry.password"),
+		))
+	}
+	path := config.GetString("schema_registry.file")
+	if path == "" {
+		path = "schema-registry.json"
+	}
+	registry, err := schemaregistry.NewFileRegistry(path)
+	if err != nil {
+		panic(err)
+	}
+	log.Info("kafka-config", fmt.Sprintf("Avro serializer uses local schema registry %s", path), "kafka", "")
+	return messaging.NewAvroSerializer(registry)
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CorporateController struct {
+	Log     log.Log
+	UseCase *usecase.CorporateUseCase
+}
+
+func NewCorporateController(useCase *usecase.CorporateUseCase, logger log.Log) *CorporateController {
+	return &CorporateController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *CorporateController) GetProfile(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	result := c.UseCase.GetProfile(ctx.Context(), auth.UserID)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Corporate Profile", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) CreateCompany(ctx *fiber.Ctx) error {
+	request := new(model.CreateCompanyRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("CorporateController.CreateCompany", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.CreateCompany(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Create Company", fiber.StatusCreated, ctx)
+}
+
+func (c *CorporateController) GetCompany(ctx *fiber.Ctx) error {
+	request := new(model.CompanyRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("CorporateController.GetCompany", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.GetCompany(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get Company", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) UpdateCompanyStatus(ctx *fiber.Ctx) error {
+	request := new(model.CompanyStatusRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("CorporateController.UpdateCompanyStatus", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.CompanyID = ctx.Params("companyId")
+	result := c.UseCase.UpdateCompanyStatus(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Update Company Status", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) UpsertMember(ctx *fiber.Ctx) error {
+	request := new(model.CompanyMemberRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("CorporateController.UpsertMember", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.CompanyID = ctx.Params("companyId")
+	request.UserID = ctx.Params("userId")
+	result := c.UseCase.UpsertMember(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Save Company Member", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) RemoveMember(ctx *fiber.Ctx) error {
+	request := new(model.RemoveCompanyMemberRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("CorporateController.RemoveMember", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.RemoveMember(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Remove Company Member", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) SetPolicy(ctx *fiber.Ctx) error {
+	request := new(model.CompanyPolicyRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("CorporateController.SetPolicy", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.CompanyID = ctx.Params("companyId")
+	result := c.UseCase.SetPolicy(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Set Company Policy", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) ListInvoices(ctx *fiber.Ctx) error {
+	request := new(model.CompanyRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("CorporateController.ListInvoices", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ListInvoices(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Company Invoices", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) GenerateInvoice(ctx *fiber.Ctx) error {
+	request := new(model.GenerateInvoiceRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("CorporateController.GenerateInvoice", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.CompanyID = ctx.Params("companyId")
+	result := c.UseCase.GenerateInvoice(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Generate Invoice", fiber.StatusOK, ctx)
+}
+
+func (c *CorporateController) GetInvoice(ctx *fiber.Ctx) error {
+	request := new(model.InvoiceRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("CorporateController.GetInvoice", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.GetInvoice(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get Invoice", fiber.StatusOK, ctx)
+}
@@ -38,6 +38,48 @@ func (c *DriverController) PickupPassanger(ctx *fiber.Ctx) error {
 	return utils.Response(result.Data, "Pickup Passanger", fiber.StatusOK, ctx)
 }
 
+func (c *DriverController) AcceptPickup(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.AcceptPickupRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("DriverController.AcceptPickup", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.AcceptPickup(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Accept Pickup", fiber.StatusOK, ctx)
+}
+
+func (c *DriverController) SetDestinationMode(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.DestinationModeRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("DriverController.SetDestinationMode", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.SetDestinationMode(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Set Destination Mode", fiber.StatusOK, ctx)
+}
+
+func (c *DriverController) ClearDestinationMode(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	result := c.UseCase.ClearDestinationMode(ctx.Context(), auth.UserID)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Clear Destination Mode", fiber.StatusOK, ctx)
+}
+
 func (c *DriverController) CompletedTrip(ctx *fiber.Ctx) error {
 	auth := middleware.GetUser(ctx)
 	request := new(model.RequestCompleteTrip)
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DriverLedgerController struct {
+	Log     log.Log
+	UseCase *usecase.DriverLedgerUseCase
+}
+
+func NewDriverLedgerController(useCase *usecase.DriverLedgerUseCase, logger log.Log) *DriverLedgerController {
+	return &DriverLedgerController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *DriverLedgerController) GetDebt(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	result := c.UseCase.GetDebt(ctx.Context(), auth.UserID)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get Driver Debt", fiber.StatusOK, ctx)
+}
+
+func (c *DriverLedgerController) SettleDebt(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.SettleDebtRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("DriverLedgerController.SettleDebt", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	if key := ctx.Get("Idempotency-Key"); key != "" {
+		request.IdempotencyKey = key
+	}
+	result := c.UseCase.SettleDebt(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Settle Driver Debt", fiber.StatusOK, ctx)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// RequireAdmin only lets through the users listed in admin.user_ids, it must run
+// after VerifyBearer.
+func RequireAdmin(viper *viper.Viper) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		auth := GetUser(c)
+		if auth == nil {
+			return utils.Response(nil, "Invalid token!", http.StatusUnauthorized, c)
+		}
+		for _, userID := range viper.GetStringSlice("admin.user_ids") {
+			if userID == auth.UserID {
+				return c.Next()
+			}
+		}
+		return utils.Response(nil, "Forbidden!", http.StatusForbidden, c)
+	}
+}
@@ -59,6 +59,7 @@ func VerifyBearer(viper *viper.Viper) fiber.Handler {
 		auth := model.Auth{
 			UserID:   claim.Metadata.UserID,
 			FullName: claim.Metadata.FullName,
+			Segment:  claim.Metadata.Segment,
 		}
 		c.Locals("metadata", &auth)
 		return c.Next()
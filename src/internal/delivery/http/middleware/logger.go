@@ -17,6 +17,7 @@ func NewLogger() fiber.Handler {
 		start := time.Now()
 		err := c.Next()
 		latency := time.Since(start)
+		logger := logger.WithContext(c.UserContext())
 
 		status := c.Response().StatusCode()
 		method := c.Method()
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"order-service/src/pkg/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewTracing continues the request id and trace context of the caller, or
+// starts them, for the handlers and everything they publish. The request id is
+// echoed in the response.
+func NewTracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		trace := tracing.Extract(func(key string) string {
+			return c.Get(key)
+		})
+		// handlers pass c.Context() down, c.UserContext() is for the rest
+		c.Context().SetUserValue(tracing.ContextKey, trace)
+		c.SetUserContext(tracing.WithTrace(c.UserContext(), trace))
+		c.Set(tracing.HeaderRequestID, trace.RequestID)
+		return c.Next()
+	}
+}
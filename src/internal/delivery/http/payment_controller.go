@@ -0,0 +1,50 @@
+package http
+
+import (
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentController struct {
+	Log     log.Log
+	UseCase *usecase.PaymentUseCase
+}
+
+func NewPaymentController(useCase *usecase.PaymentUseCase, logger log.Log) *PaymentController {
+	return &PaymentController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *PaymentController) GetQrisPayment(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.QrisPaymentRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("PaymentController.GetQrisPayment", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	result := c.UseCase.GetQrisPayment(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get QRIS Payment", fiber.StatusOK, ctx)
+}
+
+// Callback is called by the QRIS provider, it is authenticated by the body
+// signature instead of a user token.
+func (c *PaymentController) Callback(ctx *fiber.Ctx) error {
+	result := c.UseCase.HandleCallback(ctx.Context(), ctx.Body(), ctx.Get("X-Callback-Signature"))
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payment Callback", fiber.StatusOK, ctx)
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PayoutController struct {
+	Log     log.Log
+	UseCase *usecase.PayoutUseCase
+}
+
+func NewPayoutController(useCase *usecase.PayoutUseCase, logger log.Log) *PayoutController {
+	return &PayoutController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *PayoutController) ListDriverPayouts(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.PayoutListRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("PayoutController.ListDriverPayouts", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.ListPayouts(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	page := result.Data.(model.PayoutPage)
+	return utils.ResponseWithMeta(page.Batches, page.Meta, "Driver Payouts", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutController) ListPayouts(ctx *fiber.Ctx) error {
+	request := new(model.PayoutListRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("PayoutController.ListPayouts", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ListPayouts(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	page := result.Data.(model.PayoutPage)
+	return utils.ResponseWithMeta(page.Batches, page.Meta, "Payouts", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutController) PayoutReport(ctx *fiber.Ctx) error {
+	request := new(model.PayoutReportRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("PayoutController.PayoutReport", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.PayoutReport(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payout Report", fiber.StatusOK, ctx)
+}
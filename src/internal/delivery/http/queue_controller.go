@@ -0,0 +1,59 @@
+package http
+
+import (
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type QueueController struct {
+	Log     log.Log
+	UseCase *usecase.QueueUseCase
+}
+
+func NewQueueController(useCase *usecase.QueueUseCase, logger log.Log) *QueueController {
+	return &QueueController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *QueueController) ListQueueZones(ctx *fiber.Ctx) error {
+	result := c.UseCase.ListQueueZones(ctx.Context())
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "List Queue Zones", fiber.StatusOK, ctx)
+}
+
+func (c *QueueController) GetQueue(ctx *fiber.Ctx) error {
+	request := new(model.QueueRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("QueueController.GetQueue", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.GetQueue(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get Queue", fiber.StatusOK, ctx)
+}
+
+func (c *QueueController) RemoveFromQueue(ctx *fiber.Ctx) error {
+	request := new(model.QueueRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("QueueController.RemoveFromQueue", "Failed to parse request params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.RemoveFromQueue(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Remove From Queue", fiber.StatusOK, ctx)
+}
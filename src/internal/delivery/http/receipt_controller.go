@@ -0,0 +1,44 @@
+package http
+
+import (
+	"fmt"
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReceiptController struct {
+	Log     log.Log
+	UseCase *usecase.ReceiptUseCase
+}
+
+func NewReceiptController(useCase *usecase.ReceiptUseCase, logger log.Log) *ReceiptController {
+	return &ReceiptController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *ReceiptController) GetReceipt(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.ReceiptRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("ReceiptController.GetReceipt", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	request.OrderID = ctx.Params("orderId")
+	result := c.UseCase.GetReceipt(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	document := result.Data.(model.ReceiptDocument)
+	ctx.Set(fiber.HeaderContentType, document.ContentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", document.FileName))
+	return ctx.Status(fiber.StatusOK).Send(document.Content)
+}
@@ -8,10 +8,18 @@ import (
 )
 
 type RouteConfig struct {
-	App              *fiber.App
-	UserController   *http.UserController
-	DriverController *http.DriverController
-	AuthMiddleware   fiber.Handler
+	App                 *fiber.App
+	UserController      *http.UserController
+	DriverController    *http.DriverController
+	QueueController     *http.QueueController
+	WalletController    *http.WalletController
+	PaymentController   *http.PaymentController
+	LedgerController    *http.DriverLedgerController
+	PayoutController    *http.PayoutController
+	CorporateController *http.CorporateController
+	ReceiptController   *http.ReceiptController
+	AuthMiddleware      fiber.Handler
+	AdminMiddleware     fiber.Handler
 }
 
 func (c *RouteConfig) Setup() {
@@ -19,6 +27,8 @@ func (c *RouteConfig) Setup() {
 	c.App.Get("/health", func(ctx *fiber.Ctx) error {
 		return ctx.SendString("OK")
 	})
+	// provider webhooks are signed by the provider and sit outside the auth middleware
+	c.App.Post("/payments/v1/callback", c.PaymentController.Callback)
 	c.SetupAuthRoute()
 
 }
@@ -32,10 +42,45 @@ func (c *RouteConfig) SetupAuthRoute() {
 	c.App.Post("/order/v1/confirm", c.UserController.ConfirmOrder)
 	c.App.Post("/order/v1/cancel", c.UserController.CancelOrder)
 	c.App.Get("/order/v1/driver-pickup/:orderId", c.UserController.GetDriverPickupRequest)
+	c.App.Get("/order/v1/:orderId/receipt", c.ReceiptController.GetReceipt)
 	c.App.Get("/users/v1/order-status/:orderId", c.UserController.GetOrderStatus)
+	c.App.Get("/users/v1/corporate", c.CorporateController.GetProfile)
+
+	// wallet routes
+	c.App.Get("/wallets/v1", c.WalletController.GetWallet)
+	c.App.Post("/wallets/v1", c.WalletController.CreateWallet)
+	c.App.Post("/wallets/v1/top-up", c.WalletController.TopUp)
+	c.App.Get("/wallets/v1/transactions", c.WalletController.GetTransactions)
+	c.App.Get("/wallets/v1/transactions/export", c.WalletController.ExportStatement)
+
+	// payment routes
+	c.App.Get("/payments/v1/qris/:orderId", c.PaymentController.GetQrisPayment)
 
 	// driver routes
+	c.App.Post("/drivers/v1/accept-pickup", c.DriverController.AcceptPickup)
+	c.App.Post("/drivers/v1/destination-mode", c.DriverController.SetDestinationMode)
+	c.App.Delete("/drivers/v1/destination-mode", c.DriverController.ClearDestinationMode)
 	c.App.Post("/drivers/v1/pickup-passanger", c.DriverController.PickupPassanger)
 	c.App.Post("/drivers/v1/complete-trip", c.DriverController.CompletedTrip)
+	c.App.Get("/drivers/v1/debt", c.LedgerController.GetDebt)
+	c.App.Post("/drivers/v1/debt/settle", c.LedgerController.SettleDebt)
+	c.App.Get("/drivers/v1/payouts", c.PayoutController.ListDriverPayouts)
 	// c.App.Get("/drivers/v1/detail-trip/:orderId", c.UserController.DetailTrip)
+
+	// admin routes
+	admin := c.App.Group("/admin/v1", c.AdminMiddleware)
+	admin.Get("/queues", c.QueueController.ListQueueZones)
+	admin.Get("/queues/:zoneId", c.QueueController.GetQueue)
+	admin.Delete("/queues/:zoneId/drivers/:driverId", c.QueueController.RemoveFromQueue)
+	admin.Get("/payouts", c.PayoutController.ListPayouts)
+	admin.Get("/payouts/report", c.PayoutController.PayoutReport)
+	admin.Post("/companies", c.CorporateController.CreateCompany)
+	admin.Get("/companies/:companyId", c.CorporateController.GetCompany)
+	admin.Put("/companies/:companyId/status", c.CorporateController.UpdateCompanyStatus)
+	admin.Put("/companies/:companyId/members/:userId", c.CorporateController.UpsertMember)
+	admin.Delete("/companies/:companyId/members/:userId", c.CorporateController.RemoveMember)
+	admin.Put("/companies/:companyId/policy", c.CorporateController.SetPolicy)
+	admin.Get("/companies/:companyId/invoices", c.CorporateController.ListInvoices)
+	admin.Post("/companies/:companyId/invoices", c.CorporateController.GenerateInvoice)
+	admin.Get("/invoices/:invoiceId", c.CorporateController.GetInvoice)
 }
@@ -57,6 +57,7 @@ func (c *UserController) FindDriver(ctx *fiber.Ctx) error {
 	auth := middleware.GetUser(ctx)
 	request := new(model.FindDriverRequest)
 	request.UserID = auth.UserID
+	request.Segment = auth.Segment
 	if err := ctx.BodyParser(request); err != nil {
 		c.Log.Error("UserController.FindDriver", "Failed to parse request body", "error", err.Error())
 		return utils.ResponseError(err, ctx)
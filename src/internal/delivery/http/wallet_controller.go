@@ -0,0 +1,99 @@
+package http
+
+import (
+	"fmt"
+	"order-service/src/internal/delivery/http/middleware"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WalletController struct {
+	Log     log.Log
+	UseCase *usecase.WalletUseCase
+}
+
+func NewWalletController(useCase *usecase.WalletUseCase, logger log.Log) *WalletController {
+	return &WalletController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *WalletController) GetWallet(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	result := c.UseCase.GetWallet(ctx.Context(), auth.UserID)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Get Wallet", fiber.StatusOK, ctx)
+}
+
+func (c *WalletController) CreateWallet(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	result := c.UseCase.CreateWallet(ctx.Context(), auth.UserID)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Create Wallet", fiber.StatusOK, ctx)
+}
+
+func (c *WalletController) TopUp(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.TopUpRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("WalletController.TopUp", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	if key := ctx.Get("Idempotency-Key"); key != "" {
+		request.IdempotencyKey = key
+	}
+	result := c.UseCase.TopUp(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Top Up Wallet", fiber.StatusOK, ctx)
+}
+
+func (c *WalletController) GetTransactions(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.WalletTransactionRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("WalletController.GetTransactions", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	result := c.UseCase.GetTransactions(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	page := result.Data.(model.WalletTransactionPage)
+	return utils.ResponseWithMeta(page.Transactions, page.Meta, "Wallet Transactions", fiber.StatusOK, ctx)
+}
+
+func (c *WalletController) ExportStatement(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.WalletStatementRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("WalletController.ExportStatement", "Failed to parse request query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	result := c.UseCase.ExportStatement(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	statement := result.Data.(model.WalletStatement)
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", statement.FileName))
+	return ctx.Status(fiber.StatusOK).Send(statement.Content)
+}
@@ -0,0 +1,42 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	kafkaPkgConfluent "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type PaymentConsumer struct {
+	Log     log.Log
+	UseCase *usecase.PaymentUseCase
+}
+
+func NewPaymentConsumer(useCase *usecase.PaymentUseCase, logger log.Log) *PaymentConsumer {
+	return &PaymentConsumer{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+// ConsumePaymentResult applies a payment-result event. A payload that cannot be
+// applied goes to the dead letter topic without retries.
+func (c *PaymentConsumer) ConsumePaymentResult(ctx context.Context, message *kafka.Message) error {
+	event := new(model.PaymentResultEvent)
+	if err := messaging.Decode(message.Value, event); err != nil {
+		c.Log.WithContext(ctx).Error("PaymentConsumer.ConsumePaymentResult", fmt.Sprintf("Failed to decode payment result: %v", err), "error", string(message.Key))
+		return kafkaPkgConfluent.Permanent(err)
+	}
+
+	err := c.UseCase.HandlePaymentResult(ctx, event)
+	if errors.Is(err, usecase.ErrInvalidPaymentResult) {
+		return kafkaPkgConfluent.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/model"
+	"order-service/src/internal/usecase"
+	kafkaPkgConfluent "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type TrackingConsumer struct {
+	Log     log.Log
+	UseCase *usecase.TripTrackingUseCase
+}
+
+func NewTrackingConsumer(useCase *usecase.TripTrackingUseCase, logger log.Log) *TrackingConsumer {
+	return &TrackingConsumer{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+// ConsumeTripLocation adds a driver location to the track of the trip.
+func (c *TrackingConsumer) ConsumeTripLocation(ctx context.Context, message *kafka.Message) error {
+	event := new(model.TripLocationEvent)
+	if err := messaging.Decode(message.Value, event); err != nil {
+		c.Log.WithContext(ctx).Error("TrackingConsumer.ConsumeTripLocation", fmt.Sprintf("Failed to decode trip location: %v", err), "error", string(message.Key))
+		return kafkaPkgConfluent.Permanent(err)
+	}
+
+	err := c.UseCase.TrackLocation(ctx, event)
+	if errors.Is(err, usecase.ErrInvalidTripLocation) {
+		return kafkaPkgConfluent.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package entity
+
+import "time"
+
+type Company struct {
+	ID           uint64    `db:"id"            json:"id"`
+	CompanyID    string    `db:"company_id"    json:"company_id"`
+	Name         string    `db:"name"          json:"name"`
+	BillingEmail string    `db:"billing_email" json:"billing_email"`
+	Status       string    `db:"status"        json:"status"`
+	CreatedAt    time.Time `db:"created_at"    json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"    json:"updated_at"`
+}
+
+type CompanyMember struct {
+	ID         uint64    `db:"id"          json:"id"`
+	CompanyID  string    `db:"company_id"  json:"company_id"`
+	UserID     string    `db:"user_id"     json:"user_id"`
+	CostCenter string    `db:"cost_center" json:"cost_center"`
+	Status     string    `db:"status"      json:"status"`
+	CreatedAt  time.Time `db:"created_at"  json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"  json:"updated_at"`
+}
+
+// CompanyPolicy limits when, where and how much members ride on the company
+// account. A nil field is not enforced, times are HH:MM in the corporate timezone,
+// weekdays is a comma separated list of 0 (Sunday) to 6 and allowed zones is a
+// JSON array of dispatch zones.
+type CompanyPolicy struct {
+	ID           uint64    `db:"id"            json:"id"`
+	CompanyID    string    `db:"company_id"    json:"company_id"`
+	StartTime    *string   `db:"start_time"    json:"start_time,omitempty"`
+	EndTime      *string   `db:"end_time"      json:"end_time,omitempty"`
+	Weekdays     *string   `db:"weekdays"      json:"weekdays,omitempty"`
+	AllowedZones *string   `db:"allowed_zones" json:"allowed_zones,omitempty"`
+	MaxFare      *float64  `db:"max_fare"      json:"max_fare,omitempty"`
+	CreatedAt    time.Time `db:"created_at"    json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"    json:"updated_at"`
+}
+
+// CorporateMembership is an active member of an active company with the policy of
+// the company, if any.
+type CorporateMembership struct {
+	Member  CompanyMember
+	Company Company
+	Policy  *CompanyPolicy
+}
+
+type CorporateInvoice struct {
+	ID          uint64    `db:"id"           json:"id"`
+	InvoiceID   string    `db:"invoice_id"   json:"invoice_id"`
+	CompanyID   string    `db:"company_id"   json:"company_id"`
+	Period      string    `db:"period"       json:"period"`
+	TripCount   int       `db:"trip_count"   json:"trip_count"`
+	TotalAmount float64   `db:"total_amount" json:"total_amount"`
+	Status      string    `db:"status"       json:"status"`
+	IssuedAt    time.Time `db:"issued_at"    json:"issued_at"`
+	DueAt       time.Time `db:"due_at"       json:"due_at"`
+	CreatedAt   time.Time `db:"created_at"   json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"   json:"updated_at"`
+}
+
+type CorporateInvoiceItem struct {
+	ID          uint64    `db:"id"           json:"id"`
+	InvoiceID   string    `db:"invoice_id"   json:"invoice_id"`
+	OrderID     string    `db:"order_id"     json:"order_id"`
+	PassengerID string    `db:"passenger_id" json:"passenger_id"`
+	CostCenter  string    `db:"cost_center"  json:"cost_center"`
+	Amount      float64   `db:"amount"       json:"amount"`
+	CompletedAt time.Time `db:"completed_at" json:"completed_at"`
+	CreatedAt   time.Time `db:"created_at"   json:"created_at"`
+}
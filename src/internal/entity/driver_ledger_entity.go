@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+type DriverLedgerEntry struct {
+	ID            uint64    `db:"id"             json:"id"`
+	EntryID       string    `db:"entry_id"       json:"entry_id"`
+	DriverID      string    `db:"driver_id"      json:"driver_id"`
+	OrderID       *string   `db:"order_id"       json:"order_id,omitempty"`
+	EntryType     string    `db:"entry_type"     json:"entry_type"`
+	Fare          float64   `db:"fare"           json:"fare"`
+	CashCollected float64   `db:"cash_collected" json:"cash_collected"`
+	Amount        float64   `db:"amount"         json:"amount"`
+	DebtAfter     float64   `db:"debt_after"     json:"debt_after"`
+	Description   *string   `db:"description"    json:"description,omitempty"`
+	CreatedAt     time.Time `db:"created_at"     json:"created_at"`
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type OrderDetail struct {
-	ID                 uint64  `db:"order_id"`
+	ID                 uint64  `db:"id"`
 	OrderID            string  `db:"order_id"`
 	PassengerID        string  `db:"passenger_id"`
 	DriverID           *string `db:"driver_id"`
@@ -16,21 +16,31 @@ type OrderDetail struct {
 	OriginAddress      string  `db:"origin_address"`
 	DestinationAddress string  `db:"destination_address"`
 
-	MinPrice          float64 `db:"min_price"`
-	MaxPrice          float64 `db:"max_price"`
-	BestRouteKm       float64 `db:"best_route_km"`
-	BestRoutePrice    float64 `db:"best_route_price"`
-	BestRouteDuration string  `db:"best_route_duration"`
+	MinPrice          float64  `db:"min_price"`
+	MaxPrice          float64  `db:"max_price"`
+	BestRouteKm       float64  `db:"best_route_km"`
+	BestRoutePrice    float64  `db:"best_route_price"`
+	BestRouteDuration string   `db:"best_route_duration"`
+	FinalFare         *float64 `db:"final_fare"`
+	DistanceActual    *float64 `db:"distance_actual"`
+	DurationActual    *string  `db:"duration_actual"`
+	RideType          string   `db:"ride_type"`
+	SharedDiscount    *float64 `db:"shared_discount"`
 
-	Status        string    `db:"status"`
-	PaymentMethod string    `db:"payment_method"`
-	PaymentStatus string    `db:"payment_status"`
-	CreatedAt     time.Time `db:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at"`
+	Status              string     `db:"status"`
+	PaymentMethod       string     `db:"payment_method"`
+	PaymentStatus       string     `db:"payment_status"`
+	CompanyID           *string    `db:"company_id"`
+	CostCenter          *string    `db:"cost_center"`
+	WalletTransactionID *string    `db:"wallet_transaction_id"`
+	WalletCaptured      *float64   `db:"wallet_captured_amount"`
+	CompletedAt         *time.Time `db:"completed_at"`
+	CreatedAt           time.Time  `db:"created_at"`
+	UpdatedAt           time.Time  `db:"updated_at"`
 
-	Payment PaymentDetail `json:"payment,omitempty"`
+	PaymentDetail `json:"payment,omitempty"`
 
-	Promo PromoDetail `json:"promo,omitempty"`
+	PromoDetail `json:"promo,omitempty"`
 }
 
 type Order struct {
@@ -49,9 +59,15 @@ type Order struct {
 	BestRouteKm        float64   `db:"best_route_km"       json:"best_route_km"`
 	BestRoutePrice     float64   `db:"best_route_price"    json:"best_route_price"`
 	BestRouteDuration  string    `db:"best_route_duration" json:"best_route_duration"`
+	FinalFare          *float64  `db:"final_fare"          json:"final_fare,omitempty"`
 	Status             string    `db:"status"              json:"status"`
 	PaymentMethod      string    `db:"payment_method"      json:"payment_method"`
 	PaymentStatus      string    `db:"payment_status"      json:"payment_status"`
+	CompanyID          *string   `db:"company_id"          json:"company_id,omitempty"`
+	CostCenter         *string   `db:"cost_center"         json:"cost_center,omitempty"`
+	DispatchMode       string    `db:"dispatch_mode"       json:"dispatch_mode"`
+	RideType           string    `db:"ride_type"           json:"ride_type"`
+	SharedGroupID      *string   `db:"shared_group_id"     json:"shared_group_id,omitempty"`
 	EstimatedFare      *float64  `db:"estimated_fare"      json:"estimated_fare,omitempty"`
 	DistanceKm         *float64  `db:"distance_km"         json:"distance_km,omitempty"`
 	DistanceActual     *float64  `db:"distance_actual"     json:"distance_actual,omitempty"`
@@ -69,6 +85,8 @@ type OrderFilter struct {
 	StatusNot     *string
 	StatusIn      []string
 	PaymentStatus *string
+	DispatchMode  *string
+	SharedGroupID *string
 }
 
 type PaymentDetail struct {
@@ -108,6 +126,10 @@ type CreateOrder struct {
 	Status             string   `json:"status,omitempty"`
 	PaymentMethod      string   `json:"payment_method,omitempty"`
 	PaymentStatus      string   `json:"payment_status,omitempty"`
+	CompanyID          *string  `json:"company_id,omitempty"`
+	CostCenter         *string  `json:"cost_center,omitempty"`
+	DispatchMode       string   `json:"dispatch_mode,omitempty"`
+	RideType           string   `json:"ride_type,omitempty"`
 	EstimatedFare      *float64 `json:"estimated_fare,omitempty"`
 	DistanceKm         *float64 `json:"distance_km,omitempty"`
 	DistanceActual     *float64 `json:"distance_actual,omitempty"`
@@ -133,4 +155,20 @@ type UpdateOrderRequest struct {
 	Status             string
 	PaymentMethod      string
 	PaymentStatus      string
+	CompanyID          *string
+	CostCenter         *string
+	DispatchMode       string
+	RideType           string
+}
+
+// TripTrack is the path a driver drove for a completed trip, Path holds the
+// points as a JSON array.
+type TripTrack struct {
+	OrderID    string    `db:"order_id"    json:"order_id"`
+	DriverID   string    `db:"driver_id"   json:"driver_id"`
+	DistanceKm float64   `db:"distance_km" json:"distance_km"`
+	PointCount int       `db:"point_count" json:"point_count"`
+	Path       string    `db:"path"        json:"path"`
+	StartedAt  time.Time `db:"started_at"  json:"started_at"`
+	EndedAt    time.Time `db:"ended_at"    json:"ended_at"`
 }
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// OutboxMessage is an event written with the change it announces, the relay
+// publishes it to Kafka once the transaction commits. Headers holds the Kafka
+// headers of the message as a JSON object.
+type OutboxMessage struct {
+	ID          uint64     `db:"id"           json:"id"`
+	Topic       string     `db:"topic"        json:"topic"`
+	MessageKey  string     `db:"message_key"  json:"message_key"`
+	Payload     []byte     `db:"payload"      json:"payload"`
+	Headers     []byte     `db:"headers"      json:"headers,omitempty"`
+	Status      string     `db:"status"       json:"status"`
+	Attempts    int        `db:"attempts"     json:"attempts"`
+	LastError   *string    `db:"last_error"   json:"last_error,omitempty"`
+	AvailableAt time.Time  `db:"available_at" json:"available_at"`
+	SentAt      *time.Time `db:"sent_at"      json:"sent_at,omitempty"`
+	CreatedAt   time.Time  `db:"created_at"   json:"created_at"`
+}
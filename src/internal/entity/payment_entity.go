@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// PaymentCollection is an order whose fare could not be debited, it stays OPEN
+// until the passenger pays it.
+type PaymentCollection struct {
+	ID                   uint64    `db:"id"                     json:"id"`
+	OrderID              string    `db:"order_id"               json:"order_id"`
+	PassengerID          string    `db:"passenger_id"           json:"passenger_id"`
+	Amount               float64   `db:"amount"                 json:"amount"`
+	Reason               string    `db:"reason"                 json:"reason"`
+	Status               string    `db:"status"                 json:"status"`
+	PaymentTransactionID *uint64   `db:"payment_transaction_id" json:"payment_transaction_id,omitempty"`
+	CreatedAt            time.Time `db:"created_at"             json:"created_at"`
+	UpdatedAt            time.Time `db:"updated_at"             json:"updated_at"`
+}
@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+type PayoutBatch struct {
+	ID               uint64     `db:"id"                json:"id"`
+	BatchID          string     `db:"batch_id"          json:"batch_id"`
+	DriverID         string     `db:"driver_id"         json:"driver_id"`
+	PayoutDate       time.Time  `db:"payout_date"       json:"payout_date"`
+	TripCount        int        `db:"trip_count"        json:"trip_count"`
+	GrossAmount      float64    `db:"gross_amount"      json:"gross_amount"`
+	CommissionAmount float64    `db:"commission_amount" json:"commission_amount"`
+	NetAmount        float64    `db:"net_amount"        json:"net_amount"`
+	Status           string     `db:"status"            json:"status"`
+	Attempts         int        `db:"attempts"          json:"attempts"`
+	FailureReason    *string    `db:"failure_reason"    json:"failure_reason,omitempty"`
+	PaidAt           *time.Time `db:"paid_at"           json:"paid_at,omitempty"`
+	CreatedAt        time.Time  `db:"created_at"        json:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at"        json:"updated_at"`
+}
+
+type PayoutItem struct {
+	ID             uint64    `db:"id"              json:"id"`
+	BatchID        string    `db:"batch_id"        json:"batch_id"`
+	OrderID        string    `db:"order_id"        json:"order_id"`
+	PaymentMethod  string    `db:"payment_method"  json:"payment_method"`
+	City           string    `db:"city"            json:"city"`
+	VehicleType    string    `db:"vehicle_type"    json:"vehicle_type"`
+	Fare           float64   `db:"fare"            json:"fare"`
+	CommissionRate float64   `db:"commission_rate" json:"commission_rate"`
+	Commission     float64   `db:"commission"      json:"commission"`
+	Earning        float64   `db:"earning"         json:"earning"`
+	CompletedAt    time.Time `db:"completed_at"    json:"completed_at"`
+	CreatedAt      time.Time `db:"created_at"      json:"created_at"`
+}
+
+// PayableOrder is a completed and paid order not settled to its driver yet.
+type PayableOrder struct {
+	OrderID       string    `db:"order_id"`
+	DriverID      string    `db:"driver_id"`
+	PaymentMethod string    `db:"payment_method"`
+	City          string    `db:"city"`
+	VehicleType   string    `db:"vehicle_type"`
+	Fare          float64   `db:"fare"`
+	CompletedAt   time.Time `db:"completed_at"`
+}
+
+type PayoutFilter struct {
+	DriverID *string
+	Status   *string
+	From     *time.Time
+	To       *time.Time
+	Limit    int64
+	Offset   int64
+}
+
+type PayoutReportRow struct {
+	PayoutDate       time.Time `db:"payout_date"`
+	Status           string    `db:"status"`
+	Batches          int       `db:"batches"`
+	TripCount        int       `db:"trip_count"`
+	GrossAmount      float64   `db:"gross_amount"`
+	CommissionAmount float64   `db:"commission_amount"`
+	NetAmount        float64   `db:"net_amount"`
+}
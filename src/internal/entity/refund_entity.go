@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+type Refund struct {
+	ID                   uint64    `db:"id"                     json:"id"`
+	RefundID             string    `db:"refund_id"              json:"refund_id"`
+	OrderID              string    `db:"order_id"               json:"order_id"`
+	PassengerID          string    `db:"passenger_id"           json:"passenger_id"`
+	PaymentMethod        string    `db:"payment_method"         json:"payment_method"`
+	PaymentTransactionID *uint64   `db:"payment_transaction_id" json:"payment_transaction_id,omitempty"`
+	Stage                string    `db:"stage"                  json:"stage"`
+	Reason               string    `db:"reason"                 json:"reason"`
+	FeeAmount            float64   `db:"fee_amount"             json:"fee_amount"`
+	Amount               float64   `db:"amount"                 json:"amount"`
+	Status               string    `db:"status"                 json:"status"`
+	Attempts             int       `db:"attempts"               json:"attempts"`
+	ProviderReference    *string   `db:"provider_reference"     json:"provider_reference,omitempty"`
+	LastError            *string   `db:"last_error"             json:"last_error,omitempty"`
+	CreatedAt            time.Time `db:"created_at"             json:"created_at"`
+	UpdatedAt            time.Time `db:"updated_at"             json:"updated_at"`
+}
+
+type PaymentTransaction struct {
+	ID                  uint64     `db:"id"                    json:"id"`
+	RideOrderID         string     `db:"ride_order_id"         json:"ride_order_id"`
+	Amount              float64    `db:"amount"                json:"amount"`
+	PaymentStatus       string     `db:"payment_status"        json:"payment_status"`
+	ProviderName        *string    `db:"provider_name"         json:"provider_name,omitempty"`
+	ProviderReferenceID *string    `db:"provider_reference_id" json:"provider_reference_id,omitempty"`
+	QRPayload           *string    `db:"qr_payload"            json:"qr_payload,omitempty"`
+	ExpiresAt           *time.Time `db:"expires_at"            json:"expires_at,omitempty"`
+	PaidAt              *time.Time `db:"paid_at"               json:"paid_at,omitempty"`
+}
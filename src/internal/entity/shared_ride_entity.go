@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+type SharedRideGroup struct {
+	ID         uint64    `db:"id"          json:"id"`
+	GroupID    string    `db:"group_id"    json:"group_id"`
+	DriverID   string    `db:"driver_id"   json:"driver_id"`
+	SeatsTotal int       `db:"seats_total" json:"seats_total"`
+	SeatsTaken int       `db:"seats_taken" json:"seats_taken"`
+	Status     string    `db:"status"      json:"status"`
+	CreatedAt  time.Time `db:"created_at"  json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"  json:"updated_at"`
+}
+
+type OrderFareSplit struct {
+	ID             uint64    `db:"id"              json:"id"`
+	OrderID        string    `db:"order_id"        json:"order_id"`
+	GroupID        *string   `db:"group_id"        json:"group_id,omitempty"`
+	BaseFare       float64   `db:"base_fare"       json:"base_fare"`
+	DiscountAmount float64   `db:"discount_amount" json:"discount_amount"`
+	Fare           float64   `db:"fare"            json:"fare"`
+	CreatedAt      time.Time `db:"created_at"      json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"      json:"updated_at"`
+}
@@ -6,6 +6,7 @@ type Wallet struct {
 	ID          string    `db:"id"        json:"id"`
 	UserID      string    `db:"user_id"   json:"user_id"`
 	Balance     float64   `db:"balance"   json:"balance"`
+	HeldBalance float64   `db:"held_balance" json:"held_balance"`
 	LastUpdated time.Time `db:"last_updated" json:"last_updated"`
 	CreatedAt   time.Time `db:"created_at"   json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"   json:"updated_at"`
@@ -15,9 +16,31 @@ type WalletTransaction struct {
 	ID            uint64    `db:"id"             json:"id"`
 	WalletID      string    `db:"wallet_id"      json:"wallet_id"`
 	TransactionID string    `db:"transaction_id" json:"transaction_id"`
+	OrderID       *string   `db:"order_id"       json:"order_id,omitempty"`
 	Amount        float64   `db:"amount"         json:"amount"`
 	Type          string    `db:"type"           json:"type"`
 	Description   string    `db:"description"    json:"description"`
 	Timestamp     time.Time `db:"timestamp"      json:"timestamp"`
 	CreatedAt     time.Time `db:"created_at"     json:"created_at"`
 }
+
+type WalletHold struct {
+	ID             uint64    `db:"id"              json:"id"`
+	OrderID        string    `db:"order_id"        json:"order_id"`
+	WalletID       string    `db:"wallet_id"       json:"wallet_id"`
+	Amount         float64   `db:"amount"          json:"amount"`
+	CapturedAmount float64   `db:"captured_amount" json:"captured_amount"`
+	ReleasedAmount float64   `db:"released_amount" json:"released_amount"`
+	Status         string    `db:"status"          json:"status"`
+	CreatedAt      time.Time `db:"created_at"      json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"      json:"updated_at"`
+}
+
+type WalletTransactionFilter struct {
+	UserID string
+	Types  []string
+	From   *time.Time
+	To     *time.Time
+	Limit  int64
+	Offset int64
+}
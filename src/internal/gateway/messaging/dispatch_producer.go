@@ -0,0 +1,28 @@
+package messaging
+
+import (
+	"context"
+	"order-service/src/internal/model"
+	kafka "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+)
+
+type DispatchProducer struct {
+	DriverOfferProducer Producer[*model.DriverOfferEvent]
+}
+
+func NewDispatchProducer(producer kafka.Producer, serializer Serializer, log log.Log) *DispatchProducer {
+	return &DispatchProducer{
+		DriverOfferProducer: Producer[*model.DriverOfferEvent]{
+			Producer:   producer,
+			Topic:      "driver-offer",
+			Type:       EventDriverOffer,
+			Serializer: serializer,
+			Log:        log,
+		},
+	}
+}
+
+func (u *DispatchProducer) SendDriverOffer(ctx context.Context, event *model.DriverOfferEvent) error {
+	return u.DriverOfferProducer.Send(ctx, event)
+}
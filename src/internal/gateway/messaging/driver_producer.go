@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"context"
+	"order-service/src/internal/entity"
 	"order-service/src/internal/model"
 	kafka "order-service/src/pkg/kafka/confluent"
 	"order-service/src/pkg/log"
@@ -12,25 +14,29 @@ type DriverProducer struct {
 	Producer[*model.OrderEvent]
 }
 
-func NewDriverProducer(producer kafka.Producer, log log.Log) *DriverProducer {
+func NewDriverProducer(producer kafka.Producer, serializer Serializer, log log.Log) *DriverProducer {
 	return &DriverProducer{
 		DriverPickupProducer: Producer[*model.OrderEvent]{
-			Producer: producer,
-			Topic:    "trip-created",
-			Log:      log,
+			Producer:   producer,
+			Topic:      "trip-created",
+			Type:       EventTripCreated,
+			Serializer: serializer,
+			Log:        log,
 		},
 		DriverUpdateProducer: Producer[*model.NotificationUser]{
-			Producer: producer,
-			Topic:    "order-driver-request-pickup",
-			Log:      log,
+			Producer:   producer,
+			Topic:      "order-driver-request-pickup",
+			Type:       EventOrderCompleted,
+			Serializer: serializer,
+			Log:        log,
 		},
 	}
 }
 
-func (u *DriverProducer) SendRequestRide(event *model.OrderEvent) error {
-	return u.DriverPickupProducer.Send(event)
+func (u *DriverProducer) SendRequestRide(ctx context.Context, event *model.OrderEvent) error {
+	return u.DriverPickupProducer.Send(ctx, event)
 }
 
-func (u *DriverProducer) SendOrderCompleted(event *model.NotificationUser) error {
-	return u.DriverUpdateProducer.Send(event)
+func (u *DriverProducer) OutboxOrderCompleted(ctx context.Context, event *model.NotificationUser) (*entity.OutboxMessage, error) {
+	return u.DriverUpdateProducer.Outbox(ctx, event)
 }
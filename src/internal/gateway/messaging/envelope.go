@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"order-service/src/pkg/schemaregistry"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SpecVersion     = "1.0"
+	EventSource     = "nebengjek/order-service"
+	DataContentType = "application/json"
+)
+
+// Envelope wraps every payload published by the service with the CloudEvents
+// attributes in structured mode. The payload keeps its own field naming, the
+// envelope is what consumers rely on to route and version events.
+type Envelope struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	SpecVersion     string          `json:"specversion"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	SchemaVersion   int             `json:"schemaversion"`
+	Subject         string          `json:"subject,omitempty"`
+	Data            json.RawMessage `json:"data" avro:"-"`
+}
+
+// wrap encodes the payload of an event type in its envelope for the topic, the
+// subject is the message key. A nil serializer writes JSON.
+func wrap(serializer Serializer, topic, eventType, subject string, payload interface{}) ([]byte, error) {
+	version, ok := SchemaVersion(eventType)
+	if !ok {
+		return nil, fmt.Errorf("event type %q has no registered schema", eventType)
+	}
+	if serializer == nil {
+		serializer = NewJSONSerializer()
+	}
+	return serializer.Serialize(topic, &Envelope{
+		ID:              uuid.NewString(),
+		Type:            eventType,
+		Source:          EventSource,
+		SpecVersion:     SpecVersion,
+		Time:            time.Now().UTC(),
+		DataContentType: serializer.ContentType(),
+		DataSchema:      dataSchema(eventType, version),
+		SchemaVersion:   version,
+		Subject:         subject,
+	}, payload)
+}
+
+// Unwrap decodes an enveloped message into data and returns the envelope. Fields
+// added by a newer schema version than the consumer knows are ignored.
+func Unwrap(value []byte, data interface{}) (*Envelope, error) {
+	if _, _, err := schemaregistry.Decode(value); err == nil {
+		return nil, fmt.Errorf("message is Avro encoded, decode it with the schema registry")
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.SpecVersion == "" || envelope.Type == "" {
+		return nil, fmt.Errorf("message is not an event envelope")
+	}
+	if data != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			return &envelope, err
+		}
+	}
+	return &envelope, nil
+}
+
+func dataSchema(eventType string, version int) string {
+	return fmt.Sprintf("%s/schemas/%s/v%d", EventSource, eventType, version)
+}
+
+// Decode reads an inbound event into data, whether its producer wraps it in an
+// envelope or publishes the bare JSON payload.
+func Decode(value []byte, data interface{}) error {
+	envelope, err := Unwrap(value, data)
+	if envelope != nil {
+		return err
+	}
+	if _, _, errFramed := schemaregistry.Decode(value); errFramed == nil {
+		return err
+	}
+	return json.Unmarshal(value, data)
+}
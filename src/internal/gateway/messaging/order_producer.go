@@ -0,0 +1,29 @@
+package messaging
+
+import (
+	"context"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	kafka "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+)
+
+type OrderProducer struct {
+	Producer[*model.OrderLifecycleEvent]
+}
+
+func NewOrderProducer(producer kafka.Producer, serializer Serializer, log log.Log) *OrderProducer {
+	return &OrderProducer{
+		Producer: Producer[*model.OrderLifecycleEvent]{
+			Producer:   producer,
+			Topic:      "order-events",
+			Type:       EventOrderLifecycle,
+			Serializer: serializer,
+			Log:        log,
+		},
+	}
+}
+
+func (p *OrderProducer) OutboxOrderEvent(ctx context.Context, event *model.OrderLifecycleEvent) (*entity.OutboxMessage, error) {
+	return p.Outbox(ctx, event)
+}
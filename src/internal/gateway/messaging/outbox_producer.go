@@ -0,0 +1,34 @@
+package messaging
+
+import (
+	"encoding/json"
+	"order-service/src/internal/entity"
+	kafka "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+)
+
+// OutboxProducer publishes the messages relayed from the outbox as they were
+// encoded when written.
+type OutboxProducer struct {
+	Producer kafka.Producer
+	Log      log.Log
+}
+
+func NewOutboxProducer(producer kafka.Producer, log log.Log) *OutboxProducer {
+	return &OutboxProducer{
+		Producer: producer,
+		Log:      log,
+	}
+}
+
+// Relay publishes the message with the headers it was written with, messages
+// written before they kept headers go out without.
+func (p *OutboxProducer) Relay(message *entity.OutboxMessage) error {
+	var headers map[string]string
+	if len(message.Headers) > 0 {
+		if err := json.Unmarshal(message.Headers, &headers); err != nil {
+			p.Log.Error("gateway/messaging/producer", "invalid outbox message headers", "Relay", err.Error())
+		}
+	}
+	return publish(p.Producer, p.Log, message.Topic, message.MessageKey, message.Payload, headers)
+}
@@ -1,10 +1,14 @@
 package messaging
 
 import (
+	"context"
 	"encoding/json"
+	"order-service/src/internal/entity"
 	"order-service/src/internal/model"
 	kafka "order-service/src/pkg/kafka/confluent"
 	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
+	"sort"
 
 	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
@@ -12,36 +16,96 @@ import (
 type Producer[T model.Event] struct {
 	Producer kafka.Producer
 	Topic    string
-	Log      log.Log
+	// Type is the CloudEvents type of the payload, see eventSchemas
+	Type       string
+	Serializer Serializer
+	Log        log.Log
 }
 
 func (p *Producer[T]) GetTopic() *string {
 	return &p.Topic
 }
 
-func (p *Producer[T]) SendTo(topic string, event T) error {
-	value, err := json.Marshal(event)
+// SendTo publishes the event with the request id and trace context of ctx in
+// its headers.
+func (p *Producer[T]) SendTo(ctx context.Context, topic string, event T) error {
+	value, err := wrap(p.Serializer, topic, p.Type, event.GetId(), event)
 	if err != nil {
-		p.Log.Error("gateway/messaging/producer", "failed to marshal event", "SendTo", err.Error())
+		p.Log.WithContext(ctx).Error("gateway/messaging/producer", "failed to marshal event", "SendTo", err.Error())
 		return err
 	}
 
+	return publish(p.Producer, p.Log, topic, event.GetId(), value, traceHeaders(ctx))
+}
+
+func (p *Producer[T]) Send(ctx context.Context, event T) error {
+	return p.SendTo(ctx, p.Topic, event)
+}
+
+// Outbox encodes the event for the topic of the producer as an outbox message,
+// the relay publishes it once the transaction writing it commits. The trace
+// headers of ctx are kept with it.
+func (p *Producer[T]) Outbox(ctx context.Context, event T) (*entity.OutboxMessage, error) {
+	value, err := wrap(p.Serializer, p.Topic, p.Type, event.GetId(), event)
+	if err != nil {
+		p.Log.WithContext(ctx).Error("gateway/messaging/producer", "failed to marshal event", "Outbox", err.Error())
+		return nil, err
+	}
+	headers, err := json.Marshal(traceHeaders(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.OutboxMessage{
+		Topic:      p.Topic,
+		MessageKey: event.GetId(),
+		Payload:    value,
+		Headers:    headers,
+	}, nil
+}
+
+// traceHeaders are the headers of a message published in a new span of the
+// trace of ctx, a new trace when ctx carries none.
+func traceHeaders(ctx context.Context) map[string]string {
+	trace, ok := tracing.FromContext(ctx)
+	if ok {
+		trace = trace.Child()
+	} else {
+		trace = tracing.New()
+	}
+	message := &k.Message{}
+	kafka.InjectTrace(message, trace)
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return headers
+}
+
+func publish(producer kafka.Producer, logger log.Log, topic, key string, value []byte, headers map[string]string) error {
 	message := &k.Message{
 		TopicPartition: k.TopicPartition{Topic: &topic, Partition: k.PartitionAny},
-		Key:            []byte(event.GetId()),
+		Key:            []byte(key),
 		Value:          value,
 	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		message.Headers = append(message.Headers, k.Header{Key: name, Value: []byte(headers[name])})
+	}
+	if trace, ok := kafka.MessageTrace(message); ok {
+		logger = logger.WithContext(tracing.WithTrace(context.Background(), trace))
+	}
 
-	err = p.Producer.Publish(message)
+	err := producer.Publish(message)
 	if err != nil {
-		p.Log.Error("gateway/messaging/producer", "error send message", "SendTo", err.Error())
+		logger.Error("gateway/messaging/producer", "error send message", "SendTo", err.Error())
 		return err
 	}
 
-	p.Log.Info("gateway/messaging/producer", "event published successfully", topic, event.GetId())
+	logger.Info("gateway/messaging/producer", "event published successfully", topic, key)
 	return nil
 }
-
-func (p *Producer[T]) Send(event T) error {
-	return p.SendTo(p.Topic, event)
-}
@@ -0,0 +1,28 @@
+package messaging
+
+import (
+	"context"
+	"order-service/src/internal/model"
+	kafka "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+)
+
+type ReceiptProducer struct {
+	Producer[*model.ReceiptReadyEvent]
+}
+
+func NewReceiptProducer(producer kafka.Producer, serializer Serializer, log log.Log) *ReceiptProducer {
+	return &ReceiptProducer{
+		Producer: Producer[*model.ReceiptReadyEvent]{
+			Producer:   producer,
+			Topic:      "receipt-ready",
+			Type:       EventReceiptReady,
+			Serializer: serializer,
+			Log:        log,
+		},
+	}
+}
+
+func (p *ReceiptProducer) SendReceiptReady(ctx context.Context, event *model.ReceiptReadyEvent) error {
+	return p.Send(ctx, event)
+}
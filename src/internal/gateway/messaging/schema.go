@@ -0,0 +1,188 @@
+package messaging
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"order-service/src/internal/model"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event types published by the service.
+const (
+	EventRequestRide    = "nebengjek.order.request-ride"
+	EventDriverMatch    = "nebengjek.order.driver-match"
+	EventTripCreated    = "nebengjek.order.trip-created"
+	EventOrderCompleted = "nebengjek.order.completed"
+	EventDriverOffer    = "nebengjek.order.driver-offer"
+	EventReceiptReady   = "nebengjek.order.receipt-ready"
+	EventOrderLifecycle = "nebengjek.order.lifecycle"
+)
+
+type eventSchema struct {
+	// Version is the schema version produced, every version up to it has a
+	// snapshot in schemas/<type>/v<version>.json
+	Version int
+	Sample  interface{}
+}
+
+// eventSchemas is the schema version of every event type. A payload change needs
+// a new version and snapshot, and a new version may only add fields so consumers
+// of an older version keep reading it.
+var eventSchemas = map[string]eventSchema{
+	EventRequestRide:    {Version: 1, Sample: model.UserEvent{}},
+	EventDriverMatch:    {Version: 1, Sample: model.DriverMatchEvent{}},
+	EventTripCreated:    {Version: 1, Sample: model.OrderEvent{}},
+	EventOrderCompleted: {Version: 2, Sample: model.NotificationUser{}},
+	EventDriverOffer:    {Version: 1, Sample: model.DriverOfferEvent{}},
+	EventReceiptReady:   {Version: 1, Sample: model.ReceiptReadyEvent{}},
+	EventOrderLifecycle: {Version: 1, Sample: model.OrderLifecycleEvent{}},
+}
+
+//go:embed schemas
+var schemaSnapshots embed.FS
+
+// SchemaVersion returns the version produced for the event type.
+func SchemaVersion(eventType string) (int, bool) {
+	schema, ok := eventSchemas[eventType]
+	return schema.Version, ok
+}
+
+// CheckSchemas verifies every event type against its snapshots: the payload
+// matches the snapshot of the version produced, and no version drops or retypes
+// a field of the version before it.
+func CheckSchemas() error {
+	return checkSchemas(schemaSnapshots, eventSchemas)
+}
+
+func checkSchemas(snapshots fs.FS, schemas map[string]eventSchema) error {
+	var errs []error
+	types := make([]string, 0, len(schemas))
+	for eventType := range schemas {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+
+	for _, eventType := range types {
+		schema := schemas[eventType]
+		var previous map[string]string
+		for version := 1; version <= schema.Version; version++ {
+			snapshot, err := loadSnapshot(snapshots, eventType, version)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s v%d: %w", eventType, version, err))
+				break
+			}
+			for field, kind := range previous {
+				if snapshot[field] != kind {
+					errs = append(errs, fmt.Errorf("%s v%d removes or retypes field %q of v%d", eventType, version, field, version-1))
+				}
+			}
+			previous = snapshot
+		}
+		if previous == nil {
+			continue
+		}
+		if current := SchemaOf(schema.Sample); !reflect.DeepEqual(current, previous) {
+			errs = append(errs, fmt.Errorf("%s payload differs from the v%d snapshot, add version %d with its snapshot", eventType, schema.Version, schema.Version+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadSnapshot(snapshots fs.FS, eventType string, version int) (map[string]string, error) {
+	raw, err := fs.ReadFile(snapshots, fmt.Sprintf("schemas/%s/v%d.json", eventType, version))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// SchemaOf lists the JSON fields of a payload with their JSON kind, nested fields
+// are joined with a dot and array elements marked with [].
+func SchemaOf(sample interface{}) map[string]string {
+	fields := make(map[string]string)
+	collectFields(reflect.TypeOf(sample), "", fields)
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func collectFields(t reflect.Type, prefix string, fields map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			collectFields(field.Type, prefix, fields)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		path := prefix + name
+		fields[path] = jsonKind(field.Type)
+		switch elem := indirect(field.Type); elem.Kind() {
+		case reflect.Struct:
+			collectFields(elem, path+".", fields)
+		case reflect.Slice, reflect.Array:
+			if elem.Elem().Kind() != reflect.Uint8 {
+				collectFields(elem.Elem(), path+"[].", fields)
+			}
+		}
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func jsonKind(t reflect.Type) string {
+	t = indirect(t)
+	if t == timeType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return "string"
+		}
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "any"
+	}
+}
@@ -0,0 +1,305 @@
+package messaging
+
+import (
+	"context"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/log"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// quietLog drops every entry, its level is above ERROR.
+var quietLog = log.Log{LogLevel: 3}
+
+type captureProducer struct {
+	messages []*k.Message
+}
+
+func (p *captureProducer) Publish(message *k.Message) error {
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func (p *captureProducer) PublishChannel(topic string, message []byte) {}
+
+func TestCheckSchemas(t *testing.T) {
+	if err := CheckSchemas(); err != nil {
+		t.Fatalf("CheckSchemas() = %v", err)
+	}
+}
+
+// TestProducerPayloadsMatchSchemas publishes a fully populated event through
+// every producer and compares the fields of the data it writes with the
+// snapshot of the version in its envelope.
+func TestProducerPayloadsMatchSchemas(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		eventType string
+		publish   func(producer *captureProducer) ([]byte, error)
+	}{
+		{
+			eventType: EventRequestRide,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				message, err := NewUserProducer(producer, nil, quietLog).OutboxRequestRide(ctx, filled(&model.UserEvent{}))
+				return outboxPayload(message, err)
+			},
+		},
+		{
+			eventType: EventDriverMatch,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				message, err := NewUserProducer(producer, nil, quietLog).OutboxDriverMatch(ctx, filled(&model.DriverMatchEvent{}))
+				return outboxPayload(message, err)
+			},
+		},
+		{
+			eventType: EventTripCreated,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				err := NewDriverProducer(producer, nil, quietLog).SendRequestRide(ctx, filled(&model.OrderEvent{}))
+				return producer.last(err)
+			},
+		},
+		{
+			eventType: EventOrderCompleted,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				message, err := NewDriverProducer(producer, nil, quietLog).OutboxOrderCompleted(ctx, filled(&model.NotificationUser{}))
+				return outboxPayload(message, err)
+			},
+		},
+		{
+			eventType: EventDriverOffer,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				err := NewDispatchProducer(producer, nil, quietLog).SendDriverOffer(ctx, filled(&model.DriverOfferEvent{}))
+				return producer.last(err)
+			},
+		},
+		{
+			eventType: EventReceiptReady,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				err := NewReceiptProducer(producer, nil, quietLog).SendReceiptReady(ctx, filled(&model.ReceiptReadyEvent{}))
+				return producer.last(err)
+			},
+		},
+		{
+			eventType: EventOrderLifecycle,
+			publish: func(producer *captureProducer) ([]byte, error) {
+				message, err := NewOrderProducer(producer, nil, quietLog).OutboxOrderEvent(ctx, filled(&model.OrderLifecycleEvent{}))
+				return outboxPayload(message, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			value, err := tt.publish(&captureProducer{})
+			if err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			var data interface{}
+			envelope, err := Unwrap(value, &data)
+			if err != nil {
+				t.Fatalf("Unwrap() = %v", err)
+			}
+			if envelope.Type != tt.eventType {
+				t.Fatalf("envelope type = %q, want %q", envelope.Type, tt.eventType)
+			}
+			if want, _ := SchemaVersion(tt.eventType); envelope.SchemaVersion != want {
+				t.Fatalf("envelope schema version = %d, want %d", envelope.SchemaVersion, want)
+			}
+			snapshot, err := loadSnapshot(schemaSnapshots, tt.eventType, envelope.SchemaVersion)
+			if err != nil {
+				t.Fatalf("loadSnapshot() = %v", err)
+			}
+
+			got := make(map[string]string)
+			jsonFields(data, "", got)
+			if !reflect.DeepEqual(got, snapshot) {
+				t.Fatalf("payload fields differ from the v%d snapshot:\n%s", envelope.SchemaVersion, fieldDiff(got, snapshot))
+			}
+		})
+	}
+}
+
+func TestCheckSchemasCompatibility(t *testing.T) {
+	type v1 struct {
+		ID     string  `json:"id"`
+		Amount float64 `json:"amount"`
+	}
+	type v2 struct {
+		ID     string  `json:"id"`
+		Amount float64 `json:"amount"`
+		Note   string  `json:"note,omitempty"`
+	}
+	const eventType = "test.event"
+	snapshotV1 := `{"id": "string", "amount": "number"}`
+	tests := []struct {
+		name      string
+		schema    eventSchema
+		snapshots map[string]string
+		wantErr   string
+	}{
+		{
+			name:      "matching snapshot",
+			schema:    eventSchema{Version: 1, Sample: v1{}},
+			snapshots: map[string]string{"v1": snapshotV1},
+		},
+		{
+			name:      "version adding a field",
+			schema:    eventSchema{Version: 2, Sample: v2{}},
+			snapshots: map[string]string{"v1": snapshotV1, "v2": `{"id": "string", "amount": "number", "note": "string"}`},
+		},
+		{
+			name: "version removing a field",
+			schema: eventSchema{Version: 2, Sample: struct {
+				ID string `json:"id"`
+			}{}},
+			snapshots: map[string]string{"v1": snapshotV1, "v2": `{"id": "string"}`},
+			wantErr:   `removes or retypes field "amount"`,
+		},
+		{
+			name: "version retyping a field",
+			schema: eventSchema{Version: 2, Sample: struct {
+				ID     string `json:"id"`
+				Amount string `json:"amount"`
+			}{}},
+			snapshots: map[string]string{"v1": snapshotV1, "v2": `{"id": "string", "amount": "string"}`},
+			wantErr:   `removes or retypes field "amount"`,
+		},
+		{
+			name:      "payload changed without a new version",
+			schema:    eventSchema{Version: 1, Sample: v2{}},
+			snapshots: map[string]string{"v1": snapshotV1},
+			wantErr:   "payload differs from the v1 snapshot",
+		},
+		{
+			name:      "missing snapshot",
+			schema:    eventSchema{Version: 2, Sample: v2{}},
+			snapshots: map[string]string{"v1": snapshotV1},
+			wantErr:   "test.event v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := fstest.MapFS{}
+			for version, raw := range tt.snapshots {
+				snapshots["schemas/"+eventType+"/"+version+".json"] = &fstest.MapFile{Data: []byte(raw)}
+			}
+			err := checkSchemas(snapshots, map[string]eventSchema{eventType: tt.schema})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkSchemas() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkSchemas() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func (p *captureProducer) last(err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return p.messages[len(p.messages)-1].Value, nil
+}
+
+func outboxPayload(message *entity.OutboxMessage, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return message.Payload, nil
+}
+
+// filled sets every field of the event to a non zero value, so no field is left
+// out by omitempty and every slice has an element to describe.
+func filled[T any](event *T) *T {
+	fill(reflect.ValueOf(event).Elem())
+	return event
+}
+
+func fill(v reflect.Value) {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		v.Set(reflect.ValueOf(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	}
+}
+
+// jsonFields lists the fields of decoded JSON the way SchemaOf lists them.
+func jsonFields(value interface{}, prefix string, fields map[string]string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, field := range value {
+			path := prefix + name
+			fields[path] = jsonKindOf(field)
+			jsonFields(field, path+".", fields)
+		}
+	case []interface{}:
+		if len(value) > 0 {
+			jsonFields(value[0], strings.TrimSuffix(prefix, ".")+"[].", fields)
+		}
+	}
+}
+
+func jsonKindOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "any"
+	}
+}
+
+func fieldDiff(got, want map[string]string) string {
+	var lines []string
+	for field, kind := range got {
+		if want[field] != kind {
+			lines = append(lines, "+ "+field+": "+kind)
+		}
+	}
+	for field, kind := range want {
+		if got[field] != kind {
+			lines = append(lines, "- "+field+": "+kind)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,126 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"order-service/src/pkg/avro"
+	"order-service/src/pkg/schemaregistry"
+	"sync"
+)
+
+const (
+	SerializerJSON = "json"
+	SerializerAvro = "avro"
+
+	AvroContentType = "application/avro"
+	avroNamespace   = "nebengjek.order"
+)
+
+// Serializer encodes the envelope of an event published to a topic.
+type Serializer interface {
+	// ContentType is the datacontenttype of the envelopes it writes.
+	ContentType() string
+	Serialize(topic string, envelope *Envelope, payload interface{}) ([]byte, error)
+}
+
+// JSONSerializer writes the envelope and payload as JSON, the payload lands in
+// the data attribute.
+type JSONSerializer struct{}
+
+func NewJSONSerializer() *JSONSerializer {
+	return &JSONSerializer{}
+}
+
+func (s *JSONSerializer) ContentType() string {
+	return DataContentType
+}
+
+func (s *JSONSerializer) Serialize(topic string, envelope *Envelope, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Data = data
+	return json.Marshal(envelope)
+}
+
+// AvroSerializer writes the envelope as an Avro record, with the payload record
+// in its data field, framed in the Confluent wire format. The schema is
+// registered under the value subject of the topic the first time an event type
+// is sent to it.
+type AvroSerializer struct {
+	Registry schemaregistry.Registry
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+func NewAvroSerializer(registry schemaregistry.Registry) *AvroSerializer {
+	return &AvroSerializer{
+		Registry: registry,
+		ids:      make(map[string]int),
+	}
+}
+
+func (s *AvroSerializer) ContentType() string {
+	return AvroContentType
+}
+
+func (s *AvroSerializer) Serialize(topic string, envelope *Envelope, payload interface{}) ([]byte, error) {
+	id, err := s.schemaID(topic, envelope.Type, payload)
+	if err != nil {
+		return nil, err
+	}
+	header, err := avro.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	data, err := avro.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	// a record is its fields written in order, data is the last field
+	return schemaregistry.Encode(id, append(header, data...)), nil
+}
+
+func (s *AvroSerializer) schemaID(topic, eventType string, payload interface{}) (int, error) {
+	key := topic + "|" + eventType
+	s.mu.Lock()
+	id, ok := s.ids[key]
+	s.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	schema, err := envelopeSchema(payload)
+	if err != nil {
+		return 0, err
+	}
+	id, err = s.Registry.Register(context.Background(), schemaregistry.ValueSubject(topic), schema)
+	if err != nil {
+		return 0, fmt.Errorf("register schema of %s on %s: %w", eventType, topic, err)
+	}
+	s.mu.Lock()
+	s.ids[key] = id
+	s.mu.Unlock()
+	return id, nil
+}
+
+// envelopeSchema is the Avro schema of the envelope carrying the payload.
+func envelopeSchema(payload interface{}) (string, error) {
+	envelope, err := avro.RecordOf(Envelope{}, avroNamespace)
+	if err != nil {
+		return "", err
+	}
+	data, err := avro.RecordOf(payload, avroNamespace)
+	if err != nil {
+		return "", err
+	}
+	envelope.Fields = append(envelope.Fields, avro.Field{Name: "data", Type: data})
+	schema, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(schema), nil
+}
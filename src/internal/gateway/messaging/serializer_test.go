@@ -0,0 +1,252 @@
+package messaging
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/schemaregistry"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAvroSerializerRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.json")
+	registry, err := schemaregistry.NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("NewFileRegistry() = %v", err)
+	}
+	serializer := NewAvroSerializer(registry)
+
+	event := &model.ReceiptReadyEvent{
+		EventID:       "evt-1",
+		ReceiptNumber: "RCP-1",
+		OrderID:       "order-1",
+		PassengerID:   "passenger-1",
+		Total:         25500.5,
+		Currency:      "IDR",
+		PaymentMethod: "WALLET",
+		HTMLURL:       "https://example.com/r.html",
+		PDFURL:        "https://example.com/r.pdf",
+		Timestamp:     time.Date(2026, 3, 4, 5, 6, 7, 8000000, time.UTC),
+	}
+	value, err := wrap(serializer, "receipt-ready", EventReceiptReady, event.GetId(), event)
+	if err != nil {
+		t.Fatalf("wrap() = %v", err)
+	}
+
+	id, body, err := schemaregistry.Decode(value)
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	registered, err := registry.Schema(ctx, id)
+	if err != nil {
+		t.Fatalf("Schema(%d) = %v", id, err)
+	}
+	want, err := envelopeSchema(event)
+	if err != nil {
+		t.Fatalf("envelopeSchema() = %v", err)
+	}
+	if registered != want {
+		t.Fatalf("registered schema = %s, want %s", registered, want)
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal([]byte(registered), &schema); err != nil {
+		t.Fatalf("registered schema is not JSON: %v", err)
+	}
+	reader := &avroReader{buf: body, named: make(map[string]interface{})}
+	decoded, ok := reader.read(schema).(map[string]interface{})
+	if reader.err != nil || !ok {
+		t.Fatalf("decoding the message = %v %v", decoded, reader.err)
+	}
+	if len(reader.buf) != 0 {
+		t.Fatalf("%d bytes left after decoding the message", len(reader.buf))
+	}
+
+	checks := map[string]interface{}{
+		"type":            EventReceiptReady,
+		"source":          EventSource,
+		"specversion":     SpecVersion,
+		"datacontenttype": AvroContentType,
+		"dataschema":      dataSchema(EventReceiptReady, 1),
+		"schemaversion":   int64(1),
+		"subject":         event.OrderID,
+	}
+	for name, want := range checks {
+		if decoded[name] != want {
+			t.Errorf("envelope %s = %v, want %v", name, decoded[name], want)
+		}
+	}
+	data, _ := decoded["data"].(map[string]interface{})
+	wantData := map[string]interface{}{
+		"eventId":       event.EventID,
+		"receiptNumber": event.ReceiptNumber,
+		"orderId":       event.OrderID,
+		"passengerId":   event.PassengerID,
+		"companyId":     "",
+		"total":         event.Total,
+		"currency":      event.Currency,
+		"paymentMethod": event.PaymentMethod,
+		"htmlUrl":       event.HTMLURL,
+		"pdfUrl":        event.PDFURL,
+		"timestamp":     event.Timestamp.UnixMilli(),
+	}
+	if !reflect.DeepEqual(data, wantData) {
+		t.Fatalf("data = %v, want %v", data, wantData)
+	}
+
+	// the id is cached by the serializer and reused by the registry
+	again, err := wrap(serializer, "receipt-ready", EventReceiptReady, event.GetId(), event)
+	if err != nil {
+		t.Fatalf("wrap() = %v", err)
+	}
+	if againID, _, _ := schemaregistry.Decode(again); againID != id {
+		t.Fatalf("second message has schema id %d, want %d", againID, id)
+	}
+	reloaded, err := schemaregistry.NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("reloading the registry = %v", err)
+	}
+	restarted, err := wrap(NewAvroSerializer(reloaded), "receipt-ready", EventReceiptReady, event.GetId(), event)
+	if err != nil {
+		t.Fatalf("wrap() after reload = %v", err)
+	}
+	if restartedID, _, _ := schemaregistry.Decode(restarted); restartedID != id {
+		t.Fatalf("schema id after reload = %d, want %d", restartedID, id)
+	}
+
+	if _, err := Unwrap(value, nil); err == nil {
+		t.Fatalf("Unwrap() of an Avro message succeeded")
+	}
+}
+
+func TestAvroSerializerSeparatesEventTypes(t *testing.T) {
+	registry, err := schemaregistry.NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatalf("NewFileRegistry() = %v", err)
+	}
+	serializer := NewAvroSerializer(registry)
+
+	receipt, err := wrap(serializer, "events", EventReceiptReady, "order-1", &model.ReceiptReadyEvent{OrderID: "order-1"})
+	if err != nil {
+		t.Fatalf("wrap() = %v", err)
+	}
+	offer, err := wrap(serializer, "events", EventDriverOffer, "evt-1", &model.DriverOfferEvent{EventID: "evt-1"})
+	if err != nil {
+		t.Fatalf("wrap() = %v", err)
+	}
+	receiptID, _, _ := schemaregistry.Decode(receipt)
+	offerID, _, _ := schemaregistry.Decode(offer)
+	if receiptID == offerID {
+		t.Fatalf("two event types on one topic share schema id %d", receiptID)
+	}
+}
+
+// avroReader decodes the Avro binary encoding against a parsed JSON schema, the
+// subset the avro package writes.
+type avroReader struct {
+	buf   []byte
+	named map[string]interface{}
+	err   error
+}
+
+func (r *avroReader) fail(format string, args ...interface{}) interface{} {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+	return nil
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail("invalid varint")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+// take returns the next n bytes, zeros once the buffer runs short.
+func (r *avroReader) take(n int) []byte {
+	if n < 0 || n > len(r.buf) {
+		r.fail("need %d bytes, have %d", n, len(r.buf))
+		return make([]byte, max(n, 0))
+	}
+	out := r.buf[:n]
+	r.buf = r.buf[n:]
+	return out
+}
+
+func (r *avroReader) read(schema interface{}) interface{} {
+	if r.err != nil {
+		return nil
+	}
+	switch schema := schema.(type) {
+	case string:
+		switch schema {
+		case "null":
+			return nil
+		case "boolean":
+			return r.take(1)[0] == 1
+		case "int", "long":
+			return r.long()
+		case "float":
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(r.take(4))))
+		case "double":
+			return math.Float64frombits(binary.LittleEndian.Uint64(r.take(8)))
+		case "string":
+			return string(r.take(int(r.long())))
+		case "bytes":
+			return r.take(int(r.long()))
+		}
+		if named, ok := r.named[schema]; ok {
+			return r.read(named)
+		}
+		return r.fail("unknown type %q", schema)
+	case []interface{}:
+		branch := r.long()
+		if branch < 0 || int(branch) >= len(schema) {
+			return r.fail("union branch %d out of range", branch)
+		}
+		return r.read(schema[branch])
+	case map[string]interface{}:
+		switch schema["type"] {
+		case "record":
+			r.named[schema["name"].(string)] = schema
+			record := make(map[string]interface{})
+			for _, f := range schema["fields"].([]interface{}) {
+				f := f.(map[string]interface{})
+				record[f["name"].(string)] = r.read(f["type"])
+			}
+			return record
+		case "array":
+			var items []interface{}
+			for count := r.long(); count != 0 && r.err == nil; count = r.long() {
+				for i := int64(0); i < count; i++ {
+					items = append(items, r.read(schema["items"]))
+				}
+			}
+			return items
+		case "map":
+			values := make(map[string]interface{})
+			for count := r.long(); count != 0 && r.err == nil; count = r.long() {
+				for i := int64(0); i < count; i++ {
+					key := string(r.take(int(r.long())))
+					values[key] = r.read(schema["values"])
+				}
+			}
+			return values
+		default:
+			// a primitive with a logical type, such as timestamp-millis
+			return r.read(schema["type"])
+		}
+	}
+	return r.fail("unsupported schema %v", schema)
+}
@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"context"
+	"order-service/src/internal/entity"
 	"order-service/src/internal/model"
 	kafka "order-service/src/pkg/kafka/confluent"
 	"order-service/src/pkg/log"
@@ -12,25 +14,29 @@ type UserProducer struct {
 	Producer[*model.UserEvent]
 }
 
-func NewUserProducer(producer kafka.Producer, log log.Log) *UserProducer {
+func NewUserProducer(producer kafka.Producer, serializer Serializer, log log.Log) *UserProducer {
 	return &UserProducer{
 		RequestRideProducer: Producer[*model.UserEvent]{
-			Producer: producer,
-			Topic:    "request-ride",
-			Log:      log,
+			Producer:   producer,
+			Topic:      "request-ride",
+			Type:       EventRequestRide,
+			Serializer: serializer,
+			Log:        log,
 		},
 		DriverMatchProducer: Producer[*model.DriverMatchEvent]{
-			Producer: producer,
-			Topic:    "driver-match",
-			Log:      log,
+			Producer:   producer,
+			Topic:      "driver-match",
+			Type:       EventDriverMatch,
+			Serializer: serializer,
+			Log:        log,
 		},
 	}
 }
 
-func (u *UserProducer) SendRequestRide(event *model.UserEvent) error {
-	return u.RequestRideProducer.Send(event)
+func (u *UserProducer) OutboxRequestRide(ctx context.Context, event *model.UserEvent) (*entity.OutboxMessage, error) {
+	return u.RequestRideProducer.Outbox(ctx, event)
 }
 
-func (u *UserProducer) SendDriverMatch(event *model.DriverMatchEvent) error {
-	return u.DriverMatchProducer.Send(event)
+func (u *UserProducer) OutboxDriverMatch(ctx context.Context, event *model.DriverMatchEvent) (*entity.OutboxMessage, error) {
+	return u.DriverMatchProducer.Outbox(ctx, event)
 }
@@ -0,0 +1,102 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-service/src/pkg/log"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// GatewayProvider charges the passenger's linked payment account through the
+// payment gateway API at payment.gateway.base_url.
+type GatewayProvider struct {
+	Log        log.Log
+	BaseURL    string
+	APIKey     string
+	MerchantID string
+	Client     *http.Client
+}
+
+func NewGatewayProvider(cfg *viper.Viper, logger log.Log) (*GatewayProvider, error) {
+	provider := &GatewayProvider{
+		Log:        logger,
+		BaseURL:    strings.TrimRight(cfg.GetString("payment.gateway.base_url"), "/"),
+		APIKey:     cfg.GetString("payment.gateway.api_key"),
+		MerchantID: cfg.GetString("payment.gateway.merchant_id"),
+	}
+	if provider.BaseURL == "" || provider.APIKey == "" {
+		return nil, errors.New("payment.gateway.base_url and payment.gateway.api_key are required")
+	}
+	timeout := cfg.GetDuration("payment.gateway.timeout")
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	provider.Client = &http.Client{Timeout: timeout}
+	return provider, nil
+}
+
+func (p *GatewayProvider) Name() string {
+	return "gateway"
+}
+
+type gatewayResponse struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+func (p *GatewayProvider) Charge(ctx context.Context, request *ChargeRequest) (*ChargeResult, error) {
+	var response gatewayResponse
+	err := postJSON(ctx, p.Client, p.Log, "Gateway", p.BaseURL+"/v1/charges", p.APIKey, request.Reference, map[string]interface{}{
+		"merchant_id": p.MerchantID,
+		"reference":   request.Reference,
+		"customer_id": request.UserID,
+		"amount":      request.Amount,
+		"currency":    "IDR",
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(response.Status, "DECLINED") {
+		return nil, ErrChargeDeclined
+	}
+	return &ChargeResult{
+		Provider:          p.Name(),
+		ProviderReference: response.Reference,
+		Status:            gatewayStatus(response.Status),
+	}, nil
+}
+
+func (p *GatewayProvider) Refund(ctx context.Context, request *RefundRequest) (*RefundResult, error) {
+	var response gatewayResponse
+	err := postJSON(ctx, p.Client, p.Log, "Gateway", p.BaseURL+"/v1/refunds", p.APIKey, request.Reference, map[string]interface{}{
+		"merchant_id":      p.MerchantID,
+		"reference":        request.Reference,
+		"charge_reference": request.ProviderReference,
+		"amount":           request.Amount,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &RefundResult{
+		Provider:          p.Name(),
+		ProviderReference: response.Reference,
+		Status:            gatewayStatus(response.Status),
+	}, nil
+}
+
+// gatewayStatus maps a gateway status to the provider statuses, anything not
+// final yet stays pending.
+func gatewayStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "SUCCESS", "SUCCEEDED", "CAPTURED":
+		return StatusSucceeded
+	case "FAILED", "DECLINED", "EXPIRED":
+		return StatusFailed
+	default:
+		return StatusPending
+	}
+}
@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/pkg/log"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	StatusSucceeded = "SUCCEEDED"
+	StatusPending   = "PENDING"
+	StatusFailed    = "FAILED"
+)
+
+var ErrChargeDeclined = errors.New("charge declined by payment provider")
+
+type ChargeRequest struct {
+	// Reference is the idempotency key of the charge, providers must return the
+	// original result when the same reference is charged twice.
+	Reference string
+	UserID    string
+	Amount    float64
+}
+
+type ChargeResult struct {
+	Provider          string
+	ProviderReference string
+	Status            string
+}
+
+type RefundRequest struct {
+	// Reference is the idempotency key of the refund
+	Reference         string
+	ProviderReference string
+	Amount            float64
+}
+
+type RefundResult struct {
+	Provider          string
+	ProviderReference string
+	Status            string
+}
+
+// Provider is the adapter every payment provider implements.
+type Provider interface {
+	Name() string
+	Charge(ctx context.Context, request *ChargeRequest) (*ChargeResult, error)
+	Refund(ctx context.Context, request *RefundRequest) (*RefundResult, error)
+}
+
+// NewProvider returns the provider configured in payment.provider. It fails on
+// an empty or unknown provider, the sandbox settles every charge so it must be
+// selected explicitly.
+func NewProvider(cfg *viper.Viper, logger log.Log) (Provider, error) {
+	switch name := strings.ToLower(cfg.GetString("payment.provider")); name {
+	case "sandbox":
+		logger.Info("gateway/payment", "Using the sandbox payment provider, charges are not collected", "NewProvider", "")
+		return NewSandboxProvider(cfg, logger), nil
+	case "gateway":
+		return NewGatewayProvider(cfg, logger)
+	case "":
+		return nil, errors.New("payment.provider is not configured")
+	default:
+		return nil, fmt.Errorf("unknown payment.provider %q", name)
+	}
+}
@@ -0,0 +1,139 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"order-service/src/pkg/log"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// QrisProvider talks to the QRIS acquirer API at payment.qris.base_url.
+type QrisProvider struct {
+	Log        log.Log
+	BaseURL    string
+	APIKey     string
+	MerchantID string
+	Client     *http.Client
+}
+
+func NewQrisProvider(cfg *viper.Viper, logger log.Log) (*QrisProvider, error) {
+	provider := &QrisProvider{
+		Log:        logger,
+		BaseURL:    strings.TrimRight(cfg.GetString("payment.qris.base_url"), "/"),
+		APIKey:     cfg.GetString("payment.qris.api_key"),
+		MerchantID: cfg.GetString("payment.qris.merchant_id"),
+	}
+	if provider.BaseURL == "" || provider.APIKey == "" {
+		return nil, errors.New("payment.qris.base_url and payment.qris.api_key are required")
+	}
+	timeout := cfg.GetDuration("payment.qris.timeout")
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	provider.Client = &http.Client{Timeout: timeout}
+	return provider, nil
+}
+
+func (p *QrisProvider) Name() string {
+	return "qris"
+}
+
+type qrisCreateResponse struct {
+	Reference string    `json:"reference"`
+	QRString  string    `json:"qr_string"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type qrisRefundResponse struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+func (p *QrisProvider) CreateQR(ctx context.Context, request *QRRequest) (*QRPayment, error) {
+	var response qrisCreateResponse
+	err := p.post(ctx, "/v1/qr", request.Reference, map[string]interface{}{
+		"merchant_id":     p.MerchantID,
+		"reference":       request.Reference,
+		"order_id":        request.OrderID,
+		"amount":          request.Amount,
+		"expires_in_secs": int(request.ExpiresIn.Seconds()),
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &QRPayment{
+		Provider:          p.Name(),
+		ProviderReference: response.Reference,
+		Payload:           response.QRString,
+		ExpiresAt:         response.ExpiresAt,
+	}, nil
+}
+
+func (p *QrisProvider) Refund(ctx context.Context, request *RefundRequest) (*RefundResult, error) {
+	var response qrisRefundResponse
+	err := p.post(ctx, "/v1/refunds", request.Reference, map[string]interface{}{
+		"merchant_id":       p.MerchantID,
+		"reference":         request.Reference,
+		"payment_reference": request.ProviderReference,
+		"amount":            request.Amount,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	status := StatusPending
+	switch strings.ToUpper(response.Status) {
+	case "SUCCESS", "SUCCEEDED":
+		status = StatusSucceeded
+	case "FAILED":
+		status = StatusFailed
+	}
+	return &RefundResult{
+		Provider:          p.Name(),
+		ProviderReference: response.Reference,
+		Status:            status,
+	}, nil
+}
+
+func (p *QrisProvider) post(ctx context.Context, path, idempotencyKey string, body interface{}, result interface{}) error {
+	return postJSON(ctx, p.Client, p.Log, "QRIS", p.BaseURL+path, p.APIKey, idempotencyKey, body, result)
+}
+
+// postJSON posts body to url with the API key and idempotency key of a provider
+// API and decodes the 2xx response into result.
+func postJSON(ctx context.Context, client *http.Client, logger log.Log, provider, url, apiKey, idempotencyKey string, body interface{}, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Error("gateway/payment", fmt.Sprintf("%s %s returned %d", provider, url, resp.StatusCode), "postJSON", string(raw))
+		return fmt.Errorf("%s provider returned status %d", strings.ToLower(provider), resp.StatusCode)
+	}
+	return json.Unmarshal(raw, result)
+}
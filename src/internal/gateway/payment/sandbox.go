@@ -0,0 +1,48 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"order-service/src/pkg/log"
+
+	"github.com/spf13/viper"
+)
+
+// SandboxProvider settles every charge immediately, it backs local and staging
+// environments. Charges above payment.sandbox.decline_above are declined so the
+// failure path can be exercised.
+type SandboxProvider struct {
+	Log          log.Log
+	DeclineAbove float64
+}
+
+func NewSandboxProvider(cfg *viper.Viper, logger log.Log) *SandboxProvider {
+	return &SandboxProvider{
+		Log:          logger,
+		DeclineAbove: cfg.GetFloat64("payment.sandbox.decline_above"),
+	}
+}
+
+func (p *SandboxProvider) Name() string {
+	return "sandbox"
+}
+
+func (p *SandboxProvider) Charge(ctx context.Context, request *ChargeRequest) (*ChargeResult, error) {
+	if p.DeclineAbove > 0 && request.Amount > p.DeclineAbove {
+		p.Log.WithContext(ctx).Info("gateway/payment", "Sandbox charge declined", "Charge", request.Reference)
+		return nil, ErrChargeDeclined
+	}
+	return &ChargeResult{
+		Provider:          p.Name(),
+		ProviderReference: fmt.Sprintf("SBX-%s", request.Reference),
+		Status:            StatusSucceeded,
+	}, nil
+}
+
+func (p *SandboxProvider) Refund(ctx context.Context, request *RefundRequest) (*RefundResult, error) {
+	return &RefundResult{
+		Provider:          p.Name(),
+		ProviderReference: fmt.Sprintf("SBX-%s", request.Reference),
+		Status:            StatusSucceeded,
+	}, nil
+}
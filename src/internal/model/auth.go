@@ -3,4 +3,5 @@ package model
 type Auth struct {
 	UserID   string `json:"user_id"`
 	FullName string `json:"full_name"`
+	Segment  string `json:"segment,omitempty"`
 }
@@ -0,0 +1,77 @@
+package model
+
+import "order-service/src/internal/entity"
+
+type CreateCompanyRequest struct {
+	Name         string `json:"name" validate:"required,max=128"`
+	BillingEmail string `json:"billingEmail" validate:"required,email,max=128"`
+}
+
+type CompanyRequest struct {
+	CompanyID string `json:"companyId" params:"companyId" validate:"required"`
+}
+
+type CompanyStatusRequest struct {
+	CompanyID string `json:"companyId" params:"companyId" validate:"required"`
+	Status    string `json:"status" validate:"required,oneof=ACTIVE SUSPENDED"`
+}
+
+type CompanyMemberRequest struct {
+	CompanyID  string `json:"companyId" params:"companyId" validate:"required"`
+	UserID     string `json:"userId" params:"userId" validate:"required"`
+	CostCenter string `json:"costCenter" validate:"required,max=64"`
+}
+
+type RemoveCompanyMemberRequest struct {
+	CompanyID string `json:"companyId" params:"companyId" validate:"required"`
+	UserID    string `json:"userId" params:"userId" validate:"required"`
+}
+
+// CompanyPolicyRequest replaces the spending policy of a company, an empty field
+// is not enforced.
+type CompanyPolicyRequest struct {
+	CompanyID    string         `json:"companyId" params:"companyId" validate:"required"`
+	StartTime    string         `json:"startTime" validate:"required_with=EndTime,omitempty,datetime=15:04"`
+	EndTime      string         `json:"endTime" validate:"required_with=StartTime,omitempty,datetime=15:04"`
+	Weekdays     []int          `json:"weekdays" validate:"omitempty,dive,min=0,max=6"`
+	AllowedZones []DispatchZone `json:"allowedZones"`
+	MaxFare      *float64       `json:"maxFare" validate:"omitempty,gt=0"`
+}
+
+type CompanyResponse struct {
+	Company entity.Company         `json:"company"`
+	Policy  *entity.CompanyPolicy  `json:"policy,omitempty"`
+	Members []entity.CompanyMember `json:"members"`
+}
+
+type CorporateProfileResponse struct {
+	CompanyID   string                `json:"companyId"`
+	CompanyName string                `json:"companyName"`
+	CostCenter  string                `json:"costCenter"`
+	Policy      *entity.CompanyPolicy `json:"policy,omitempty"`
+}
+
+type GenerateInvoiceRequest struct {
+	CompanyID string `json:"companyId" params:"companyId" validate:"required"`
+	Period    string `json:"period" validate:"required,datetime=2006-01"`
+}
+
+type InvoiceRequest struct {
+	InvoiceID string `json:"invoiceId" params:"invoiceId" validate:"required"`
+}
+
+type CostCenterTotal struct {
+	CostCenter  string  `json:"costCenter"`
+	TripCount   int     `json:"tripCount"`
+	TotalAmount float64 `json:"totalAmount"`
+}
+
+type InvoiceResponse struct {
+	Invoice     entity.CorporateInvoice       `json:"invoice"`
+	CostCenters []CostCenterTotal             `json:"costCenters"`
+	Items       []entity.CorporateInvoiceItem `json:"items"`
+}
+
+type CorporateInvoiceTask struct {
+	Period string `json:"period"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"order-service/src/pkg/utils"
+	"time"
+)
+
+type DispatchZone struct {
+	ID        string  `json:"id" mapstructure:"id"`
+	Latitude  float64 `json:"latitude" mapstructure:"latitude"`
+	Longitude float64 `json:"longitude" mapstructure:"longitude"`
+	RadiusKm  float64 `json:"radiusKm" mapstructure:"radius_km"`
+}
+
+type DestinationModeRequest struct {
+	DriverID  string  `json:"driverId" validate:"required"`
+	Latitude  float64 `json:"latitude" validate:"required"`
+	Longitude float64 `json:"longitude" validate:"required"`
+	Address   string  `json:"address"`
+}
+
+type DriverDestination struct {
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Address     string    `json:"address,omitempty"`
+	ActivatedAt time.Time `json:"activatedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+type DestinationModeResponse struct {
+	Destination    DriverDestination `json:"destination"`
+	UsedToday      int64             `json:"usedToday"`
+	DailyQuota     int64             `json:"dailyQuota"`
+	RemainingToday int64             `json:"remainingToday"`
+}
+
+type QueueEntry struct {
+	DriverID  string    `json:"driverId"`
+	Position  int       `json:"position"`
+	JoinedAt  time.Time `json:"joinedAt"`
+	Latitude  float64   `json:"latitude,omitempty"`
+	Longitude float64   `json:"longitude,omitempty"`
+}
+
+type QueueResponse struct {
+	Zone    DispatchZone `json:"zone"`
+	Size    int          `json:"size"`
+	Drivers []QueueEntry `json:"drivers"`
+}
+
+type QueueRequest struct {
+	ZoneID   string `json:"zoneId" params:"zoneId" validate:"required"`
+	DriverID string `json:"driverId" params:"driverId"`
+}
+
+func (z DispatchZone) Contains(lat, lng float64) bool {
+	return utils.HaversineKm(z.Latitude, z.Longitude, lat, lng) <= z.RadiusKm
+}
+
+// CommissionTier is the platform commission for trips in a city with a vehicle
+// type, an empty city or vehicle type matches any.
+type CommissionTier struct {
+	City        string  `json:"city" mapstructure:"city"`
+	VehicleType string  `json:"vehicleType" mapstructure:"vehicle_type"`
+	Percent     float64 `json:"percent" mapstructure:"percent"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"order-service/src/internal/entity"
+)
+
+type DriverDebtResponse struct {
+	DriverID string                     `json:"driverId"`
+	Debt     float64                    `json:"debt"`
+	Limit    float64                    `json:"limit"`
+	Blocked  bool                       `json:"blocked"`
+	Entries  []entity.DriverLedgerEntry `json:"entries"`
+}
+
+type SettleDebtRequest struct {
+	DriverID string `json:"driverId" validate:"required"`
+	// Amount defaults to the whole outstanding debt
+	Amount         float64 `json:"amount" validate:"omitempty,gt=0"`
+	IdempotencyKey string  `json:"idempotencyKey" validate:"required,max=64,printascii"`
+}
+
+type SettleDebtResponse struct {
+	EntryID           string  `json:"entryId"`
+	Amount            float64 `json:"amount"`
+	Debt              float64 `json:"debt"`
+	Blocked           bool    `json:"blocked"`
+	Provider          string  `json:"provider,omitempty"`
+	ProviderReference string  `json:"providerReference,omitempty"`
+	Replayed          bool    `json:"replayed"`
+}
@@ -1,10 +1,27 @@
 package model
 
+import "time"
+
 type OrderEvent struct {
 	ID      string          `json:"id,omitempty"`
 	Message PickupPassanger `json:"message,omitempty"`
 }
 
+type DriverOfferEvent struct {
+	EventID          string       `json:"event_id"`
+	OrderID          string       `json:"order_id"`
+	PassengerID      string       `json:"passenger_id"`
+	DriverID         string       `json:"driver_id"`
+	PickupDistanceKm float64      `json:"pickup_distance_km"`
+	PickupEtaMinutes float64      `json:"pickup_eta_minutes"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	RouteSummary     RouteSummary `json:"route_summary,omitempty"`
+}
+
 func (u *OrderEvent) GetId() string {
 	return u.ID
 }
+
+func (e *DriverOfferEvent) GetId() string {
+	return e.EventID
+}
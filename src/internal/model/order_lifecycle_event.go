@@ -0,0 +1,44 @@
+package model
+
+import (
+	"order-service/src/internal/entity"
+	"strings"
+	"time"
+)
+
+const (
+	TransitionStatus        = "status"
+	TransitionPaymentStatus = "payment_status"
+)
+
+// OrderTransition is the field of the order that changed, its status or its
+// payment status.
+type OrderTransition struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to"`
+}
+
+// EventType names the transition, ORDER_<status> or PAYMENT_<payment status>.
+func (t OrderTransition) EventType() string {
+	if t.Field == TransitionPaymentStatus {
+		return "PAYMENT_" + strings.ToUpper(t.To)
+	}
+	return "ORDER_" + strings.ToUpper(t.To)
+}
+
+// OrderLifecycleEvent is published on order-events for every change of an order,
+// with the order as it was committed.
+type OrderLifecycleEvent struct {
+	EventID    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	OrderID    string          `json:"order_id"`
+	Transition OrderTransition `json:"transition"`
+	Order      entity.Order    `json:"order"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// GetId keys the event by order so the events of one order stay on one partition.
+func (e *OrderLifecycleEvent) GetId() string {
+	return e.OrderID
+}
@@ -5,26 +5,34 @@ import (
 	"time"
 )
 
+const (
+	DispatchModeManual = "MANUAL"
+	DispatchModeAuto   = "AUTO"
+	DispatchModeBatch  = "BATCH"
+
+	RideTypePrivate = "PRIVATE"
+	RideTypeShared  = "SHARED"
+)
+
 type PickupPassanger struct {
 	DriverID    string `json:"driverId" validate:"required"`
 	PassangerID string `json:"passangerId" bson:"passangerId" validate:"required"`
 	OrderID     string `json:"orderId" validate:"required"`
 }
 
+type AcceptPickupRequest struct {
+	DriverID string `json:"driverId" validate:"required"`
+	OrderID  string `json:"orderId" validate:"required"`
+}
+
 type RequestCompleteTrip struct {
 	DriverID       string  `json:"driverId" validate:"required"`
 	OrderID        string  `json:"orderId" validate:"required"`
 	FarePercentage float64 `json:"farePercentage" validate:"required"`
+	// CashCollected is the amount the driver confirms receiving on a cash trip
+	CashCollected *float64 `json:"cashCollected" validate:"omitempty,gte=0"`
 }
 
-type TripTracker struct {
-	Data DataTrip `json:"data"`
-}
-
-type DataTrip struct {
-	DriverID string `json:"driverId" bson:"driverId"`
-	Distance string `json:"distance" bson:"distance"`
-}
 type OrderDetailRequest struct {
 	UserID  string `json:"userId" validate:"required"`
 	OrderID string `json:"orderId" validate:"required"`
@@ -45,6 +53,9 @@ type LocationRequest struct {
 	Longitude float64 `json:"longitude" validate:"required"`
 	Latitude  float64 `json:"latitude" validate:"required"`
 	Address   string  `json:"address" validate:"required"`
+	// City is resolved from dispatch.city_areas, a value sent by the client is
+	// overwritten
+	City string `json:"city,omitempty"`
 }
 
 type LocationSuggestionRequest struct {
@@ -78,11 +89,27 @@ type DriverMatch struct {
 	GeoHash   int32   `json:"GeoHash"`
 }
 
+type AutoDispatchTask struct {
+	OrderID     string    `json:"orderId"`
+	PassengerID string    `json:"passengerId"`
+	Deadline    time.Time `json:"deadline"`
+}
+
+type HoldExpiryTask struct {
+	OrderID     string `json:"orderId"`
+	PassengerID string `json:"passengerId"`
+}
+
+type ProcessRefundTask struct {
+	RefundID string `json:"refundId"`
+}
+
 type RequestRide struct {
-	RouteSummary RouteSummary `json:"routeSummary" bson:"routeSummary"`
-	OrderTempID  string       `json:"orderTempId" bson:"orderTempId"`
-	UserId       string       `json:"userId" bson:"userId"`
-	Attempt      int          `json:"attempt"`
+	RouteSummary       RouteSummary `json:"routeSummary" bson:"routeSummary"`
+	OrderTempID        string       `json:"orderTempId" bson:"orderTempId"`
+	UserId             string       `json:"userId" bson:"userId"`
+	Attempt            int          `json:"attempt"`
+	CandidateDriverIDs []string     `json:"candidateDriverIds,omitempty" bson:"candidateDriverIds"`
 }
 
 type Route struct {
@@ -98,7 +125,10 @@ type FindDriverResponse struct {
 
 type FindDriverRequest struct {
 	UserID        string `json:"userId" validate:"required"`
-	PaymentMethod string `json:"paymentMethod" validate:"required,oneof=wallet cash qris"`
+	Segment       string `json:"-"`
+	PaymentMethod string `json:"paymentMethod" validate:"required,payment_method"`
+	RideType      string `json:"rideType" validate:"omitempty,oneof=private shared PRIVATE SHARED"`
+	CostCenter    string `json:"costCenter" validate:"omitempty,max=64"`
 }
 
 type AvailableDriverResponse struct {
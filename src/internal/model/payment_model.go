@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type QrisPaymentRequest struct {
+	UserID  string `json:"userId" validate:"required"`
+	OrderID string `json:"orderId" params:"orderId" validate:"required"`
+}
+
+type QrisPaymentResponse struct {
+	OrderID           string    `json:"orderId"`
+	Amount            float64   `json:"amount"`
+	Status            string    `json:"status"`
+	Provider          string    `json:"provider"`
+	ProviderReference string    `json:"providerReference"`
+	QRPayload         string    `json:"qrPayload"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+type PaymentCallbackResponse struct {
+	Reference string `json:"reference"`
+	OrderID   string `json:"orderId"`
+	Status    string `json:"status"`
+	// Settled is false when the callback was a redelivery of a settled payment
+	Settled bool `json:"settled"`
+}
+
+// PaymentResultEvent is what the wallet service publishes after debiting the
+// passenger for an ORDER_COMPLETED event.
+type PaymentResultEvent struct {
+	EventID       string    `json:"eventId"`
+	OrderID       string    `json:"orderId"`
+	PassengerID   string    `json:"passangerId"`
+	Status        string    `json:"status"`
+	Amount        float64   `json:"amount"`
+	Provider      string    `json:"provider,omitempty"`
+	TransactionID string    `json:"transactionId"`
+	Reason        string    `json:"reason,omitempty"`
+	ProcessedAt   time.Time `json:"processedAt"`
+}
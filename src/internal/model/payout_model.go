@@ -0,0 +1,51 @@
+package model
+
+import (
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/constants"
+)
+
+type PayoutTask struct {
+	BatchID string `json:"batchId"`
+}
+
+type PayoutListRequest struct {
+	DriverID string `json:"driverId" query:"driver_id"`
+	Status   string `json:"status" query:"status" validate:"omitempty,oneof=PENDING PROCESSING PAID FAILED"`
+	From     string `json:"from" query:"from"`
+	To       string `json:"to" query:"to"`
+	Page     int64  `json:"page" query:"page"`
+	Limit    int64  `json:"limit" query:"limit"`
+}
+
+type PayoutPage struct {
+	Batches []entity.PayoutBatch `json:"batches"`
+	Meta    constants.MetaData   `json:"meta"`
+}
+
+type PayoutReportRequest struct {
+	From string `json:"from" query:"from" validate:"required"`
+	To   string `json:"to" query:"to" validate:"required"`
+}
+
+type PayoutReportDay struct {
+	PayoutDate       string         `json:"payoutDate"`
+	Batches          int            `json:"batches"`
+	TripCount        int            `json:"tripCount"`
+	GrossAmount      float64        `json:"grossAmount"`
+	CommissionAmount float64        `json:"commissionAmount"`
+	NetAmount        float64        `json:"netAmount"`
+	ByStatus         map[string]int `json:"byStatus"`
+}
+
+type PayoutReportResponse struct {
+	From             string            `json:"from"`
+	To               string            `json:"to"`
+	Batches          int               `json:"batches"`
+	TripCount        int               `json:"tripCount"`
+	GrossAmount      float64           `json:"grossAmount"`
+	CommissionAmount float64           `json:"commissionAmount"`
+	NetAmount        float64           `json:"netAmount"`
+	PaidAmount       float64           `json:"paidAmount"`
+	Days             []PayoutReportDay `json:"days"`
+}
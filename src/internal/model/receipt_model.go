@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+const (
+	ReceiptFormatHTML = "html"
+	ReceiptFormatPDF  = "pdf"
+)
+
+type ReceiptRequest struct {
+	UserID  string `json:"userId" validate:"required"`
+	OrderID string `json:"orderId" params:"orderId" validate:"required"`
+	Format  string `json:"format" query:"format" validate:"omitempty,oneof=html pdf"`
+}
+
+type ReceiptLine struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// Receipt is the content of a trip e-receipt, rendered as HTML or PDF.
+type Receipt struct {
+	ReceiptNumber      string        `json:"receiptNumber"`
+	OrderID            string        `json:"orderId"`
+	PassengerID        string        `json:"passengerId"`
+	DriverID           string        `json:"driverId,omitempty"`
+	OriginAddress      string        `json:"originAddress"`
+	DestinationAddress string        `json:"destinationAddress"`
+	DistanceKm         float64       `json:"distanceKm"`
+	Duration           string        `json:"duration"`
+	RideType           string        `json:"rideType"`
+	Lines              []ReceiptLine `json:"lines"`
+	Total              float64       `json:"total"`
+	Currency           string        `json:"currency"`
+	PaymentMethod      string        `json:"paymentMethod"`
+	PaymentStatus      string        `json:"paymentStatus"`
+	PaymentReference   string        `json:"paymentReference,omitempty"`
+	CompanyID          string        `json:"companyId,omitempty"`
+	CostCenter         string        `json:"costCenter,omitempty"`
+	CompletedAt        time.Time     `json:"completedAt"`
+	IssuedAt           time.Time     `json:"issuedAt"`
+}
+
+type ReceiptDocument struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+type ReceiptReadyEvent struct {
+	EventID       string    `json:"eventId"`
+	ReceiptNumber string    `json:"receiptNumber"`
+	OrderID       string    `json:"orderId"`
+	PassengerID   string    `json:"passengerId"`
+	CompanyID     string    `json:"companyId,omitempty"`
+	Total         float64   `json:"total"`
+	Currency      string    `json:"currency"`
+	PaymentMethod string    `json:"paymentMethod"`
+	HTMLURL       string    `json:"htmlUrl"`
+	PDFURL        string    `json:"pdfUrl"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (e *ReceiptReadyEvent) GetId() string {
+	return e.OrderID
+}
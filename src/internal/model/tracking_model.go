@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// TripLocationEvent is a GPS fix the driver app publishes while driving, keyed by
+// order so the fixes of a trip arrive in order.
+type TripLocationEvent struct {
+	EventID  string  `json:"eventId"`
+	OrderID  string  `json:"orderId"`
+	DriverID string  `json:"driverId"`
+	Lat      float64 `json:"lat"`
+	Lng      float64 `json:"lng"`
+	// Accuracy is the radius of the fix in meters, 0 when the device did not report it
+	Accuracy   float64   `json:"accuracy,omitempty"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+type TripPoint struct {
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
@@ -17,12 +17,18 @@ type DriverMatchEvent struct {
 	RouteSummary RouteSummary `json:"route_summary,omitempty"`
 }
 
+// NotificationUser is the ORDER_COMPLETED event. PaymentSettled tells the wallet
+// service the fare was already captured from the booking hold, it must not debit
+// the passenger again.
 type NotificationUser struct {
-	EventType   string    `json:"eventType"`
-	OrderID     string    `json:"orderId"`
-	DriverID    string    `json:"driverId"`
-	PassengerID string    `json:"passangerId"`
-	Timestamp   time.Time `json:"timestamp"`
+	EventType      string    `json:"eventType"`
+	OrderID        string    `json:"orderId"`
+	DriverID       string    `json:"driverId"`
+	PassengerID    string    `json:"passangerId"`
+	FinalFare      float64   `json:"finalFare,omitempty"`
+	PaymentMethod  string    `json:"paymentMethod,omitempty"`
+	PaymentSettled bool      `json:"paymentSettled"`
+	Timestamp      time.Time `json:"timestamp"`
 }
 
 func (u *UserEvent) GetId() string {
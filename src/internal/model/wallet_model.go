@@ -0,0 +1,76 @@
+package model
+
+import (
+	"order-service/src/pkg/constants"
+	"time"
+)
+
+const (
+	WalletActionCreate = "CREATE_WALLET"
+	WalletActionTopUp  = "TOP_UP"
+)
+
+type WalletResponse struct {
+	WalletID    string    `json:"walletId"`
+	UserID      string    `json:"userId"`
+	Balance     float64   `json:"balance"`
+	HeldBalance float64   `json:"heldBalance"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+type TopUpRequest struct {
+	UserID         string  `json:"userId" validate:"required"`
+	Amount         float64 `json:"amount" validate:"required,gt=0"`
+	IdempotencyKey string  `json:"idempotencyKey" validate:"required,max=64,printascii"`
+}
+
+type TopUpResponse struct {
+	Wallet            WalletResponse `json:"wallet"`
+	TransactionID     string         `json:"transactionId"`
+	Provider          string         `json:"provider"`
+	ProviderReference string         `json:"providerReference"`
+	Replayed          bool           `json:"replayed"`
+}
+
+// WalletAction tells the client how to recover from a wallet error.
+type WalletAction struct {
+	Action   string  `json:"action"`
+	Endpoint string  `json:"endpoint"`
+	Required float64 `json:"required,omitempty"`
+	Balance  float64 `json:"balance,omitempty"`
+}
+
+type WalletTransactionRequest struct {
+	UserID string `json:"userId" validate:"required"`
+	Type   string `json:"type" query:"type"`
+	From   string `json:"from" query:"from"`
+	To     string `json:"to" query:"to"`
+	Page   int64  `json:"page" query:"page"`
+	Limit  int64  `json:"limit" query:"limit"`
+}
+
+type WalletStatementRequest struct {
+	UserID string `json:"userId" validate:"required"`
+	Month  string `json:"month" query:"month"`
+}
+
+type WalletTransactionResponse struct {
+	TransactionID string    `json:"transactionId"`
+	Type          string    `json:"type"`
+	Direction     string    `json:"direction"`
+	Amount        float64   `json:"amount"`
+	Description   string    `json:"description"`
+	OrderID       string    `json:"orderId,omitempty"`
+	OrderLink     string    `json:"orderLink,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type WalletTransactionPage struct {
+	Transactions []WalletTransactionResponse
+	Meta         constants.MetaData
+}
+
+type WalletStatement struct {
+	FileName string
+	Content  []byte
+}
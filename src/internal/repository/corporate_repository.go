@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+	"time"
+)
+
+var ErrCompanyNotFound = errors.New("company not found")
+
+const (
+	CompanyActive    = "ACTIVE"
+	CompanySuspended = "SUSPENDED"
+
+	InvoiceIssued = "ISSUED"
+	InvoicePaid   = "PAID"
+)
+
+type CorporateRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewCorporateRepository(db mysql.DBInterface) *CorporateRepository {
+	return &CorporateRepository{
+		DB: db,
+	}
+}
+
+func (r *CorporateRepository) InsertCompany(ctx context.Context, company *entity.Company) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO companies (company_id, name, billing_email, status)
+		VALUES (?, ?, ?, ?)
+	`, company.CompanyID, company.Name, company.BillingEmail, defaultString(company.Status, CompanyActive))
+	return err
+}
+
+func (r *CorporateRepository) UpdateCompanyStatus(ctx context.Context, companyID, status string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, `UPDATE companies SET status = ? WHERE company_id = ?`, status, companyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCompanyNotFound
+	}
+	return nil
+}
+
+func (r *CorporateRepository) GetCompany(ctx context.Context, companyID string) (*entity.Company, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var company entity.Company
+	query := `
+		SELECT id, company_id, name, billing_email, status, created_at, updated_at
+		FROM companies
+		WHERE company_id = ?
+	`
+	if err := db.GetContext(ctx, &company, query, companyID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &company, nil
+}
+
+// UpsertMember adds a user to a company or moves them to another cost center, a
+// user rides for one company at a time.
+func (r *CorporateRepository) UpsertMember(ctx context.Context, member *entity.CompanyMember) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO company_members (company_id, user_id, cost_center, status)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE company_id = VALUES(company_id), cost_center = VALUES(cost_center), status = VALUES(status)
+	`, member.CompanyID, member.UserID, member.CostCenter, CompanyActive)
+	return err
+}
+
+func (r *CorporateRepository) RemoveMember(ctx context.Context, companyID, userID string) (bool, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := db.ExecContext(ctx, `DELETE FROM company_members WHERE company_id = ? AND user_id = ?`, companyID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (r *CorporateRepository) FindMembers(ctx context.Context, companyID string) ([]entity.CompanyMember, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []entity.CompanyMember
+	query := `
+		SELECT id, company_id, user_id, cost_center, status, created_at, updated_at
+		FROM company_members
+		WHERE company_id = ?
+		ORDER BY cost_center, user_id
+	`
+	if err := db.SelectContext(ctx, &members, query, companyID); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetMembership returns the company a user rides for, nil when the user is not a
+// member.
+func (r *CorporateRepository) GetMembership(ctx context.Context, userID string) (*entity.CorporateMembership, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var membership entity.CorporateMembership
+	if err := db.GetContext(ctx, &membership.Member, `
+		SELECT id, company_id, user_id, cost_center, status, created_at, updated_at
+		FROM company_members
+		WHERE user_id = ?
+	`, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	company, err := r.GetCompany(ctx, membership.Member.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if company == nil {
+		return nil, nil
+	}
+	membership.Company = *company
+
+	if membership.Policy, err = r.GetPolicy(ctx, company.CompanyID); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *CorporateRepository) UpsertPolicy(ctx context.Context, policy *entity.CompanyPolicy) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO company_policies (company_id, start_time, end_time, weekdays, allowed_zones, max_fare)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			start_time = VALUES(start_time),
+			end_time = VALUES(end_time),
+			weekdays = VALUES(weekdays),
+			allowed_zones = VALUES(allowed_zones),
+			max_fare = VALUES(max_fare)
+	`, policy.CompanyID, policy.StartTime, policy.EndTime, policy.Weekdays, policy.AllowedZones, policy.MaxFare)
+	return err
+}
+
+func (r *CorporateRepository) GetPolicy(ctx context.Context, companyID string) (*entity.CompanyPolicy, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var policy entity.CompanyPolicy
+	query := `
+		SELECT id, company_id, start_time, end_time, weekdays, allowed_zones, max_fare, created_at, updated_at
+		FROM company_policies
+		WHERE company_id = ?
+	`
+	if err := db.GetContext(ctx, &policy, query, companyID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindBilledCompanies returns the companies with completed corporate trips in the
+// period that are not invoiced yet.
+func (r *CorporateRepository) FindBilledCompanies(ctx context.Context, from, to time.Time) ([]string, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var companies []string
+	query := `
+		SELECT DISTINCT o.company_id
+		FROM orders o
+		LEFT JOIN corporate_invoice_items ii ON ii.order_id = o.order_id
+		WHERE o.status = 'COMPLETED'
+		  AND o.company_id IS NOT NULL
+		  AND o.completed_at >= ? AND o.completed_at < ?
+		  AND ii.id IS NULL
+		ORDER BY o.company_id
+	`
+	if err := db.SelectContext(ctx, &companies, query, from, to); err != nil {
+		return nil, err
+	}
+	return companies, nil
+}
+
+// GenerateInvoice issues the invoice of a company for a period with every
+// completed trip of the period billed to the company. Generating it again only
+// adds the trips that were not invoiced yet.
+func (r *CorporateRepository) GenerateInvoice(ctx context.Context, invoice *entity.CorporateInvoice, from, to time.Time) (*entity.CorporateInvoice, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO corporate_invoices (invoice_id, company_id, period, status, due_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, invoice.InvoiceID, invoice.CompanyID, invoice.Period, InvoiceIssued, invoice.DueAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO corporate_invoice_items (invoice_id, order_id, passenger_id, cost_center, amount, completed_at)
+		SELECT ?, o.order_id, o.passenger_id, COALESCE(o.cost_center, ''), COALESCE(o.final_fare, o.best_route_price), o.completed_at
+		FROM orders o
+		WHERE o.status = 'COMPLETED'
+		  AND o.company_id = ?
+		  AND o.completed_at >= ? AND o.completed_at < ?
+	`, invoice.InvoiceID, invoice.CompanyID, from, to); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE corporate_invoices i
+		JOIN (
+			SELECT invoice_id, COUNT(*) AS trips, SUM(amount) AS total
+			FROM corporate_invoice_items
+			WHERE invoice_id = ?
+			GROUP BY invoice_id
+		) t ON t.invoice_id = i.invoice_id
+		SET i.trip_count = t.trips, i.total_amount = t.total
+	`, invoice.InvoiceID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetInvoice(ctx, invoice.InvoiceID)
+}
+
+func (r *CorporateRepository) GetInvoice(ctx context.Context, invoiceID string) (*entity.CorporateInvoice, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var invoice entity.CorporateInvoice
+	query := `
+		SELECT id, invoice_id, company_id, period, trip_count, total_amount, status, issued_at, due_at, created_at, updated_at
+		FROM corporate_invoices
+		WHERE invoice_id = ?
+	`
+	if err := db.GetContext(ctx, &invoice, query, invoiceID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *CorporateRepository) FindInvoices(ctx context.Context, companyID string) ([]entity.CorporateInvoice, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var invoices []entity.CorporateInvoice
+	query := `
+		SELECT id, invoice_id, company_id, period, trip_count, total_amount, status, issued_at, due_at, created_at, updated_at
+		FROM corporate_invoices
+		WHERE company_id = ?
+		ORDER BY period DESC
+	`
+	if err := db.SelectContext(ctx, &invoices, query, companyID); err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (r *CorporateRepository) FindInvoiceItems(ctx context.Context, invoiceID string) ([]entity.CorporateInvoiceItem, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []entity.CorporateInvoiceItem
+	query := `
+		SELECT id, invoice_id, order_id, passenger_id, cost_center, amount, completed_at, created_at
+		FROM corporate_invoice_items
+		WHERE invoice_id = ?
+		ORDER BY cost_center, completed_at
+	`
+	if err := db.SelectContext(ctx, &items, query, invoiceID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	LedgerCommission = "COMMISSION"
+	LedgerSettlement = "SETTLEMENT"
+)
+
+// DriverLedgerRepository books what drivers owe the platform. The debt lives in the
+// driver wallet, a commission debit may take the balance below zero and the
+// negative part is the debt.
+type DriverLedgerRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewDriverLedgerRepository(db mysql.DBInterface) *DriverLedgerRepository {
+	return &DriverLedgerRepository{
+		DB: db,
+	}
+}
+
+// BookCommission debits the commission of a cash trip from the driver wallet. It
+// returns false with the booked entry when the order was already booked.
+func (r *DriverLedgerRepository) BookCommission(ctx context.Context, entry *entity.DriverLedgerEntry) (*entity.DriverLedgerEntry, bool, error) {
+	return r.book(ctx, entry, -entry.Amount, "commission")
+}
+
+// Settle credits a debt payment to the driver wallet, it returns false with the
+// booked entry when the payment was already applied.
+func (r *DriverLedgerRepository) Settle(ctx context.Context, entry *entity.DriverLedgerEntry) (*entity.DriverLedgerEntry, bool, error) {
+	return r.book(ctx, entry, entry.Amount, "settlement")
+}
+
+// book joins the transaction carried by ctx, a commission booked while completing
+// a trip commits with the completion.
+func (r *DriverLedgerRepository) book(ctx context.Context, entry *entity.DriverLedgerEntry, delta float64, walletType string) (*entity.DriverLedgerEntry, bool, error) {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	wallet, err := lockDriverWallet(ctx, tx.Tx, entry.DriverID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	orderID := ""
+	if entry.OrderID != nil {
+		orderID = *entry.OrderID
+	}
+	description := ""
+	if entry.Description != nil {
+		description = *entry.Description
+	}
+	inserted, err := insertWalletTransaction(ctx, tx.Tx, wallet.ID, entry.EntryID, orderID, entry.Amount, walletType, description)
+	if err != nil {
+		return nil, false, err
+	}
+	if !inserted {
+		booked, err := r.GetEntry(ctx, entry.EntryID)
+		if booked == nil {
+			booked = entry
+		}
+		return booked, false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE wallets SET balance = balance + ?, last_updated = NOW(6)
+		WHERE id = ?
+	`, delta, wallet.ID); err != nil {
+		return nil, false, err
+	}
+
+	entry.DebtAfter = math.Max(-(wallet.Balance + delta), 0)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO driver_ledger
+			(entry_id, driver_id, order_id, entry_type, fare, cash_collected, amount, debt_after, description)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.EntryID, entry.DriverID, entry.OrderID, entry.EntryType, entry.Fare, entry.CashCollected, entry.Amount, entry.DebtAfter, entry.Description); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// lockDriverWallet locks the driver wallet, opening one when the driver has none.
+func lockDriverWallet(ctx context.Context, tx *sqlx.Tx, driverID string) (*entity.Wallet, error) {
+	var wallet entity.Wallet
+	query := `SELECT id, balance FROM wallets WHERE user_id = ? FOR UPDATE`
+	err := tx.GetContext(ctx, &wallet, query, driverID)
+	if err == sql.ErrNoRows {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO wallets (id, user_id, balance, held_balance, last_updated)
+			VALUES (UUID(), ?, 0, 0, NOW(6))
+		`, driverID); err != nil {
+			return nil, err
+		}
+		err = tx.GetContext(ctx, &wallet, query, driverID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetDebt returns what the driver owes, zero when the wallet is not negative.
+func (r *DriverLedgerRepository) GetDebt(ctx context.Context, driverID string) (float64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var balance float64
+	if err := db.GetContext(ctx, &balance, `SELECT balance FROM wallets WHERE user_id = ?`, driverID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return math.Max(-balance, 0), nil
+}
+
+func (r *DriverLedgerRepository) GetEntry(ctx context.Context, entryID string) (*entity.DriverLedgerEntry, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry entity.DriverLedgerEntry
+	query := `
+		SELECT id, entry_id, driver_id, order_id, entry_type, fare, cash_collected, amount, debt_after, description, created_at
+		FROM driver_ledger
+		WHERE entry_id = ?
+	`
+	if err := db.GetContext(ctx, &entry, query, entryID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindEntries returns the latest ledger entries of a driver, newest first.
+func (r *DriverLedgerRepository) FindEntries(ctx context.Context, driverID string, limit int) ([]entity.DriverLedgerEntry, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entity.DriverLedgerEntry
+	query := `
+		SELECT id, entry_id, driver_id, order_id, entry_type, fare, cash_collected, amount, debt_after, description, created_at
+		FROM driver_ledger
+		WHERE driver_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	if err := db.SelectContext(ctx, &entries, query, driverID, limit); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"order-service/src/internal/entity"
 	"order-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type DriverRepository struct {
@@ -48,6 +50,42 @@ func (r *DriverRepository) FindDriver(ctx context.Context, id string) ([]entity.
 	return drivers, nil
 }
 
+func (r *DriverRepository) FindAvailableDrivers(ctx context.Context, ids []string) ([]entity.AvailableDriver, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT 
+			da.driver_id,
+			da.status,
+			da.last_seen_at,
+			i.city,
+			i.jenis_kendaraan
+		FROM driver_availability da
+		JOIN info_driver i 
+			ON da.driver_id = i.driver_id
+		WHERE da.is_available = 1
+		AND da.status = 'online'
+		AND da.last_seen_at >= NOW() - INTERVAL 2 MINUTE
+		AND da.driver_id IN (?);
+		`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var drivers []entity.AvailableDriver
+	if err := db.SelectContext(ctx, &drivers, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return drivers, nil
+}
+
 func (r *DriverRepository) GetDetailDriver(ctx context.Context, id string) (*entity.DriverInfo, error) {
 	db, err := r.DB.GetDB()
 	if err != nil {
@@ -77,6 +115,39 @@ func (r *DriverRepository) GetDetailDriver(ctx context.Context, id string) (*ent
 	return &drivers, nil
 }
 
+func (r *DriverRepository) GetDetailDrivers(ctx context.Context, ids []string) ([]entity.DriverInfo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT 
+			i.driver_id,
+			u.full_name,
+			i.city,
+			i.jenis_kendaraan,
+			i.nopol
+		FROM users u
+		JOIN info_driver i 
+			ON u.user_id = i.driver_id
+		WHERE u.user_id IN (?);
+		`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var drivers []entity.DriverInfo
+	if err := db.SelectContext(ctx, &drivers, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return drivers, nil
+}
+
 func (r *DriverRepository) SetOnTrip(ctx context.Context, driverID string) error {
 	db, err := r.DB.GetDB()
 	if err != nil {
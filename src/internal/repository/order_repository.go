@@ -20,7 +20,7 @@ func NewOrderRepository(db mysql.DBInterface) *OrderRepository {
 }
 
 func (r *OrderRepository) FindOrders(ctx context.Context, f entity.OrderFilter) ([]entity.Order, error) {
-	db, err := r.DB.GetDB()
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return nil, err
 	}
@@ -42,9 +42,15 @@ func (r *OrderRepository) FindOrders(ctx context.Context, f entity.OrderFilter)
 			o.best_route_km,
 			o.best_route_price,
 			o.best_route_duration,
+			o.final_fare,
 			o.status,
 			o.payment_method,
 			o.payment_status,
+			o.company_id,
+			o.cost_center,
+			o.dispatch_mode,
+			o.ride_type,
+			o.shared_group_id,
 			o.estimated_fare,
 			o.distance_km,
 			o.distance_actual,
@@ -97,6 +103,16 @@ func (r *OrderRepository) FindOrders(ctx context.Context, f entity.OrderFilter)
 		args = append(args, *f.PaymentStatus)
 	}
 
+	if f.DispatchMode != nil {
+		conds = append(conds, "o.dispatch_mode = ?")
+		args = append(args, *f.DispatchMode)
+	}
+
+	if f.SharedGroupID != nil {
+		conds = append(conds, "o.shared_group_id = ?")
+		args = append(args, *f.SharedGroupID)
+	}
+
 	query := baseQuery
 
 	if len(conds) > 0 {
@@ -133,8 +149,9 @@ func (r *OrderRepository) OrderDetail(ctx context.Context, id string) (*entity.O
 	var order entity.OrderDetail
 
 	query := `
-		SELECT 
-			0.order_id,
+		SELECT
+			o.id,
+			o.order_id,
 			o.passenger_id,
 			o.driver_id,
 			o.origin_lat,
@@ -143,15 +160,24 @@ func (r *OrderRepository) OrderDetail(ctx context.Context, id string) (*entity.O
 			o.destination_lng,
 			o.origin_address,
 			o.destination_address,
-			o.route,
 			o.min_price,
 			o.max_price,
 			o.best_route_km,
 			o.best_route_price,
 			o.best_route_duration,
+			o.final_fare,
+			o.distance_actual,
+			o.duration_actual,
+			o.ride_type,
+			ofs.discount_amount AS shared_discount,
 			o.status,
 			o.payment_method,
 			o.payment_status,
+			o.company_id,
+			o.cost_center,
+			wt.transaction_id AS wallet_transaction_id,
+			wt.amount AS wallet_captured_amount,
+			o.completed_at,
 			o.created_at,
 			o.updated_at,
 
@@ -170,7 +196,15 @@ func (r *OrderRepository) OrderDetail(ctx context.Context, id string) (*entity.O
 			pc.discount_value,
 			pc.max_discount
 		FROM orders o
-		LEFT JOIN payment_transactions pt ON pt.ride_order_id = o.order_id
+		LEFT JOIN payment_transactions pt ON pt.id = (
+			-- expired QRs leave several rows, the paid one wins over the latest
+			SELECT p.id FROM payment_transactions p
+			WHERE p.ride_order_id = o.order_id
+			ORDER BY p.payment_status = 'PAID' DESC, p.id DESC
+			LIMIT 1
+		)
+		LEFT JOIN wallet_transactions wt ON wt.order_id = o.order_id AND wt.type = 'capture'
+		LEFT JOIN order_fare_splits ofs ON ofs.order_id = o.order_id
 		LEFT JOIN promo_redemptions pr ON pr.ride_order_id = o.order_id
 		LEFT JOIN promo_campaigns pc ON pc.id = pr.promo_campaign_id
 		WHERE o.order_id = ?
@@ -185,7 +219,7 @@ func (r *OrderRepository) OrderDetail(ctx context.Context, id string) (*entity.O
 }
 
 func (r *OrderRepository) InsertOrder(ctx context.Context, order *entity.CreateOrder) error {
-	db, err := r.DB.GetDB()
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return fmt.Errorf("failed get db: %w", err)
 	}
@@ -228,6 +262,8 @@ func (r *OrderRepository) InsertOrder(ctx context.Context, order *entity.CreateO
 	status := defaultString(order.Status, "REQUESTED")
 	paymentMethod := defaultString(order.PaymentMethod, "WALLET")
 	paymentStatus := defaultString(order.PaymentStatus, "UNPAID")
+	dispatchMode := defaultString(order.DispatchMode, "MANUAL")
+	rideType := defaultString(order.RideType, "PRIVATE")
 
 	query := `
 		INSERT INTO orders (
@@ -248,11 +284,15 @@ func (r *OrderRepository) InsertOrder(ctx context.Context, order *entity.CreateO
 			status,
 			payment_method,
 			payment_status,
+			company_id,
+			cost_center,
+			dispatch_mode,
+			ride_type,
 			estimated_fare,
 			distance_km,
 			distance_actual,
 			duration_actual
-		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 	`
 
 	_, err = db.ExecContext(ctx, query,
@@ -273,6 +313,10 @@ func (r *OrderRepository) InsertOrder(ctx context.Context, order *entity.CreateO
 		status,
 		paymentMethod,
 		paymentStatus,
+		order.CompanyID,
+		order.CostCenter,
+		dispatchMode,
+		rideType,
 		estimatedFare,
 		distanceKm,
 		distanceActual,
@@ -287,7 +331,7 @@ func (r *OrderRepository) InsertOrder(ctx context.Context, order *entity.CreateO
 }
 
 func (r *OrderRepository) UpdateOrder(ctx context.Context, req *entity.UpdateOrderRequest) error {
-	db, err := r.DB.GetDB()
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return err
 	}
@@ -310,7 +354,11 @@ func (r *OrderRepository) UpdateOrder(ctx context.Context, req *entity.UpdateOrd
 			best_route_duration = ?,
 			status = ?,
 			payment_method = ?,
-			payment_status = ?
+			payment_status = ?,
+			company_id = ?,
+			cost_center = ?,
+			dispatch_mode = ?,
+			ride_type = ?
 		WHERE id = ?
 	`
 
@@ -337,6 +385,10 @@ func (r *OrderRepository) UpdateOrder(ctx context.Context, req *entity.UpdateOrd
 		req.Status,
 		req.PaymentMethod,
 		req.PaymentStatus,
+		req.CompanyID,
+		req.CostCenter,
+		defaultString(req.DispatchMode, "MANUAL"),
+		defaultString(req.RideType, "PRIVATE"),
 		req.ID,
 	)
 
@@ -344,7 +396,7 @@ func (r *OrderRepository) UpdateOrder(ctx context.Context, req *entity.UpdateOrd
 }
 
 func (r *OrderRepository) AssignDriverToOrder(ctx context.Context, orderID string, passengerID string, driverID string) (bool, error) {
-	db, err := r.DB.GetDB()
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return false, err
 	}
@@ -371,8 +423,10 @@ func (r *OrderRepository) AssignDriverToOrder(ctx context.Context, orderID strin
 	return rows > 0, nil
 }
 
-func (r *OrderRepository) UpdateStatusOrder(ctx context.Context, orderID string, status string) (bool, error) {
-	db, err := r.DB.GetDB()
+// UpdateStatusOrder moves the order from fromStatus to toStatus. It returns false
+// when the order is no longer in fromStatus, a concurrent transition won.
+func (r *OrderRepository) UpdateStatusOrder(ctx context.Context, orderID, fromStatus, toStatus string) (bool, error) {
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return false, err
 	}
@@ -381,9 +435,54 @@ func (r *OrderRepository) UpdateStatusOrder(ctx context.Context, orderID string,
 		UPDATE orders
 		SET status = ?
 		WHERE order_id = ?
+		  AND status = ?
 	`
 
-	res, err := db.ExecContext(ctx, query, status, orderID)
+	res, err := db.ExecContext(ctx, query, toStatus, orderID, fromStatus)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (r *OrderRepository) UpdatePaymentStatus(ctx context.Context, orderID string, paymentStatus string) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE orders
+		SET payment_status = ?
+		WHERE order_id = ?
+	`
+
+	_, err = db.ExecContext(ctx, query, paymentStatus, orderID)
+	return err
+}
+
+// TransitionPaymentStatus moves the payment status of an order only when it is
+// still the one the caller read.
+func (r *OrderRepository) TransitionPaymentStatus(ctx context.Context, orderID, fromStatus, toStatus string) (bool, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE orders
+		SET payment_status = ?
+		WHERE order_id = ?
+		  AND payment_status = ?
+	`
+
+	res, err := db.ExecContext(ctx, query, toStatus, orderID, fromStatus)
 	if err != nil {
 		return false, err
 	}
@@ -397,7 +496,7 @@ func (r *OrderRepository) UpdateStatusOrder(ctx context.Context, orderID string,
 }
 
 func (r *OrderRepository) UpdateStatusOrderForDriver(ctx context.Context, orderID, driverID, fromStatus, toStatus string) (bool, error) {
-	db, err := r.DB.GetDB()
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return false, err
 	}
@@ -423,8 +522,8 @@ func (r *OrderRepository) UpdateStatusOrderForDriver(ctx context.Context, orderI
 	return rows > 0, nil
 }
 
-func (r *OrderRepository) CompleteTrip(ctx context.Context, orderID, driverID string, distanceActual float64, durationActual string) (bool, error) {
-	db, err := r.DB.GetDB()
+func (r *OrderRepository) CompleteTrip(ctx context.Context, orderID, driverID string, distanceActual float64, durationActual string, finalFare float64) (bool, error) {
+	db, err := mysql.Conn(ctx, r.DB)
 	if err != nil {
 		return false, err
 	}
@@ -435,13 +534,68 @@ func (r *OrderRepository) CompleteTrip(ctx context.Context, orderID, driverID st
 			status = 'COMPLETED',
 			distance_actual = ?,
 			duration_actual = ?,
+			final_fare = ?,
+			completed_at = NOW(6),
 			updated_at = NOW()
 		WHERE order_id = ?
 		  AND driver_id = ?
 		  AND status = 'ON_GOING'
 	`
 
-	res, err := db.ExecContext(ctx, query, distanceActual, durationActual, orderID, driverID)
+	res, err := db.ExecContext(ctx, query, distanceActual, durationActual, finalFare, orderID, driverID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// ExpireOrder moves an order nobody picked up to EXPIRED, it returns false when the
+// order already left the matching phase.
+// InsertTripTrack stores the tracked path of a trip, completing a trip again
+// keeps the first one.
+func (r *OrderRepository) InsertTripTrack(ctx context.Context, track *entity.TripTrack) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO trip_tracks (order_id, driver_id, distance_km, point_count, path, started_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE order_id = order_id
+	`
+	_, err = db.ExecContext(ctx, query,
+		track.OrderID,
+		track.DriverID,
+		track.DistanceKm,
+		track.PointCount,
+		track.Path,
+		track.StartedAt,
+		track.EndedAt,
+	)
+	return err
+}
+
+func (r *OrderRepository) ExpireOrder(ctx context.Context, orderID string) (bool, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE orders
+		SET status = 'EXPIRED'
+		WHERE order_id = ?
+		  AND status IN ('REQUESTED','MATCHING')
+	`
+
+	res, err := db.ExecContext(ctx, query, orderID)
 	if err != nil {
 		return false, err
 	}
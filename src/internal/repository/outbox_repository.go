@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+	"time"
+)
+
+const (
+	OutboxPending = "PENDING"
+	OutboxSent    = "SENT"
+	OutboxFailed  = "FAILED"
+)
+
+type OutboxRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewOutboxRepository(db mysql.DBInterface) *OutboxRepository {
+	return &OutboxRepository{
+		DB: db,
+	}
+}
+
+// WithinTx runs fn in one transaction, the order changes and outbox messages
+// written with the context fn receives commit together.
+func (r *OutboxRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return mysql.WithinTx(ctx, r.DB, fn)
+}
+
+// InsertMessage writes the message in the transaction carried by ctx.
+func (r *OutboxRepository) InsertMessage(ctx context.Context, message *entity.OutboxMessage) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO outbox_messages (topic, message_key, payload, headers, status)
+		VALUES (?, ?, ?, ?, ?)
+	`, message.Topic, message.MessageKey, message.Payload, message.Headers, OutboxPending)
+	return err
+}
+
+// FindDueMessages returns the pending messages ready to publish, oldest first. A
+// message waits while an older message with the same key is backing off, so the
+// messages of one key are published in the order they were written.
+func (r *OutboxRepository) FindDueMessages(ctx context.Context, limit int) ([]entity.OutboxMessage, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []entity.OutboxMessage
+	query := `
+		SELECT m.id, m.topic, m.message_key, m.payload, m.headers, m.status, m.attempts, m.last_error,
+			m.available_at, m.sent_at, m.created_at
+		FROM outbox_messages m
+		WHERE m.status = ?
+		  AND m.available_at <= NOW(6)
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM outbox_messages earlier
+			WHERE earlier.message_key = m.message_key
+			  AND earlier.status = ?
+			  AND earlier.id < m.id
+			  AND earlier.available_at > NOW(6)
+		  )
+		ORDER BY m.id
+		LIMIT ?
+	`
+	if err := db.SelectContext(ctx, &messages, query, OutboxPending, OutboxPending, limit); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id uint64) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE outbox_messages
+		SET status = ?, attempts = attempts + 1, last_error = NULL, sent_at = NOW(6)
+		WHERE id = ?
+	`, OutboxSent, id)
+	return err
+}
+
+// MarkRetry keeps the message pending until availableAt, a failed message is left
+// for an operator once it ran out of attempts.
+func (r *OutboxRepository) MarkRetry(ctx context.Context, id uint64, reason string, availableAt time.Time, failed bool) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	status := OutboxPending
+	if failed {
+		status = OutboxFailed
+	}
+	if len(reason) > 512 {
+		reason = reason[:512]
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE outbox_messages
+		SET status = ?, attempts = attempts + 1, last_error = ?, available_at = ?
+		WHERE id = ?
+	`, status, reason, availableAt, id)
+	return err
+}
+
+// DeleteSent removes the messages published before the given time.
+func (r *OutboxRepository) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM outbox_messages
+		WHERE status = ?
+		  AND sent_at < ?
+		LIMIT 5000
+	`, OutboxSent, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+	"time"
+)
+
+var ErrPaymentNotFound = errors.New("payment transaction not found")
+
+const (
+	PaymentPending = "PENDING"
+	PaymentPaid    = "PAID"
+	PaymentFailed  = "FAILED"
+	PaymentExpired = "EXPIRED"
+	// PaymentReversed is a debit made after the order was already paid, it was
+	// credited back to the passenger
+	PaymentReversed = "REVERSED"
+	// PaymentRefundDue is a QR paid after the order was already paid another way,
+	// the passenger is owed the amount back through reconciliation
+	PaymentRefundDue = "REFUND_DUE"
+)
+
+const (
+	CollectionOpen     = "OPEN"
+	CollectionResolved = "RESOLVED"
+)
+
+type PaymentRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewPaymentRepository(db mysql.DBInterface) *PaymentRepository {
+	return &PaymentRepository{
+		DB: db,
+	}
+}
+
+// FindPaymentByOrder returns the latest payment transaction of a ride order.
+func (r *PaymentRepository) FindPaymentByOrder(ctx context.Context, orderID string) (*entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var payment entity.PaymentTransaction
+	query := `
+		SELECT id, ride_order_id, amount, payment_status, provider_name, provider_reference_id, qr_payload, expires_at, paid_at
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	if err := db.GetContext(ctx, &payment, query, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) UpdatePaymentStatus(ctx context.Context, id uint64, status string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE payment_transactions SET payment_status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// InsertPendingPayment records a payment waiting for the provider callback.
+func (r *PaymentRepository) InsertPendingPayment(ctx context.Context, payment *entity.PaymentTransaction) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO payment_transactions (
+			ride_order_id, amount, payment_status, provider_name, provider_reference_id, qr_payload, expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	res, err := db.ExecContext(ctx, query,
+		payment.RideOrderID,
+		payment.Amount,
+		PaymentPending,
+		payment.ProviderName,
+		payment.ProviderReferenceID,
+		payment.QRPayload,
+		payment.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	payment.ID = uint64(id)
+	payment.PaymentStatus = PaymentPending
+	return nil
+}
+
+func (r *PaymentRepository) FindPaymentByReference(ctx context.Context, providerName, providerReference string) (*entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var payment entity.PaymentTransaction
+	query := `
+		SELECT id, ride_order_id, amount, payment_status, provider_name, provider_reference_id, qr_payload, expires_at, paid_at
+		FROM payment_transactions
+		WHERE provider_name = ? AND provider_reference_id = ?
+	`
+	if err := db.GetContext(ctx, &payment, query, providerName, providerReference); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// SettlePayment moves a pending payment to its final status and marks the order
+// paid in the same transaction. Only the first callback for a payment settles it,
+// later deliveries return false and change nothing. The money of a QR paid after
+// it expired or failed was taken all the same: it pays an unpaid order, and is
+// recorded as PaymentRefundDue when the order was already paid.
+func (r *PaymentRepository) SettlePayment(ctx context.Context, providerName, providerReference, status string, paidAt time.Time) (*entity.PaymentTransaction, bool, error) {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var payment entity.PaymentTransaction
+	query := `
+		SELECT id, ride_order_id, amount, payment_status, provider_name, provider_reference_id, qr_payload, expires_at, paid_at
+		FROM payment_transactions
+		WHERE provider_name = ? AND provider_reference_id = ?
+		FOR UPDATE
+	`
+	if err := tx.GetContext(ctx, &payment, query, providerName, providerReference); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, ErrPaymentNotFound
+		}
+		return nil, false, err
+	}
+	late := status == PaymentPaid && (payment.PaymentStatus == PaymentExpired || payment.PaymentStatus == PaymentFailed)
+	if payment.PaymentStatus != PaymentPending && !late {
+		return &payment, false, nil
+	}
+
+	var paid *time.Time
+	if status == PaymentPaid {
+		paid = &paidAt
+		var orderStatus string
+		if err := tx.GetContext(ctx, &orderStatus, `SELECT payment_status FROM orders WHERE order_id = ? FOR UPDATE`, payment.RideOrderID); err != nil {
+			return nil, false, err
+		}
+		if orderStatus == PaymentPaid {
+			// another QR of the order was paid first
+			status = PaymentRefundDue
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payment_transactions SET payment_status = ?, paid_at = ? WHERE id = ? AND payment_status = ?
+	`, status, paid, payment.ID, payment.PaymentStatus); err != nil {
+		return nil, false, err
+	}
+	if status == PaymentPaid {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE orders SET payment_status = ? WHERE order_id = ? AND payment_status <> ?
+		`, PaymentPaid, payment.RideOrderID, PaymentPaid); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	payment.PaymentStatus = status
+	payment.PaidAt = paid
+	return &payment, true, nil
+}
+
+// InsertSettledPayment records a payment another service already settled. The
+// provider reference is unique, a redelivered result returns false and changes
+// nothing.
+func (r *PaymentRepository) InsertSettledPayment(ctx context.Context, payment *entity.PaymentTransaction) (bool, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO payment_transactions (
+			ride_order_id, amount, payment_status, provider_name, provider_reference_id, paid_at
+		) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id
+	`
+	res, err := db.ExecContext(ctx, query,
+		payment.RideOrderID,
+		payment.Amount,
+		payment.PaymentStatus,
+		payment.ProviderName,
+		payment.ProviderReferenceID,
+		payment.PaidAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return false, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	payment.ID = uint64(id)
+	return true, nil
+}
+
+// FlagForCollection opens the collection of an order, a later failure of the same
+// order reopens it with the latest amount and reason.
+func (r *PaymentRepository) FlagForCollection(ctx context.Context, collection *entity.PaymentCollection) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO payment_collections (order_id, passenger_id, amount, reason, status, payment_transaction_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			amount = VALUES(amount),
+			reason = VALUES(reason),
+			status = VALUES(status),
+			payment_transaction_id = VALUES(payment_transaction_id)
+	`
+	_, err = db.ExecContext(ctx, query,
+		collection.OrderID,
+		collection.PassengerID,
+		collection.Amount,
+		collection.Reason,
+		CollectionOpen,
+		collection.PaymentTransactionID,
+	)
+	return err
+}
+
+// ResolveCollection closes the open collection of an order with the payment that
+// settled it.
+func (r *PaymentRepository) ResolveCollection(ctx context.Context, orderID string, paymentTransactionID uint64) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE payment_collections SET status = ?, payment_transaction_id = ? WHERE order_id = ? AND status = ?
+	`, CollectionResolved, paymentTransactionID, orderID, CollectionOpen)
+	return err
+}
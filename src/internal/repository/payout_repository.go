@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+	"strings"
+	"time"
+)
+
+var ErrPayoutBatchClosed = errors.New("payout batch is no longer pending")
+
+const (
+	PayoutPending    = "PENDING"
+	PayoutProcessing = "PROCESSING"
+	PayoutPaid       = "PAID"
+	PayoutFailed     = "FAILED"
+)
+
+type PayoutRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewPayoutRepository(db mysql.DBInterface) *PayoutRepository {
+	return &PayoutRepository{
+		DB: db,
+	}
+}
+
+// FindPayableOrders returns the paid wallet and QRIS orders and the billed
+// corporate orders completed before the cutoff that are not in a payout batch yet.
+// The fare is what was actually collected, the wallet capture or the paid QRIS
+// transaction, and the final fare invoiced to the company.
+func (r *PayoutRepository) FindPayableOrders(ctx context.Context, before time.Time) ([]entity.PayableOrder, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []entity.PayableOrder
+	query := `
+		SELECT
+			o.order_id,
+			o.driver_id,
+			o.payment_method,
+			COALESCE(i.city, '') AS city,
+			COALESCE(i.jenis_kendaraan, '') AS vehicle_type,
+			COALESCE(pt.amount, wt.amount, o.final_fare, 0) AS fare,
+			o.completed_at
+		FROM orders o
+		LEFT JOIN info_driver i ON i.driver_id = o.driver_id
+		LEFT JOIN payment_transactions pt ON pt.ride_order_id = o.order_id AND pt.payment_status = 'PAID'
+		LEFT JOIN wallet_transactions wt ON wt.order_id = o.order_id AND wt.type = 'capture'
+		LEFT JOIN payout_items pi ON pi.order_id = o.order_id
+		WHERE o.status = 'COMPLETED'
+		  AND (
+			(o.payment_method IN ('EWALLET', 'QRIS') AND o.payment_status = 'PAID')
+			OR (o.payment_method = 'CORPORATE' AND o.payment_status = 'BILLED')
+		  )
+		  AND o.driver_id IS NOT NULL
+		  AND o.completed_at < ?
+		  AND pi.id IS NULL
+		ORDER BY o.driver_id, o.completed_at
+	`
+	if err := db.SelectContext(ctx, &orders, query, before); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// AddToBatch opens the batch of a driver for a payout date when needed and adds
+// the items to it, the totals are recomputed from the items. Orders already in a
+// batch are skipped, a batch past PENDING returns ErrPayoutBatchClosed.
+func (r *PayoutRepository) AddToBatch(ctx context.Context, batch *entity.PayoutBatch, items []entity.PayoutItem) (*entity.PayoutBatch, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO payout_batches (batch_id, driver_id, payout_date, status)
+		VALUES (?, ?, ?, ?)
+	`, batch.BatchID, batch.DriverID, batch.PayoutDate.Format("2006-01-02"), PayoutPending); err != nil {
+		return nil, err
+	}
+
+	var current entity.PayoutBatch
+	if err := tx.GetContext(ctx, &current, `
+		SELECT id, batch_id, driver_id, payout_date, trip_count, gross_amount, commission_amount, net_amount,
+			status, attempts, failure_reason, paid_at, created_at, updated_at
+		FROM payout_batches
+		WHERE batch_id = ?
+		FOR UPDATE
+	`, batch.BatchID); err != nil {
+		return nil, err
+	}
+	if current.Status != PayoutPending {
+		return &current, ErrPayoutBatchClosed
+	}
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO payout_items
+				(batch_id, order_id, payment_method, city, vehicle_type, fare, commission_rate, commission, earning, completed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, batch.BatchID, item.OrderID, item.PaymentMethod, item.City, item.VehicleType, item.Fare,
+			item.CommissionRate, item.Commission, item.Earning, item.CompletedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payout_batches b
+		JOIN (
+			SELECT batch_id, COUNT(*) AS trips, SUM(fare) AS gross, SUM(commission) AS commission, SUM(earning) AS net
+			FROM payout_items
+			WHERE batch_id = ?
+			GROUP BY batch_id
+		) t ON t.batch_id = b.batch_id
+		SET b.trip_count = t.trips, b.gross_amount = t.gross, b.commission_amount = t.commission, b.net_amount = t.net
+	`, batch.BatchID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetBatch(ctx, batch.BatchID)
+}
+
+func (r *PayoutRepository) GetBatch(ctx context.Context, batchID string) (*entity.PayoutBatch, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var batch entity.PayoutBatch
+	query := `
+		SELECT id, batch_id, driver_id, payout_date, trip_count, gross_amount, commission_amount, net_amount,
+			status, attempts, failure_reason, paid_at, created_at, updated_at
+		FROM payout_batches
+		WHERE batch_id = ?
+	`
+	if err := db.GetContext(ctx, &batch, query, batchID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *PayoutRepository) FindItems(ctx context.Context, batchID string) ([]entity.PayoutItem, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []entity.PayoutItem
+	query := `
+		SELECT id, batch_id, order_id, payment_method, city, vehicle_type, fare, commission_rate, commission, earning, completed_at, created_at
+		FROM payout_items
+		WHERE batch_id = ?
+		ORDER BY completed_at
+	`
+	if err := db.SelectContext(ctx, &items, query, batchID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MarkProcessing claims a pending or failed batch for disbursement.
+func (r *PayoutRepository) MarkProcessing(ctx context.Context, batchID string) (bool, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE payout_batches
+		SET status = ?, attempts = attempts + 1, failure_reason = NULL
+		WHERE batch_id = ? AND status IN (?, ?)
+	`, PayoutProcessing, batchID, PayoutPending, PayoutFailed)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// PayBatch credits the net amount of a processing batch to the driver wallet and
+// marks it paid. The batch id is the wallet transaction id so a retried payout
+// never credits twice.
+func (r *PayoutRepository) PayBatch(ctx context.Context, batchID string) (*entity.PayoutBatch, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var batch entity.PayoutBatch
+	if err := tx.GetContext(ctx, &batch, `
+		SELECT id, batch_id, driver_id, payout_date, net_amount, status
+		FROM payout_batches
+		WHERE batch_id = ?
+		FOR UPDATE
+	`, batchID); err != nil {
+		return nil, err
+	}
+	if batch.Status == PayoutPaid {
+		return &batch, nil
+	}
+
+	wallet, err := lockDriverWallet(ctx, tx, batch.DriverID)
+	if err != nil {
+		return nil, err
+	}
+	description := fmt.Sprintf("Payout of %s", batch.PayoutDate.Format("2006-01-02"))
+	inserted, err := insertWalletTransaction(ctx, tx, wallet.ID, batch.BatchID, "", batch.NetAmount, "payout", description)
+	if err != nil {
+		return nil, err
+	}
+	if inserted {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE wallets SET balance = balance + ?, last_updated = NOW(6)
+			WHERE id = ?
+		`, batch.NetAmount, wallet.ID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payout_batches SET status = ?, paid_at = NOW(6) WHERE id = ?
+	`, PayoutPaid, batch.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetBatch(ctx, batchID)
+}
+
+func (r *PayoutRepository) MarkFailed(ctx context.Context, batchID, reason string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE payout_batches SET status = ?, failure_reason = ? WHERE batch_id = ? AND status = ?
+	`, PayoutFailed, reason, batchID, PayoutProcessing)
+	return err
+}
+
+// FindBatches returns a page of payout batches, newest payout date first, with
+// the number of batches matching the filter.
+func (r *PayoutRepository) FindBatches(ctx context.Context, f entity.PayoutFilter) ([]entity.PayoutBatch, int64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conds := []string{"1 = 1"}
+	args := []interface{}{}
+	if f.DriverID != nil {
+		conds = append(conds, "driver_id = ?")
+		args = append(args, *f.DriverID)
+	}
+	if f.Status != nil {
+		conds = append(conds, "status = ?")
+		args = append(args, *f.Status)
+	}
+	if f.From != nil {
+		conds = append(conds, "payout_date >= ?")
+		args = append(args, f.From.Format("2006-01-02"))
+	}
+	if f.To != nil {
+		conds = append(conds, "payout_date < ?")
+		args = append(args, f.To.Format("2006-01-02"))
+	}
+	where := strings.Join(conds, " AND ")
+
+	var total int64
+	if err := db.GetContext(ctx, &total, fmt.Sprintf(`SELECT COUNT(*) FROM payout_batches WHERE %s`, where), args...); err != nil {
+		return nil, 0, err
+	}
+
+	var batches []entity.PayoutBatch
+	query := fmt.Sprintf(`
+		SELECT id, batch_id, driver_id, payout_date, trip_count, gross_amount, commission_amount, net_amount,
+			status, attempts, failure_reason, paid_at, created_at, updated_at
+		FROM payout_batches
+		WHERE %s
+		ORDER BY payout_date DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, where)
+	if err := db.SelectContext(ctx, &batches, query, append(args, f.Limit, f.Offset)...); err != nil {
+		return nil, 0, err
+	}
+	return batches, total, nil
+}
+
+// Report sums the payout batches per payout date and status.
+func (r *PayoutRepository) Report(ctx context.Context, from, to time.Time) ([]entity.PayoutReportRow, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []entity.PayoutReportRow
+	query := `
+		SELECT
+			payout_date,
+			status,
+			COUNT(*) AS batches,
+			COALESCE(SUM(trip_count), 0) AS trip_count,
+			COALESCE(SUM(gross_amount), 0) AS gross_amount,
+			COALESCE(SUM(commission_amount), 0) AS commission_amount,
+			COALESCE(SUM(net_amount), 0) AS net_amount
+		FROM payout_batches
+		WHERE payout_date >= ? AND payout_date < ?
+		GROUP BY payout_date, status
+		ORDER BY payout_date, status
+	`
+	if err := db.SelectContext(ctx, &rows, query, from.Format("2006-01-02"), to.Format("2006-01-02")); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+	"time"
+)
+
+const (
+	RefundPending   = "PENDING"
+	RefundSucceeded = "SUCCEEDED"
+	RefundFailed    = "FAILED"
+)
+
+type RefundRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewRefundRepository(db mysql.DBInterface) *RefundRepository {
+	return &RefundRepository{
+		DB: db,
+	}
+}
+
+// InsertRefund records a refund once, a second insert with the same refund_id
+// keeps the first row and returns it. It joins the transaction carried by ctx.
+func (r *RefundRepository) InsertRefund(ctx context.Context, refund *entity.Refund) (*entity.Refund, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT IGNORE INTO refunds
+			(refund_id, order_id, passenger_id, payment_method, stage, reason, fee_amount, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = db.ExecContext(ctx, query,
+		refund.RefundID,
+		refund.OrderID,
+		refund.PassengerID,
+		refund.PaymentMethod,
+		refund.Stage,
+		refund.Reason,
+		refund.FeeAmount,
+		RefundPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRefund(ctx, refund.RefundID)
+}
+
+func (r *RefundRepository) GetRefund(ctx context.Context, refundID string) (*entity.Refund, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var refund entity.Refund
+	query := `
+		SELECT id, refund_id, order_id, passenger_id, payment_method, payment_transaction_id, stage, reason,
+			fee_amount, amount, status, attempts, provider_reference, last_error, created_at, updated_at
+		FROM refunds
+		WHERE refund_id = ?
+		LIMIT 1
+	`
+	if err := db.GetContext(ctx, &refund, query, refundID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundRepository) FindRefundsByOrder(ctx context.Context, orderID string) ([]entity.Refund, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var refunds []entity.Refund
+	query := `
+		SELECT id, refund_id, order_id, passenger_id, payment_method, payment_transaction_id, stage, reason,
+			fee_amount, amount, status, attempts, provider_reference, last_error, created_at, updated_at
+		FROM refunds
+		WHERE order_id = ?
+		ORDER BY created_at ASC
+	`
+	if err := db.SelectContext(ctx, &refunds, query, orderID); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// FindPendingRefunds lists the refunds recorded before the given time that no
+// refund task has attempted yet, oldest first.
+func (r *RefundRepository) FindPendingRefunds(ctx context.Context, before time.Time, limit int) ([]entity.Refund, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var refunds []entity.Refund
+	query := `
+		SELECT id, refund_id, order_id, passenger_id, payment_method, payment_transaction_id, stage, reason,
+			fee_amount, amount, status, attempts, provider_reference, last_error, created_at, updated_at
+		FROM refunds
+		WHERE status = ?
+		  AND created_at < ?
+		ORDER BY created_at ASC
+		LIMIT ?
+	`
+	if err := db.SelectContext(ctx, &refunds, query, RefundPending, before, limit); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *RefundRepository) MarkSucceeded(ctx context.Context, refundID string, amount float64, paymentTransactionID *uint64, providerReference *string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE refunds
+		SET status = ?, amount = ?, payment_transaction_id = ?, provider_reference = ?,
+			attempts = attempts + 1, last_error = NULL
+		WHERE refund_id = ?
+		  AND status != ?
+	`
+	_, err = db.ExecContext(ctx, query, RefundSucceeded, amount, paymentTransactionID, providerReference, refundID, RefundSucceeded)
+	return err
+}
+
+func (r *RefundRepository) MarkFailed(ctx context.Context, refundID, lastError string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	query := `
+		UPDATE refunds
+		SET status = ?, attempts = attempts + 1, last_error = ?
+		WHERE refund_id = ?
+		  AND status != ?
+	`
+	_, err = db.ExecContext(ctx, query, RefundFailed, lastError, refundID, RefundSucceeded)
+	return err
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"order-service/src/internal/entity"
+	"order-service/src/pkg/databases/mysql"
+)
+
+// ErrSeatTaken is returned by JoinGroup once the seat was taken but the order could
+// not be assigned, the caller rolls back the transaction it joined to free the seat.
+var ErrSeatTaken = errors.New("shared ride seat taken for an unassignable order")
+
+type SharedRideRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewSharedRideRepository(db mysql.DBInterface) *SharedRideRepository {
+	return &SharedRideRepository{
+		DB: db,
+	}
+}
+
+func (r *SharedRideRepository) FindOpenGroups(ctx context.Context) ([]entity.SharedRideGroup, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []entity.SharedRideGroup
+	query := `
+		SELECT id, group_id, driver_id, seats_total, seats_taken, status, created_at, updated_at
+		FROM shared_ride_groups
+		WHERE status = 'OPEN'
+		  AND seats_taken < seats_total
+		ORDER BY created_at ASC
+	`
+	if err := db.SelectContext(ctx, &groups, query); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (r *SharedRideRepository) InsertFareSplit(ctx context.Context, split *entity.OrderFareSplit) error {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO order_fare_splits (order_id, base_fare, discount_amount, fare)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			base_fare = VALUES(base_fare),
+			discount_amount = VALUES(discount_amount),
+			fare = VALUES(fare)
+	`
+	_, err = db.ExecContext(ctx, query, split.OrderID, split.BaseFare, split.DiscountAmount, split.Fare)
+	return err
+}
+
+// CreateGroup opens a shared ride group for the driver of the first pooled order.
+func (r *SharedRideRepository) CreateGroup(ctx context.Context, groupID, driverID string, seatsTotal int, orderID string) error {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status := "OPEN"
+	if seatsTotal <= 1 {
+		status = "FULL"
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO shared_ride_groups (group_id, driver_id, seats_total, seats_taken, status)
+		VALUES (?, ?, ?, 1, ?)
+	`, groupID, driverID, seatsTotal, status)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE orders SET shared_group_id = ? WHERE order_id = ?`, groupID, orderID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE order_fare_splits SET group_id = ? WHERE order_id = ?`, groupID, orderID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// JoinGroup takes a seat in the group and assigns the group driver to the order in
+// one transaction. It returns false when the group is full and ErrSeatTaken when
+// the order was taken, the seat is only freed once the transaction rolls back.
+func (r *SharedRideRepository) JoinGroup(ctx context.Context, groupID, orderID, passengerID, driverID string) (bool, error) {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// MySQL applies the assignments left to right, status sees the incremented seats_taken
+	res, err := tx.ExecContext(ctx, `
+		UPDATE shared_ride_groups
+		SET seats_taken = seats_taken + 1,
+		    status = IF(seats_taken >= seats_total, 'FULL', 'OPEN')
+		WHERE group_id = ?
+		  AND driver_id = ?
+		  AND status = 'OPEN'
+		  AND seats_taken < seats_total
+	`, groupID, driverID)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		UPDATE orders
+		SET driver_id = ?, status = 'ACCEPTED', shared_group_id = ?
+		WHERE order_id = ?
+		  AND passenger_id = ?
+		  AND (driver_id IS NULL OR driver_id = '')
+		  AND status IN ('REQUESTED','MATCHING')
+	`, driverID, groupID, orderID, passengerID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		// a joined transaction keeps the seat until its owner rolls back
+		return false, ErrSeatTaken
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE order_fare_splits SET group_id = ? WHERE order_id = ?`, groupID, orderID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// LeaveGroup frees the seat of a completed or cancelled order, the group is closed
+// once the last passenger leaves.
+func (r *SharedRideRepository) LeaveGroup(ctx context.Context, groupID string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	// MySQL applies the assignments left to right, status sees the decremented seats_taken
+	_, err = db.ExecContext(ctx, `
+		UPDATE shared_ride_groups
+		SET seats_taken = GREATEST(seats_taken - 1, 0),
+		    status = IF(seats_taken = 0, 'CLOSED', IF(status = 'FULL', 'OPEN', status))
+		WHERE group_id = ?
+		  AND status != 'CLOSED'
+	`, groupID)
+	return err
+}
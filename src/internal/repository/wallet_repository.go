@@ -3,8 +3,37 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"order-service/src/internal/entity"
 	"order-service/src/pkg/databases/mysql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrHoldNotFound        = errors.New("wallet hold not found")
+)
+
+// InsufficientBalanceError is returned when the wallet balance does not cover the
+// amount to hold, it matches ErrInsufficientBalance.
+type InsufficientBalanceError struct {
+	Balance float64
+	Amount  float64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("%s: balance %.2f, required %.2f", ErrInsufficientBalance, e.Balance, e.Amount)
+}
+
+func (e *InsufficientBalanceError) Unwrap() error { return ErrInsufficientBalance }
+
+const (
+	WalletHoldHeld     = "HELD"
+	WalletHoldCaptured = "CAPTURED"
+	WalletHoldReleased = "RELEASED"
 )
 
 type WalletRepository struct {
@@ -25,7 +54,7 @@ func (r *WalletRepository) GetWalletByUserID(ctx context.Context, userID string)
 
 	var w entity.Wallet
 	query := `
-		SELECT id, user_id, balance, last_updated, created_at, updated_at
+		SELECT id, user_id, balance, held_balance, last_updated, created_at, updated_at
 		FROM wallets
 		WHERE user_id = ?
 		LIMIT 1
@@ -39,7 +68,200 @@ func (r *WalletRepository) GetWalletByUserID(ctx context.Context, userID string)
 	return &w, nil
 }
 
-func (r *WalletRepository) TopUp(ctx context.Context, userID string, amount float64) error {
+// TopUp credits the wallet under the given transaction id, it returns false when
+// the transaction was already applied.
+func (r *WalletRepository) TopUp(ctx context.Context, userID, transactionID string, amount float64) (bool, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Lock wallet
+	var wallet entity.Wallet
+	err = tx.GetContext(ctx, &wallet, `
+		SELECT id, balance FROM wallets
+		WHERE user_id = ?
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+
+	// Insert transaction log, a replayed transaction id leaves the balance untouched
+	res, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO wallet_transactions
+			(wallet_id, transaction_id, amount, type, description)
+		VALUES (?, ?, ?, 'credit', 'Top up')
+	`, wallet.ID, transactionID, amount)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+
+	// Update balance
+	_, err = tx.ExecContext(ctx, `
+		UPDATE wallets SET balance = balance + ?, last_updated = NOW(6)
+		WHERE id = ?
+	`, amount, wallet.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Refund credits a refund for an order to the passenger wallet, it returns false
+// when the transaction was already applied. It joins the transaction carried by
+// ctx.
+func (r *WalletRepository) Refund(ctx context.Context, userID, orderID, transactionID string, amount float64) (bool, error) {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var walletID string
+	if err := tx.GetContext(ctx, &walletID, `SELECT id FROM wallets WHERE user_id = ? FOR UPDATE`, userID); err != nil {
+		return false, err
+	}
+	inserted, err := insertWalletTransaction(ctx, tx.Tx, walletID, transactionID, orderID, amount, "refund", "Refund for order")
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE wallets SET balance = balance + ?, last_updated = NOW(6)
+		WHERE id = ?
+	`, amount, walletID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetTransaction returns the ledger entry stored under the transaction id, nil
+// when there is none.
+func (r *WalletRepository) GetTransaction(ctx context.Context, transactionID string) (*entity.WalletTransaction, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction entity.WalletTransaction
+	query := `
+		SELECT id, wallet_id, transaction_id, order_id, amount, type, description, timestamp, created_at
+		FROM wallet_transactions
+		WHERE transaction_id = ?
+		LIMIT 1
+	`
+	if err := db.GetContext(ctx, &transaction, query, transactionID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// CreateWallet opens an empty wallet for the user, an existing wallet is returned as is.
+func (r *WalletRepository) CreateWallet(ctx context.Context, userID string) (*entity.Wallet, bool, error) {
+	wallet, err := r.GetWalletByUserID(ctx, userID)
+	if err != nil || wallet != nil {
+		return wallet, false, err
+	}
+
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO wallets (id, user_id, balance, held_balance, last_updated)
+		VALUES (UUID(), ?, 0, 0, NOW(6))
+	`, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	wallet, err = r.GetWalletByUserID(ctx, userID)
+	return wallet, true, err
+}
+
+// FindTransactions returns a page of the user's wallet transactions, newest first,
+// with the number of transactions matching the filter.
+func (r *WalletRepository) FindTransactions(ctx context.Context, f entity.WalletTransactionFilter) ([]entity.WalletTransaction, int64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conds := []string{"w.user_id = ?"}
+	args := []interface{}{f.UserID}
+	if len(f.Types) > 0 {
+		placeholders := make([]string, 0, len(f.Types))
+		for _, t := range f.Types {
+			placeholders = append(placeholders, "?")
+			args = append(args, t)
+		}
+		conds = append(conds, fmt.Sprintf("wt.type IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if f.From != nil {
+		conds = append(conds, "wt.created_at >= ?")
+		args = append(args, *f.From)
+	}
+	if f.To != nil {
+		conds = append(conds, "wt.created_at < ?")
+		args = append(args, *f.To)
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	var total int64
+	countQuery := `
+		SELECT COUNT(*)
+		FROM wallet_transactions wt
+		JOIN wallets w ON w.id = wt.wallet_id
+	` + where
+	if err := db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT 
+			wt.id,
+			wt.wallet_id,
+			wt.transaction_id,
+			wt.order_id,
+			wt.amount,
+			wt.type,
+			wt.description,
+			wt.timestamp,
+			wt.created_at
+		FROM wallet_transactions wt
+		JOIN wallets w ON w.id = wt.wallet_id
+	` + where + " ORDER BY wt.created_at DESC, wt.id DESC"
+	if f.Limit > 0 {
+		query = query + " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	var transactions []entity.WalletTransaction
+	if err := db.SelectContext(ctx, &transactions, query, args...); err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// PlaceHold moves amount from the wallet balance to its held balance for the order.
+// The HOLD-<orderId> transaction makes a repeated call a no-op.
+func (r *WalletRepository) PlaceHold(ctx context.Context, userID, orderID string, amount float64) error {
 	db, err := r.DB.GetDB()
 	if err != nil {
 		return err
@@ -51,7 +273,6 @@ func (r *WalletRepository) TopUp(ctx context.Context, userID string, amount floa
 	}
 	defer tx.Rollback()
 
-	// Lock wallet
 	var wallet entity.Wallet
 	err = tx.GetContext(ctx, &wallet, `
 		SELECT id, balance FROM wallets
@@ -62,25 +283,165 @@ func (r *WalletRepository) TopUp(ctx context.Context, userID string, amount floa
 		return err
 	}
 
-	// Update balance
-	newBalance := wallet.Balance + amount
+	inserted, err := insertWalletTransaction(ctx, tx, wallet.ID, fmt.Sprintf("HOLD-%s", orderID), orderID, amount, "hold", "Hold for order")
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return tx.Commit()
+	}
+	if wallet.Balance < amount {
+		return &InsufficientBalanceError{Balance: wallet.Balance, Amount: amount}
+	}
+
 	_, err = tx.ExecContext(ctx, `
-		UPDATE wallets SET balance = ?, last_updated = NOW(6)
+		UPDATE wallets
+		SET balance = balance - ?, held_balance = held_balance + ?, last_updated = NOW(6)
 		WHERE id = ?
-	`, newBalance, wallet.ID)
+	`, amount, amount, wallet.ID)
 	if err != nil {
 		return err
 	}
 
-	// Insert transaction log
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO wallet_transactions
-			(wallet_id, transaction_id, amount, type, description)
-		VALUES (?, UUID(), ?, 'credit', 'Top up')
-	`, wallet.ID, amount)
+		INSERT INTO wallet_holds (order_id, wallet_id, amount, status)
+		VALUES (?, ?, ?, ?)
+	`, orderID, wallet.ID, amount, WalletHoldHeld)
 	if err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
+
+// CaptureHold charges amount, capped at the held amount, and returns the rest of
+// the hold to the wallet balance. Capturing a settled hold returns it unchanged.
+func (r *WalletRepository) CaptureHold(ctx context.Context, orderID string, amount float64) (*entity.WalletHold, error) {
+	return r.settleHold(ctx, orderID, amount)
+}
+
+// ReleaseHold returns the whole hold of an order to the wallet balance.
+func (r *WalletRepository) ReleaseHold(ctx context.Context, orderID string) (*entity.WalletHold, error) {
+	return r.settleHold(ctx, orderID, 0)
+}
+
+func (r *WalletRepository) GetHold(ctx context.Context, orderID string) (*entity.WalletHold, error) {
+	db, err := mysql.Conn(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var hold entity.WalletHold
+	query := `
+		SELECT id, order_id, wallet_id, amount, captured_amount, released_amount, status, created_at, updated_at
+		FROM wallet_holds
+		WHERE order_id = ?
+		LIMIT 1
+	`
+	if err := db.GetContext(ctx, &hold, query, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// settleHold joins the transaction carried by ctx, so a capture commits with the
+// order change it pays for.
+func (r *WalletRepository) settleHold(ctx context.Context, orderID string, captureAmount float64) (*entity.WalletHold, error) {
+	tx, err := mysql.BeginTx(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the wallet before the hold, the same order PlaceHold takes the locks in
+	var walletID string
+	if err := tx.GetContext(ctx, &walletID, `SELECT wallet_id FROM wallet_holds WHERE order_id = ?`, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	var lockedID string
+	if err := tx.GetContext(ctx, &lockedID, `SELECT id FROM wallets WHERE id = ? FOR UPDATE`, walletID); err != nil {
+		return nil, err
+	}
+
+	var hold entity.WalletHold
+	err = tx.GetContext(ctx, &hold, `
+		SELECT id, order_id, wallet_id, amount, captured_amount, released_amount, status, created_at, updated_at
+		FROM wallet_holds
+		WHERE order_id = ?
+		FOR UPDATE
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != WalletHoldHeld {
+		return &hold, tx.Commit()
+	}
+
+	captured := captureAmount
+	if captured > hold.Amount {
+		captured = hold.Amount
+	}
+	if captured < 0 {
+		captured = 0
+	}
+	released := hold.Amount - captured
+	status := WalletHoldReleased
+	if captured > 0 {
+		status = WalletHoldCaptured
+		if _, err := insertWalletTransaction(ctx, tx.Tx, hold.WalletID, fmt.Sprintf("CAPTURE-%s", orderID), orderID, captured, "capture", "Payment for order"); err != nil {
+			return nil, err
+		}
+	}
+	if released > 0 {
+		if _, err := insertWalletTransaction(ctx, tx.Tx, hold.WalletID, fmt.Sprintf("RELEASE-%s", orderID), orderID, released, "release", "Release hold for order"); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE wallets
+		SET balance = balance + ?, held_balance = held_balance - ?, last_updated = NOW(6)
+		WHERE id = ?
+	`, released, hold.Amount, hold.WalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE wallet_holds
+		SET captured_amount = ?, released_amount = ?, status = ?
+		WHERE id = ?
+	`, captured, released, status, hold.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.CapturedAmount = captured
+	hold.ReleasedAmount = released
+	hold.Status = status
+	return &hold, tx.Commit()
+}
+
+// insertWalletTransaction writes a ledger entry under a deterministic transaction id,
+// it returns false when the entry already exists. An empty order id is stored as NULL.
+func insertWalletTransaction(ctx context.Context, tx *sqlx.Tx, walletID, transactionID, orderID string, amount float64, txType, description string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO wallet_transactions
+			(wallet_id, transaction_id, order_id, amount, type, description)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, walletID, transactionID, sql.NullString{String: orderID, Valid: orderID != ""}, amount, txType, description)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
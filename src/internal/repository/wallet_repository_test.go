@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/pkg/databases/mysql/mysqltest"
+	"testing"
+	"time"
+)
+
+func TestPlaceHold(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     float64
+		amount      float64
+		held        bool
+		wantErr     bool
+		wantBalance float64
+		wantHeld    float64
+	}{
+		{name: "covered by the balance", balance: 80000, amount: 50000, wantBalance: 30000, wantHeld: 50000},
+		{name: "whole balance", balance: 50000, amount: 50000, wantBalance: 0, wantHeld: 50000},
+		{name: "already held", balance: 30000, amount: 50000, held: true, wantBalance: 30000, wantHeld: 50000},
+		{name: "short balance", balance: 20000, amount: 50000, wantErr: true, wantBalance: 20000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newWalletStore(tt.balance)
+			if tt.held {
+				store.heldBalance = tt.amount
+				store.transactions["HOLD-order-1"] = tt.amount
+			}
+			db := mysqltest.New(store.handle)
+
+			err := NewWalletRepository(db).PlaceHold(context.Background(), "passenger-1", "order-1", tt.amount)
+			if tt.wantErr {
+				var balanceErr *InsufficientBalanceError
+				if !errors.As(err, &balanceErr) || !errors.Is(err, ErrInsufficientBalance) {
+					t.Fatalf("PlaceHold() error = %v, want an insufficient balance", err)
+				}
+				if balanceErr.Balance != tt.balance || balanceErr.Amount != tt.amount {
+					t.Fatalf("error carries balance %.2f for %.2f, want %.2f for %.2f", balanceErr.Balance, balanceErr.Amount, tt.balance, tt.amount)
+				}
+				if commits, rollbacks := db.Commits(); commits != 0 || rollbacks != 1 {
+					t.Fatalf("committed %d and rolled back %d, want the hold rolled back", commits, rollbacks)
+				}
+				if updates := db.Statements("UPDATE wallets"); len(updates) != 0 {
+					t.Fatalf("a short balance ran %v", updates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlaceHold() error = %v", err)
+			}
+			if store.balance != tt.wantBalance || store.heldBalance != tt.wantHeld {
+				t.Fatalf("balance %.2f held %.2f, want %.2f held %.2f", store.balance, store.heldBalance, tt.wantBalance, tt.wantHeld)
+			}
+			wantHolds := 1
+			if tt.held {
+				wantHolds = 0
+			}
+			if holds := db.Statements("INSERT INTO wallet_holds"); len(holds) != wantHolds {
+				t.Fatalf("wrote %d holds, want %d", len(holds), wantHolds)
+			}
+		})
+	}
+}
+
+func TestSettleHold(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		capture      float64
+		release      bool
+		wantStatus   string
+		wantCaptured float64
+		wantReleased float64
+		wantBalance  float64
+		wantLedger   map[string]float64
+	}{
+		{
+			name:         "fare below the hold",
+			status:       WalletHoldHeld,
+			capture:      32000,
+			wantStatus:   WalletHoldCaptured,
+			wantCaptured: 32000,
+			wantReleased: 18000,
+			wantBalance:  48000,
+			wantLedger:   map[string]float64{"CAPTURE-order-1": 32000, "RELEASE-order-1": 18000},
+		},
+		{
+			name:         "fare above the hold",
+			status:       WalletHoldHeld,
+			capture:      65000,
+			wantStatus:   WalletHoldCaptured,
+			wantCaptured: 50000,
+			wantBalance:  30000,
+			wantLedger:   map[string]float64{"CAPTURE-order-1": 50000},
+		},
+		{
+			name:         "released",
+			status:       WalletHoldHeld,
+			release:      true,
+			wantStatus:   WalletHoldReleased,
+			wantReleased: 50000,
+			wantBalance:  80000,
+			wantLedger:   map[string]float64{"RELEASE-order-1": 50000},
+		},
+		{
+			name:        "already settled",
+			status:      WalletHoldCaptured,
+			capture:     32000,
+			wantStatus:  WalletHoldCaptured,
+			wantBalance: 30000,
+			wantLedger:  map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newWalletStore(30000)
+			store.heldBalance = 50000
+			store.hold = &walletHoldRow{amount: 50000, status: tt.status}
+			db := mysqltest.New(store.handle)
+			repo := NewWalletRepository(db)
+
+			var err error
+			if tt.release {
+				_, err = repo.ReleaseHold(context.Background(), "order-1")
+			} else {
+				_, err = repo.CaptureHold(context.Background(), "order-1", tt.capture)
+			}
+			if err != nil {
+				t.Fatalf("settle error = %v", err)
+			}
+			if store.hold.status != tt.wantStatus || store.hold.captured != tt.wantCaptured || store.hold.released != tt.wantReleased {
+				t.Fatalf("hold = %+v, want %s captured %.2f released %.2f", *store.hold, tt.wantStatus, tt.wantCaptured, tt.wantReleased)
+			}
+			wantHeld := 0.0
+			if tt.status != WalletHoldHeld {
+				wantHeld = 50000
+			}
+			if store.balance != tt.wantBalance || store.heldBalance != wantHeld {
+				t.Fatalf("balance %.2f held %.2f, want %.2f held %.2f", store.balance, store.heldBalance, tt.wantBalance, wantHeld)
+			}
+			if fmt.Sprint(store.transactions) != fmt.Sprint(tt.wantLedger) {
+				t.Fatalf("ledger = %v, want %v", store.transactions, tt.wantLedger)
+			}
+		})
+	}
+}
+
+func TestSettleHoldNotFound(t *testing.T) {
+	store := newWalletStore(30000)
+	_, err := NewWalletRepository(mysqltest.New(store.handle)).CaptureHold(context.Background(), "order-1", 32000)
+	if !errors.Is(err, ErrHoldNotFound) {
+		t.Fatalf("CaptureHold() error = %v, want %v", err, ErrHoldNotFound)
+	}
+}
+
+func TestCaptureThenRefund(t *testing.T) {
+	store := newWalletStore(80000)
+	repo := NewWalletRepository(mysqltest.New(store.handle))
+	ctx := context.Background()
+
+	if err := repo.PlaceHold(ctx, "passenger-1", "order-1", 50000); err != nil {
+		t.Fatalf("PlaceHold() error = %v", err)
+	}
+	if _, err := repo.CaptureHold(ctx, "order-1", 32000); err != nil {
+		t.Fatalf("CaptureHold() error = %v", err)
+	}
+	for i, want := range []bool{true, false} {
+		refunded, err := repo.Refund(ctx, "passenger-1", "order-1", "REFUND-order-1", 12000)
+		if err != nil || refunded != want {
+			t.Fatalf("Refund() #%d = %v, %v, want %v", i+1, refunded, err, want)
+		}
+	}
+	// 80000 less the 32000 fare, 12000 of it refunded
+	if store.balance != 60000 || store.heldBalance != 0 {
+		t.Fatalf("balance %.2f held %.2f, want 60000 held 0", store.balance, store.heldBalance)
+	}
+}
+
+// walletStore answers the wallet statements for wallet-1 of passenger-1 with one
+// hold for order-1.
+type walletStore struct {
+	balance      float64
+	heldBalance  float64
+	hold         *walletHoldRow
+	transactions map[string]float64
+}
+
+type walletHoldRow struct {
+	amount   float64
+	captured float64
+	released float64
+	status   string
+}
+
+func newWalletStore(balance float64) *walletStore {
+	return &walletStore{balance: balance, transactions: map[string]float64{}}
+}
+
+func (s *walletStore) handle(query string, args []interface{}) mysqltest.Result {
+	switch {
+	case mysqltest.Contains(query, "SELECT id, balance FROM wallets WHERE user_id = ? FOR UPDATE"):
+		return mysqltest.Result{Columns: []string{"id", "balance"}, Rows: [][]interface{}{{"wallet-1", s.balance}}}
+	case mysqltest.Contains(query, "SELECT id FROM wallets WHERE"):
+		return mysqltest.Result{Columns: []string{"id"}, Rows: [][]interface{}{{"wallet-1"}}}
+	case mysqltest.Contains(query, "INSERT IGNORE INTO wallet_transactions"):
+		transactionID := args[1].(string)
+		if _, ok := s.transactions[transactionID]; ok {
+			return mysqltest.Result{}
+		}
+		s.transactions[transactionID] = args[3].(float64)
+		return mysqltest.Result{RowsAffected: 1, LastInsertID: int64(len(s.transactions))}
+	case mysqltest.Contains(query, "UPDATE wallets SET balance = balance - ?, held_balance = held_balance + ?"):
+		s.balance -= args[0].(float64)
+		s.heldBalance += args[1].(float64)
+		return mysqltest.Result{RowsAffected: 1}
+	case mysqltest.Contains(query, "UPDATE wallets SET balance = balance + ?, held_balance = held_balance - ?"):
+		s.balance += args[0].(float64)
+		s.heldBalance -= args[1].(float64)
+		return mysqltest.Result{RowsAffected: 1}
+	case mysqltest.Contains(query, "UPDATE wallets SET balance = balance + ?"):
+		s.balance += args[0].(float64)
+		return mysqltest.Result{RowsAffected: 1}
+	case mysqltest.Contains(query, "INSERT INTO wallet_holds"):
+		s.hold = &walletHoldRow{amount: args[2].(float64), status: args[3].(string)}
+		return mysqltest.Result{RowsAffected: 1, LastInsertID: 1}
+	case s.hold == nil && mysqltest.Contains(query, "FROM wallet_holds"):
+		return mysqltest.Result{}
+	case mysqltest.Contains(query, "SELECT wallet_id FROM wallet_holds"):
+		return mysqltest.Result{Columns: []string{"wallet_id"}, Rows: [][]interface{}{{"wallet-1"}}}
+	case mysqltest.Contains(query, "FROM wallet_holds WHERE order_id = ? FOR UPDATE"):
+		return mysqltest.Result{
+			Columns: []string{"id", "order_id", "wallet_id", "amount", "captured_amount", "released_amount", "status", "created_at", "updated_at"},
+			Rows:    [][]interface{}{{int64(1), "order-1", "wallet-1", s.hold.amount, s.hold.captured, s.hold.released, s.hold.status, time.Now(), time.Now()}},
+		}
+	case mysqltest.Contains(query, "UPDATE wallet_holds SET captured_amount = ?, released_amount = ?, status = ?"):
+		s.hold.captured = args[0].(float64)
+		s.hold.released = args[1].(float64)
+		s.hold.status = args[2].(string)
+		return mysqltest.Result{RowsAffected: 1}
+	}
+	return mysqltest.Result{Err: fmt.Errorf("unexpected statement %q", query)}
+}
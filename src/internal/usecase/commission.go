@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/log"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// commissionRate returns the commission percent for a trip. The most specific
+// commission.tiers entry wins, a tier naming both the city and the vehicle type
+// beats one naming only one of them. Without a match driver.commission_percent
+// applies.
+func commissionRate(cfg *viper.Viper, city, vehicleType string) float64 {
+	rate := cfg.GetFloat64("driver.commission_percent")
+	if rate <= 0 {
+		rate = 20
+	}
+	best := -1
+	for _, tier := range loadCommissionTiers(cfg) {
+		if tier.City != "" && !strings.EqualFold(tier.City, city) {
+			continue
+		}
+		if tier.VehicleType != "" && !strings.EqualFold(tier.VehicleType, vehicleType) {
+			continue
+		}
+		score := 0
+		if tier.City != "" {
+			score += 2
+		}
+		if tier.VehicleType != "" {
+			score++
+		}
+		if score > best {
+			best = score
+			rate = tier.Percent
+		}
+	}
+	return rate
+}
+
+// splitFare splits a fare into the platform commission and the driver earning.
+func splitFare(cfg *viper.Viper, city, vehicleType string, fare float64) (rate, commission, earning float64) {
+	rate = commissionRate(cfg, city, vehicleType)
+	commission = math.Round(fare * rate / 100)
+	return rate, commission, fare - commission
+}
+
+func loadCommissionTiers(cfg *viper.Viper) []model.CommissionTier {
+	var tiers []model.CommissionTier
+	if err := cfg.UnmarshalKey("commission.tiers", &tiers); err != nil {
+		log.GetLogger().Error("commission", fmt.Sprintf("Invalid commission.tiers: %v", err), "loadCommissionTiers", "")
+		return nil
+	}
+	return tiers
+}
@@ -0,0 +1,471 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+)
+
+const (
+	TypeCorporateInvoice = "corporate:invoice"
+)
+
+type CorporateUseCase struct {
+	Log                 log.Log
+	Validate            *validator.Validate
+	CorporateRepository *repository.CorporateRepository
+	Config              *viper.Viper
+}
+
+func NewCorporateUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	corporateRepository *repository.CorporateRepository,
+	cfg *viper.Viper,
+) *CorporateUseCase {
+	return &CorporateUseCase{
+		Log:                 logger,
+		Validate:            validate,
+		CorporateRepository: corporateRepository,
+		Config:              cfg,
+	}
+}
+
+func (c *CorporateUseCase) CreateCompany(ctx context.Context, request *model.CreateCompanyRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "CreateCompany", utils.ConvertString(err))
+		return result
+	}
+
+	company := &entity.Company{
+		CompanyID:    utils.GenerateUniqueIDWithPrefix("company"),
+		Name:         request.Name,
+		BillingEmail: request.BillingEmail,
+		Status:       repository.CompanyActive,
+	}
+	if err := c.CorporateRepository.InsertCompany(ctx, company); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed create company: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "CreateCompany", utils.ConvertString(err))
+		return result
+	}
+	return c.GetCompany(ctx, &model.CompanyRequest{CompanyID: company.CompanyID})
+}
+
+// GetCompany returns a company with its policy and members.
+func (c *CorporateUseCase) GetCompany(ctx context.Context, request *model.CompanyRequest) utils.Result {
+	var result utils.Result
+
+	company, err := c.CorporateRepository.GetCompany(ctx, request.CompanyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get company: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetCompany", utils.ConvertString(err))
+		return result
+	}
+	if company == nil {
+		result.Error = companyNotFoundError()
+		return result
+	}
+	policy, err := c.CorporateRepository.GetPolicy(ctx, company.CompanyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get company policy: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetCompany", utils.ConvertString(err))
+		return result
+	}
+	members, err := c.CorporateRepository.FindMembers(ctx, company.CompanyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get company members: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetCompany", utils.ConvertString(err))
+		return result
+	}
+	if members == nil {
+		members = []entity.CompanyMember{}
+	}
+
+	result.Data = model.CompanyResponse{Company: *company, Policy: policy, Members: members}
+	return result
+}
+
+func (c *CorporateUseCase) UpdateCompanyStatus(ctx context.Context, request *model.CompanyStatusRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "UpdateCompanyStatus", utils.ConvertString(err))
+		return result
+	}
+	if err := c.CorporateRepository.UpdateCompanyStatus(ctx, request.CompanyID, request.Status); err != nil {
+		if errors.Is(err, repository.ErrCompanyNotFound) {
+			result.Error = companyNotFoundError()
+			return result
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed update company: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "UpdateCompanyStatus", utils.ConvertString(err))
+		return result
+	}
+	return c.GetCompany(ctx, &model.CompanyRequest{CompanyID: request.CompanyID})
+}
+
+// UpsertMember adds a passenger to the company account or moves them to another
+// cost center.
+func (c *CorporateUseCase) UpsertMember(ctx context.Context, request *model.CompanyMemberRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "UpsertMember", utils.ConvertString(err))
+		return result
+	}
+	if result = c.requireCompany(ctx, request.CompanyID); result.Error != nil {
+		return result
+	}
+
+	member := &entity.CompanyMember{
+		CompanyID:  request.CompanyID,
+		UserID:     request.UserID,
+		CostCenter: request.CostCenter,
+	}
+	if err := c.CorporateRepository.UpsertMember(ctx, member); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed save company member: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "UpsertMember", utils.ConvertString(err))
+		return result
+	}
+	return c.GetCompany(ctx, &model.CompanyRequest{CompanyID: request.CompanyID})
+}
+
+func (c *CorporateUseCase) RemoveMember(ctx context.Context, request *model.RemoveCompanyMemberRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "RemoveMember", utils.ConvertString(err))
+		return result
+	}
+	removed, err := c.CorporateRepository.RemoveMember(ctx, request.CompanyID, request.UserID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed remove company member: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "RemoveMember", utils.ConvertString(err))
+		return result
+	}
+	if !removed {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Member not found in company"
+		result.Error = errObj
+		return result
+	}
+	return c.GetCompany(ctx, &model.CompanyRequest{CompanyID: request.CompanyID})
+}
+
+// SetPolicy replaces the spending policy of a company.
+func (c *CorporateUseCase) SetPolicy(ctx context.Context, request *model.CompanyPolicyRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "SetPolicy", utils.ConvertString(err))
+		return result
+	}
+	for _, zone := range request.AllowedZones {
+		if zone.RadiusKm <= 0 {
+			errObj := httpError.NewBadRequest()
+			errObj.Message = "allowed zones need a radiusKm above 0"
+			result.Error = errObj
+			return result
+		}
+	}
+	if result = c.requireCompany(ctx, request.CompanyID); result.Error != nil {
+		return result
+	}
+
+	policy := &entity.CompanyPolicy{
+		CompanyID: request.CompanyID,
+		StartTime: optionalString(request.StartTime),
+		EndTime:   optionalString(request.EndTime),
+		MaxFare:   request.MaxFare,
+	}
+	if len(request.Weekdays) > 0 {
+		days := make([]string, 0, len(request.Weekdays))
+		for _, day := range request.Weekdays {
+			days = append(days, strconv.Itoa(day))
+		}
+		policy.Weekdays = optionalString(strings.Join(days, ","))
+	}
+	if len(request.AllowedZones) > 0 {
+		zones, err := json.Marshal(request.AllowedZones)
+		if err != nil {
+			errObj := httpError.NewBadRequest()
+			errObj.Message = "invalid allowed zones"
+			result.Error = errObj
+			return result
+		}
+		policy.AllowedZones = optionalString(string(zones))
+	}
+	if err := c.CorporateRepository.UpsertPolicy(ctx, policy); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed save company policy: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "SetPolicy", utils.ConvertString(err))
+		return result
+	}
+	return c.GetCompany(ctx, &model.CompanyRequest{CompanyID: request.CompanyID})
+}
+
+// GetProfile returns the company account a passenger can ride on.
+func (c *CorporateUseCase) GetProfile(ctx context.Context, userID string) utils.Result {
+	var result utils.Result
+
+	membership, err := c.CorporateRepository.GetMembership(ctx, userID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get corporate account: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetProfile", utils.ConvertString(err))
+		return result
+	}
+	if membership == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "You are not a member of a corporate account"
+		result.Error = errObj
+		return result
+	}
+
+	result.Data = model.CorporateProfileResponse{
+		CompanyID:   membership.Company.CompanyID,
+		CompanyName: membership.Company.Name,
+		CostCenter:  membership.Member.CostCenter,
+		Policy:      membership.Policy,
+	}
+	return result
+}
+
+// GenerateInvoice issues the invoice of a company for a closed month.
+func (c *CorporateUseCase) GenerateInvoice(ctx context.Context, request *model.GenerateInvoiceRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GenerateInvoice", utils.ConvertString(err))
+		return result
+	}
+	location := corporateLocation(c.Config)
+	from, err := time.ParseInLocation("2006-01", request.Period, location)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid period, use YYYY-MM"
+		result.Error = errObj
+		return result
+	}
+	if to := from.AddDate(0, 1, 0); to.After(time.Now()) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "the period is not closed yet"
+		result.Error = errObj
+		return result
+	}
+	if result = c.requireCompany(ctx, request.CompanyID); result.Error != nil {
+		return result
+	}
+
+	invoice, err := c.generateInvoice(ctx, request.CompanyID, from)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed generate invoice: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GenerateInvoice", utils.ConvertString(err))
+		return result
+	}
+	return c.GetInvoice(ctx, &model.InvoiceRequest{InvoiceID: invoice.InvoiceID})
+}
+
+// GenerateInvoices is the monthly task issuing the invoices of the previous month
+// for every company with billed trips.
+func (c *CorporateUseCase) GenerateInvoices(ctx context.Context, t *asynq.Task) error {
+	location := corporateLocation(c.Config)
+	now := time.Now().In(location)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location).AddDate(0, -1, 0)
+
+	var payload model.CorporateInvoiceTask
+	if len(t.Payload()) > 0 {
+		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+			c.Log.WithContext(ctx).Error("corporate-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "GenerateInvoices", "")
+			return err
+		}
+	}
+	if payload.Period != "" {
+		period, err := time.ParseInLocation("2006-01", payload.Period, location)
+		if err != nil {
+			return err
+		}
+		from = period
+	}
+
+	companies, err := c.CorporateRepository.FindBilledCompanies(ctx, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		c.Log.WithContext(ctx).Error("corporate-usecase", fmt.Sprintf("Failed get billed companies: %v", err), "GenerateInvoices", "")
+		return err
+	}
+	var failed int
+	for _, companyID := range companies {
+		invoice, err := c.generateInvoice(ctx, companyID, from)
+		if err != nil {
+			failed++
+			c.Log.WithContext(ctx).Error("corporate-usecase", fmt.Sprintf("Failed generate invoice: %v", err), "GenerateInvoices", companyID)
+			continue
+		}
+		c.Log.WithContext(ctx).Info("corporate-usecase", fmt.Sprintf("Invoice has %d trips, total %.2f", invoice.TripCount, invoice.TotalAmount), "GenerateInvoices", invoice.InvoiceID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d invoices failed", failed, len(companies))
+	}
+	return nil
+}
+
+func (c *CorporateUseCase) ListInvoices(ctx context.Context, request *model.CompanyRequest) utils.Result {
+	var result utils.Result
+
+	if result = c.requireCompany(ctx, request.CompanyID); result.Error != nil {
+		return result
+	}
+	invoices, err := c.CorporateRepository.FindInvoices(ctx, request.CompanyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get invoices: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "ListInvoices", utils.ConvertString(err))
+		return result
+	}
+	if invoices == nil {
+		invoices = []entity.CorporateInvoice{}
+	}
+
+	result.Data = invoices
+	return result
+}
+
+// GetInvoice returns an invoice with its trips and the totals per cost center.
+func (c *CorporateUseCase) GetInvoice(ctx context.Context, request *model.InvoiceRequest) utils.Result {
+	var result utils.Result
+
+	invoice, err := c.CorporateRepository.GetInvoice(ctx, request.InvoiceID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get invoice: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetInvoice", utils.ConvertString(err))
+		return result
+	}
+	if invoice == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Invoice not found"
+		result.Error = errObj
+		return result
+	}
+	items, err := c.CorporateRepository.FindInvoiceItems(ctx, invoice.InvoiceID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get invoice items: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "GetInvoice", utils.ConvertString(err))
+		return result
+	}
+	if items == nil {
+		items = []entity.CorporateInvoiceItem{}
+	}
+
+	totals := make(map[string]*model.CostCenterTotal)
+	for _, item := range items {
+		total, ok := totals[item.CostCenter]
+		if !ok {
+			total = &model.CostCenterTotal{CostCenter: item.CostCenter}
+			totals[item.CostCenter] = total
+		}
+		total.TripCount++
+		total.TotalAmount += item.Amount
+	}
+	costCenters := make([]model.CostCenterTotal, 0, len(totals))
+	for _, total := range totals {
+		costCenters = append(costCenters, *total)
+	}
+	sort.Slice(costCenters, func(i, j int) bool { return costCenters[i].CostCenter < costCenters[j].CostCenter })
+
+	result.Data = model.InvoiceResponse{Invoice: *invoice, CostCenters: costCenters, Items: items}
+	return result
+}
+
+func (c *CorporateUseCase) generateInvoice(ctx context.Context, companyID string, from time.Time) (*entity.CorporateInvoice, error) {
+	dueDays := c.Config.GetInt("corporate.invoice.due_days")
+	if dueDays <= 0 {
+		dueDays = 14
+	}
+	return c.CorporateRepository.GenerateInvoice(ctx, &entity.CorporateInvoice{
+		InvoiceID: fmt.Sprintf("INV-%s-%s", companyID, from.Format("200601")),
+		CompanyID: companyID,
+		Period:    from.Format("2006-01"),
+		DueAt:     time.Now().AddDate(0, 0, dueDays),
+	}, from, from.AddDate(0, 1, 0))
+}
+
+func (c *CorporateUseCase) requireCompany(ctx context.Context, companyID string) utils.Result {
+	var result utils.Result
+
+	company, err := c.CorporateRepository.GetCompany(ctx, companyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get company: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("corporate-usecase", errObj.Message, "requireCompany", utils.ConvertString(err))
+		return result
+	}
+	if company == nil {
+		result.Error = companyNotFoundError()
+	}
+	return result
+}
+
+func companyNotFoundError() httpError.NotFoundData {
+	errObj := httpError.NewNotFound()
+	errObj.Message = "Company not found"
+	return errObj
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"order-service/src/internal/model"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func (c *DriverUseCase) SetDestinationMode(ctx context.Context, request *model.DestinationModeRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "SetDestinationMode", utils.ConvertString(err))
+		return result
+	}
+
+	quota := c.Config.GetInt64("driver.destination.daily_quota")
+	if quota <= 0 {
+		quota = 2
+	}
+	now := time.Now()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	quotaKey := fmt.Sprintf("DRIVER:DESTINATION-QUOTA:%s:%s", request.DriverID, now.Format("20060102"))
+
+	used, err := c.Redis.Incr(ctx, quotaKey).Result()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error insert to redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "SetDestinationMode", utils.ConvertString(err))
+		return result
+	}
+	_ = c.Redis.ExpireAt(ctx, quotaKey, endOfDay).Err()
+	if used > quota {
+		_ = c.Redis.Decr(ctx, quotaKey).Err()
+		errObj := httpError.NewConflict()
+		errObj.Message = fmt.Sprintf("Destination mode can only be used %d times a day", quota)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "SetDestinationMode", request.DriverID)
+		return result
+	}
+
+	destination := model.DriverDestination{
+		Latitude:    request.Latitude,
+		Longitude:   request.Longitude,
+		Address:     request.Address,
+		ActivatedAt: now,
+		ExpiresAt:   endOfDay,
+	}
+	raw, _ := json.Marshal(destination)
+	if err := c.Redis.Set(ctx, fmt.Sprintf("DRIVER:DESTINATION:%s", request.DriverID), raw, endOfDay.Sub(now)).Err(); err != nil {
+		_ = c.Redis.Decr(ctx, quotaKey).Err()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error insert to redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "SetDestinationMode", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = model.DestinationModeResponse{
+		Destination:    destination,
+		UsedToday:      used,
+		DailyQuota:     quota,
+		RemainingToday: quota - used,
+	}
+	return result
+}
+
+func (c *DriverUseCase) ClearDestinationMode(ctx context.Context, driverID string) utils.Result {
+	var result utils.Result
+
+	if err := c.Redis.Del(ctx, fmt.Sprintf("DRIVER:DESTINATION:%s", driverID)).Err(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error delete from redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "ClearDestinationMode", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = map[string]interface{}{
+		"driver_id": driverID,
+		"message":   "Destination mode turned off",
+	}
+	return result
+}
+
+// loadDriverDestinations returns the active destinations of the given drivers,
+// drivers without destination mode are absent from the map.
+func loadDriverDestinations(ctx context.Context, rdb redis.UniversalClient, driverIDs []string) map[string]model.DriverDestination {
+	destinations := make(map[string]model.DriverDestination)
+	if len(driverIDs) == 0 {
+		return destinations
+	}
+	pipe := rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(driverIDs))
+	for i, driverID := range driverIDs {
+		cmds[i] = pipe.Get(ctx, fmt.Sprintf("DRIVER:DESTINATION:%s", driverID))
+	}
+	_, _ = pipe.Exec(ctx)
+	for i, driverID := range driverIDs {
+		raw, err := cmds[i].Result()
+		if err != nil {
+			continue
+		}
+		var destination model.DriverDestination
+		if err := json.Unmarshal([]byte(raw), &destination); err == nil {
+			destinations[driverID] = destination
+		}
+	}
+	return destinations
+}
+
+// destinationAllowsTrip reports whether the drop-off brings the driver closer to
+// the destination by at least driver.destination.min_reduction_ratio of the
+// current distance.
+func destinationAllowsTrip(cfg *viper.Viper, destination model.DriverDestination, driverLat, driverLng, dropLat, dropLng float64) bool {
+	ratio := cfg.GetFloat64("driver.destination.min_reduction_ratio")
+	if ratio <= 0 {
+		ratio = 0.5
+	}
+	before := utils.HaversineKm(driverLat, driverLng, destination.Latitude, destination.Longitude)
+	if before == 0 {
+		return false
+	}
+	after := utils.HaversineKm(dropLat, dropLng, destination.Latitude, destination.Longitude)
+	return (before-after)/before >= ratio
+}
+
+// filterByDestination drops the drivers in destination mode for whom the trip
+// does not head towards their destination.
+func filterByDestination(ctx context.Context, rdb redis.UniversalClient, cfg *viper.Viper, drivers []redis.GeoLocation, dropLat, dropLng float64) []redis.GeoLocation {
+	ids := make([]string, 0, len(drivers))
+	for _, driver := range drivers {
+		ids = append(ids, driver.Name)
+	}
+	destinations := loadDriverDestinations(ctx, rdb, ids)
+	if len(destinations) == 0 {
+		return drivers
+	}
+	filtered := make([]redis.GeoLocation, 0, len(drivers))
+	for _, driver := range drivers {
+		destination, ok := destinations[driver.Name]
+		if ok && !destinationAllowsTrip(cfg, destination, driver.Latitude, driver.Longitude, dropLat, dropLng) {
+			continue
+		}
+		filtered = append(filtered, driver)
+	}
+	return filtered
+}
@@ -0,0 +1,302 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/matching"
+	"order-service/src/pkg/utils"
+	"sort"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+type DispatchUseCase struct {
+	Log              log.Log
+	OrderRepository  *repository.OrderRepository
+	DriverRepository *repository.DriverRepository
+	Config           *viper.Viper
+	Redis            redis.UniversalClient
+	DispatchProducer *messaging.DispatchProducer
+}
+
+func NewDispatchUseCase(
+	logger log.Log,
+	orderRepository *repository.OrderRepository,
+	driverRepository *repository.DriverRepository,
+	cfg *viper.Viper,
+	redisClient redis.UniversalClient,
+	dispatchProducer *messaging.DispatchProducer,
+) *DispatchUseCase {
+	return &DispatchUseCase{
+		Log:              logger,
+		OrderRepository:  orderRepository,
+		DriverRepository: driverRepository,
+		Config:           cfg,
+		Redis:            redisClient,
+		DispatchProducer: dispatchProducer,
+	}
+}
+
+const (
+	TypeBatchDispatch = "order:batch-dispatch"
+)
+
+type batchDriver struct {
+	ID        string
+	Latitude  float64
+	Longitude float64
+}
+
+// BatchDispatch gathers the pending BATCH orders and the idle drivers of every
+// dispatch zone and offers each order to the driver given by a global assignment
+// that minimizes the total pickup ETA of the zone.
+func (c *DispatchUseCase) BatchDispatch(ctx context.Context, t *asynq.Task) error {
+	mode := model.DispatchModeBatch
+	orders, err := c.OrderRepository.FindOrders(ctx, entity.OrderFilter{
+		DispatchMode: &mode,
+		StatusIn:     []string{"REQUESTED", "MATCHING"},
+	})
+	if err != nil {
+		c.Log.WithContext(ctx).Error("dispatch-usecase", fmt.Sprintf("Failed query pending orders: %v", err), "BatchDispatch", "")
+		return err
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	// oldest orders first keeps the assignment input stable between runs
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].ID < orders[j].ID
+		}
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+
+	for _, zone := range loadDispatchZones(c.Config) {
+		var zoneOrders []entity.Order
+		for _, order := range orders {
+			if zone.Contains(order.OriginLat, order.OriginLng) {
+				zoneOrders = append(zoneOrders, order)
+			}
+		}
+		if len(zoneOrders) == 0 {
+			continue
+		}
+		if err := c.dispatchZone(ctx, zone, zoneOrders); err != nil {
+			c.Log.WithContext(ctx).Error("dispatch-usecase", fmt.Sprintf("Failed dispatch zone: %v", err), "BatchDispatch", zone.ID)
+		}
+	}
+	return nil
+}
+
+func (c *DispatchUseCase) dispatchZone(ctx context.Context, zone model.DispatchZone, orders []entity.Order) error {
+	orders, err := c.withoutPendingOffer(ctx, orders)
+	if err != nil || len(orders) == 0 {
+		return err
+	}
+	drivers, err := c.idleDrivers(ctx, zone)
+	if err != nil || len(drivers) == 0 {
+		return err
+	}
+
+	maxPickupKm := c.Config.GetFloat64("dispatch.batch.max_pickup_km")
+	if maxPickupKm <= 0 {
+		maxPickupKm = 3
+	}
+	speedKmh := c.Config.GetFloat64("dispatch.batch.avg_speed_kmh")
+	if speedKmh <= 0 {
+		speedKmh = 20
+	}
+	driverIDs := make([]string, 0, len(drivers))
+	for _, driver := range drivers {
+		driverIDs = append(driverIDs, driver.ID)
+	}
+	destinations := loadDriverDestinations(ctx, c.Redis, driverIDs)
+
+	cost := make([][]float64, len(orders))
+	distances := make([][]float64, len(orders))
+	for i, order := range orders {
+		cost[i] = make([]float64, len(drivers))
+		distances[i] = make([]float64, len(drivers))
+		for j, driver := range drivers {
+			distance := utils.HaversineKm(order.OriginLat, order.OriginLng, driver.Latitude, driver.Longitude)
+			distances[i][j] = distance
+			cost[i][j] = matching.Infeasible
+			if destination, ok := destinations[driver.ID]; ok &&
+				!destinationAllowsTrip(c.Config, destination, driver.Latitude, driver.Longitude, order.DestinationLat, order.DestinationLng) {
+				continue
+			}
+			if distance <= maxPickupKm {
+				cost[i][j] = distance / speedKmh * 60
+			}
+		}
+	}
+
+	window := c.Config.GetDuration("dispatch.batch.offer_window")
+	if window <= 0 {
+		window = 20 * time.Second
+	}
+	assignment := matching.Assign(cost)
+	for i, j := range assignment {
+		if j < 0 {
+			continue
+		}
+		c.offer(ctx, &orders[i], drivers[j].ID, distances[i][j], cost[i][j], window)
+	}
+	c.Log.WithContext(ctx).Info("dispatch-usecase", fmt.Sprintf("Solved %d orders against %d drivers", len(orders), len(drivers)), "dispatchZone", zone.ID)
+	return nil
+}
+
+// offer reserves the order and the driver for the offer window and publishes the
+// driver-offer event. The driver accepts through AcceptPickup and the auto
+// dispatch task assigns the order, an unanswered offer simply expires.
+func (c *DispatchUseCase) offer(ctx context.Context, order *entity.Order, driverID string, distanceKm, etaMinutes float64, window time.Duration) {
+	orderKey := fmt.Sprintf("ORDER:BATCH-OFFER:%s", order.OrderID)
+	driverKey := fmt.Sprintf("DRIVER:BATCH-OFFER:%s", driverID)
+	reserved, err := c.Redis.SetNX(ctx, orderKey, driverID, window).Result()
+	if err != nil || !reserved {
+		return
+	}
+	if reserved, err := c.Redis.SetNX(ctx, driverKey, order.OrderID, window).Result(); err != nil || !reserved {
+		_ = c.Redis.Del(ctx, orderKey).Err()
+		return
+	}
+
+	event := &model.DriverOfferEvent{
+		EventID:          utils.GenerateUniqueIDWithPrefix("driver_offer"),
+		OrderID:          order.OrderID,
+		PassengerID:      order.PassengerID,
+		DriverID:         driverID,
+		PickupDistanceKm: distanceKm,
+		PickupEtaMinutes: etaMinutes,
+		ExpiresAt:        time.Now().Add(window),
+		RouteSummary:     routeSummaryFromOrder(order),
+	}
+	if err := c.DispatchProducer.SendDriverOffer(ctx, event); err != nil {
+		c.Log.WithContext(ctx).Error("dispatch-usecase", fmt.Sprintf("Failed publish driver offer event: %v", err), "offer", order.OrderID)
+		_ = c.Redis.Del(ctx, orderKey, driverKey).Err()
+	}
+}
+
+// withoutPendingOffer drops the orders still waiting on an offer from a previous run.
+func (c *DispatchUseCase) withoutPendingOffer(ctx context.Context, orders []entity.Order) ([]entity.Order, error) {
+	pipe := c.Redis.Pipeline()
+	checks := make([]*redis.IntCmd, len(orders))
+	for i, order := range orders {
+		checks[i] = pipe.Exists(ctx, fmt.Sprintf("ORDER:BATCH-OFFER:%s", order.OrderID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	var free []entity.Order
+	for i, order := range orders {
+		if checks[i].Val() == 0 {
+			free = append(free, order)
+		}
+	}
+	return free, nil
+}
+
+// idleDrivers returns the available drivers around the zone who are not holding an
+// offer, sorted by id so the solver input does not depend on Redis ordering.
+func (c *DispatchUseCase) idleDrivers(ctx context.Context, zone model.DispatchZone) ([]batchDriver, error) {
+	maxPickupKm := c.Config.GetFloat64("dispatch.batch.max_pickup_km")
+	if maxPickupKm <= 0 {
+		maxPickupKm = 3
+	}
+	locations, err := c.Redis.GeoRadius(ctx, "drivers-locations", zone.Longitude, zone.Latitude, &redis.GeoRadiusQuery{
+		Radius:    zone.RadiusKm + maxPickupKm,
+		Unit:      "km",
+		WithCoord: true,
+	}).Result()
+	if err != nil || len(locations) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(locations))
+	for _, location := range locations {
+		ids = append(ids, location.Name)
+	}
+	available, err := c.DriverRepository.FindAvailableDrivers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	blocked := debtBlockedDrivers(ctx, c.Redis, ids)
+	idle := make(map[string]bool, len(available))
+	for _, driver := range available {
+		idle[driver.DriverID] = !blocked[driver.DriverID]
+	}
+
+	pipe := c.Redis.Pipeline()
+	checks := make([]*redis.IntCmd, len(locations))
+	for i, location := range locations {
+		checks[i] = pipe.Exists(ctx, fmt.Sprintf("DRIVER:BATCH-OFFER:%s", location.Name))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var drivers []batchDriver
+	for i, location := range locations {
+		if !idle[location.Name] || checks[i].Val() > 0 {
+			continue
+		}
+		drivers = append(drivers, batchDriver{ID: location.Name, Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].ID < drivers[j].ID })
+	return drivers, nil
+}
+
+func loadDispatchZones(cfg *viper.Viper) []model.DispatchZone {
+	var zones []model.DispatchZone
+	if err := cfg.UnmarshalKey("dispatch.batch.zones", &zones); err != nil {
+		log.GetLogger().Error("dispatch-usecase", fmt.Sprintf("Invalid dispatch.batch.zones: %v", err), "loadDispatchZones", "")
+		return nil
+	}
+	return zones
+}
+
+// cityAt returns the city of dispatch.city_areas a point lies in, the id of an
+// area is its city. It is empty outside every area.
+func cityAt(cfg *viper.Viper, lat, lng float64) string {
+	var areas []model.DispatchZone
+	if err := cfg.UnmarshalKey("dispatch.city_areas", &areas); err != nil {
+		log.GetLogger().Error("dispatch-usecase", fmt.Sprintf("Invalid dispatch.city_areas: %v", err), "cityAt", "")
+		return ""
+	}
+	for _, area := range areas {
+		if area.Contains(lat, lng) {
+			return area.ID
+		}
+	}
+	return ""
+}
+
+func routeSummaryFromOrder(order *entity.Order) model.RouteSummary {
+	return model.RouteSummary{
+		Route: model.Route{
+			Origin: model.LocationRequest{
+				Latitude:  order.OriginLat,
+				Longitude: order.OriginLng,
+				Address:   order.OriginAddress,
+			},
+			Destination: model.LocationRequest{
+				Latitude:  order.DestinationLat,
+				Longitude: order.DestinationLng,
+				Address:   order.DestinationAddress,
+			},
+		},
+		MinPrice:          order.MinPrice,
+		MaxPrice:          order.MaxPrice,
+		BestRouteKm:       order.BestRouteKm,
+		BestRoutePrice:    order.BestRoutePrice,
+		BestRouteDuration: order.BestRouteDuration,
+	}
+}
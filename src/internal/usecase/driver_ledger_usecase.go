@@ -0,0 +1,291 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/payment"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+const debtBlockedKey = "DRIVER:DEBT-BLOCKED"
+
+type DriverLedgerUseCase struct {
+	Log                    log.Log
+	Validate               *validator.Validate
+	DriverLedgerRepository *repository.DriverLedgerRepository
+	Config                 *viper.Viper
+	Redis                  redis.UniversalClient
+	PaymentProvider        payment.Provider
+}
+
+func NewDriverLedgerUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	driverLedgerRepository *repository.DriverLedgerRepository,
+	cfg *viper.Viper,
+	redisClient redis.UniversalClient,
+	paymentProvider payment.Provider,
+) *DriverLedgerUseCase {
+	return &DriverLedgerUseCase{
+		Log:                    logger,
+		Validate:               validate,
+		DriverLedgerRepository: driverLedgerRepository,
+		Config:                 cfg,
+		Redis:                  redisClient,
+		PaymentProvider:        paymentProvider,
+	}
+}
+
+func (c *DriverLedgerUseCase) GetDebt(ctx context.Context, driverID string) utils.Result {
+	var result utils.Result
+
+	debt, err := c.DriverLedgerRepository.GetDebt(ctx, driverID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get driver debt: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "GetDebt", utils.ConvertString(err))
+		return result
+	}
+	entries, err := c.DriverLedgerRepository.FindEntries(ctx, driverID, 20)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get driver ledger: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "GetDebt", utils.ConvertString(err))
+		return result
+	}
+	if entries == nil {
+		entries = []entity.DriverLedgerEntry{}
+	}
+
+	result.Data = model.DriverDebtResponse{
+		DriverID: driverID,
+		Debt:     debt,
+		Limit:    debtLimit(c.Config),
+		Blocked:  debt > debtLimit(c.Config),
+		Entries:  entries,
+	}
+	return result
+}
+
+// SettleDebt charges the driver through the payment provider and credits the
+// payment to the driver wallet. The idempotency key makes a retried request return
+// the first settlement.
+func (c *DriverLedgerUseCase) SettleDebt(ctx context.Context, request *model.SettleDebtRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", utils.ConvertString(err))
+		return result
+	}
+
+	entryID := settleEntryID(request.DriverID, request.IdempotencyKey)
+	settled, err := c.DriverLedgerRepository.GetEntry(ctx, entryID)
+	if err == nil && settled == nil {
+		// settlements booked before the key was hashed keep their raw key id
+		settled, err = c.DriverLedgerRepository.GetEntry(ctx, fmt.Sprintf("SETTLE-%s-%s", request.DriverID, request.IdempotencyKey))
+	}
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get driver ledger: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", utils.ConvertString(err))
+		return result
+	}
+	if settled != nil {
+		result.Data = c.settleDebtResponse(ctx, settled, nil, true)
+		return result
+	}
+
+	debt, err := c.DriverLedgerRepository.GetDebt(ctx, request.DriverID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get driver debt: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", utils.ConvertString(err))
+		return result
+	}
+	if debt <= 0 {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "There is no outstanding debt to settle"
+		result.Error = errObj
+		return result
+	}
+	amount := request.Amount
+	if amount == 0 {
+		amount = debt
+	}
+	if amount > debt {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("amount exceeds the outstanding debt of %.0f", debt)
+		result.Error = errObj
+		return result
+	}
+
+	// a concurrent request with the same key waits for the first one to finish
+	lockKey := fmt.Sprintf("DRIVER:DEBT-SETTLE-LOCK:%s", entryID)
+	locked, err := c.Redis.SetNX(ctx, lockKey, "1", 30*time.Second).Result()
+	if err != nil || !locked {
+		errObj := httpError.NewConflict()
+		errObj.Message = "A settlement with this idempotency key is in progress"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", request.IdempotencyKey)
+		return result
+	}
+	defer c.Redis.Del(ctx, lockKey)
+
+	charge, err := c.PaymentProvider.Charge(ctx, &payment.ChargeRequest{
+		Reference: entryID,
+		UserID:    request.DriverID,
+		Amount:    amount,
+	})
+	if err != nil || charge.Status == payment.StatusFailed {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Settlement was declined by the payment provider"
+		if err != nil && !errors.Is(err, payment.ErrChargeDeclined) {
+			errObj.Message = fmt.Sprintf("Settlement failed: %v", err)
+		}
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", utils.ConvertString(err))
+		return result
+	}
+	if charge.Status != payment.StatusSucceeded {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Settlement is waiting for the payment provider, retry with the same idempotency key"
+		result.Error = errObj
+		return result
+	}
+
+	description := "Cash commission debt settlement"
+	entry, booked, err := c.DriverLedgerRepository.Settle(ctx, &entity.DriverLedgerEntry{
+		EntryID:     entryID,
+		DriverID:    request.DriverID,
+		EntryType:   repository.LedgerSettlement,
+		Amount:      amount,
+		Description: &description,
+	})
+	if err != nil || entry == nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed settle driver debt: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-ledger-usecase", errObj.Message, "SettleDebt", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = c.settleDebtResponse(ctx, entry, charge, !booked)
+	return result
+}
+
+// settleEntryID is the ledger entry id of a settlement, also used as its wallet
+// transaction id and charge reference. The idempotency key is hashed so the id
+// keeps a fixed length and charset whatever key the client sends.
+func settleEntryID(driverID, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(driverID + ":" + idempotencyKey))
+	return "SETTLE-" + hex.EncodeToString(sum[:20])
+}
+
+func (c *DriverLedgerUseCase) settleDebtResponse(ctx context.Context, entry *entity.DriverLedgerEntry, charge *payment.ChargeResult, replayed bool) model.SettleDebtResponse {
+	debt, err := c.DriverLedgerRepository.GetDebt(ctx, entry.DriverID)
+	if err != nil {
+		debt = entry.DebtAfter
+	}
+	blocked := syncDebtBlock(ctx, c.Redis, c.Config, entry.DriverID, debt)
+	response := model.SettleDebtResponse{
+		EntryID:  entry.EntryID,
+		Amount:   entry.Amount,
+		Debt:     debt,
+		Blocked:  blocked,
+		Replayed: replayed,
+	}
+	if charge != nil {
+		response.Provider = charge.Provider
+		response.ProviderReference = charge.ProviderReference
+	}
+	return response
+}
+
+// debtLimit is the commission debt above which a driver is not matched anymore.
+func debtLimit(cfg *viper.Viper) float64 {
+	limit := cfg.GetFloat64("driver.cash.debt_limit")
+	if limit <= 0 {
+		limit = 50000
+	}
+	return limit
+}
+
+// syncDebtBlock keeps the set of drivers blocked for debt in line with the debt of
+// one driver and reports whether the driver is blocked.
+func syncDebtBlock(ctx context.Context, rdb redis.UniversalClient, cfg *viper.Viper, driverID string, debt float64) bool {
+	blocked := debt > debtLimit(cfg)
+	var err error
+	if blocked {
+		err = rdb.SAdd(ctx, debtBlockedKey, driverID).Err()
+	} else {
+		err = rdb.SRem(ctx, debtBlockedKey, driverID).Err()
+	}
+	if err != nil {
+		log.GetLogger().Error("driver-ledger-usecase", fmt.Sprintf("Failed update debt block: %v", err), "syncDebtBlock", driverID)
+	}
+	return blocked
+}
+
+// debtBlockedDrivers returns which of the given drivers are blocked for debt.
+func debtBlockedDrivers(ctx context.Context, rdb redis.UniversalClient, driverIDs []string) map[string]bool {
+	blocked := make(map[string]bool)
+	if len(driverIDs) == 0 {
+		return blocked
+	}
+	members := make([]interface{}, len(driverIDs))
+	for i, id := range driverIDs {
+		members[i] = id
+	}
+	flags, err := rdb.SMIsMember(ctx, debtBlockedKey, members...).Result()
+	if err != nil {
+		return blocked
+	}
+	for i, flag := range flags {
+		if flag {
+			blocked[driverIDs[i]] = true
+		}
+	}
+	return blocked
+}
+
+// filterDebtBlocked drops the drivers blocked for unpaid cash commission.
+func filterDebtBlocked(ctx context.Context, rdb redis.UniversalClient, drivers []redis.GeoLocation) []redis.GeoLocation {
+	if len(drivers) == 0 {
+		return drivers
+	}
+	ids := make([]string, 0, len(drivers))
+	for _, driver := range drivers {
+		ids = append(ids, driver.Name)
+	}
+	blocked := debtBlockedDrivers(ctx, rdb, ids)
+	if len(blocked) == 0 {
+		return drivers
+	}
+	kept := make([]redis.GeoLocation, 0, len(drivers))
+	for _, driver := range drivers {
+		if !blocked[driver.Name] {
+			kept = append(kept, driver)
+		}
+	}
+	return kept
+}
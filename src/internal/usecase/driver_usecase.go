@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"order-service/src/internal/entity"
 	"order-service/src/internal/gateway/messaging"
 	"order-service/src/internal/model"
@@ -11,7 +12,6 @@ import (
 	httpError "order-service/src/pkg/http-error"
 	"order-service/src/pkg/log"
 	"order-service/src/pkg/utils"
-	"strconv"
 	"time"
 
 	// "order-service/src/internal/gateway/messaging"
@@ -24,15 +24,19 @@ import (
 )
 
 type DriverUseCase struct {
-	Log              log.Log
-	Validate         *validator.Validate
-	UserRepository   *repository.UserRepository
-	WalletRepository *repository.WalletRepository
-	OrderRepository  *repository.OrderRepository
-	DriverRepository *repository.DriverRepository
-	Config           *viper.Viper
-	Redis            redis.UniversalClient
-	DriverProducer   *messaging.DriverProducer
+	Log                  log.Log
+	Validate             *validator.Validate
+	UserRepository       *repository.UserRepository
+	PaymentMethods       *PaymentMethods
+	OrderRepository      *repository.OrderRepository
+	DriverRepository     *repository.DriverRepository
+	SharedRideRepository *repository.SharedRideRepository
+	OutboxRepository     *repository.OutboxRepository
+	OrderEvents          *OrderEvents
+	Config               *viper.Viper
+	Redis                redis.UniversalClient
+	DriverProducer       *messaging.DriverProducer
+	ReceiptProducer      *messaging.ReceiptProducer
 }
 
 func NewDriverUseCase(
@@ -41,21 +45,29 @@ func NewDriverUseCase(
 	userRepository *repository.UserRepository,
 	driverRepository *repository.DriverRepository,
 	orderRepository *repository.OrderRepository,
-	walletRepository *repository.WalletRepository,
+	paymentMethods *PaymentMethods,
+	sharedRideRepository *repository.SharedRideRepository,
+	outboxRepository *repository.OutboxRepository,
+	orderEvents *OrderEvents,
 	cfg *viper.Viper,
 	redisClient redis.UniversalClient,
 	driverProducer *messaging.DriverProducer,
+	receiptProducer *messaging.ReceiptProducer,
 ) *DriverUseCase {
 	return &DriverUseCase{
-		Log:              logger,
-		Validate:         validate,
-		UserRepository:   userRepository,
-		DriverRepository: driverRepository,
-		OrderRepository:  orderRepository,
-		WalletRepository: walletRepository,
-		Config:           cfg,
-		Redis:            redisClient,
-		DriverProducer:   driverProducer,
+		Log:                  logger,
+		Validate:             validate,
+		UserRepository:       userRepository,
+		DriverRepository:     driverRepository,
+		OrderRepository:      orderRepository,
+		PaymentMethods:       paymentMethods,
+		SharedRideRepository: sharedRideRepository,
+		OutboxRepository:     outboxRepository,
+		OrderEvents:          orderEvents,
+		Config:               cfg,
+		Redis:                redisClient,
+		DriverProducer:       driverProducer,
+		ReceiptProducer:      receiptProducer,
 	}
 }
 
@@ -66,13 +78,13 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewBadRequest()
 		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
 		return result
 	}
 
 	driverInfo, err := c.UserRepository.FindByID(ctx, request.DriverID)
 	if err != nil {
-		c.Log.Error("driver-usecase", fmt.Sprintf("Error get data driver :%v", err), "find-driver-info", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Error get data driver :%v", err), "find-driver-info", utils.ConvertString(err))
 		errObj := httpError.NewNotFound()
 		errObj.Message = fmt.Sprintf("driver with id %s not found", request.DriverID)
 		result.Error = errObj
@@ -83,7 +95,7 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewNotFound()
 		errObj.Message = "Order not found"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
 		return result
 	}
 	switch tripOrder.Status {
@@ -91,14 +103,14 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order is no longer available (already completed or cancelled)"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "")
 		return result
 
 	case "ON_GOING":
 		errObj := httpError.NewConflict()
 		errObj.Message = "Trip is already in progress"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "")
 		return result
 
 	case "REQUESTED", "MATCHING":
@@ -106,7 +118,7 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = "Passenger has not confirmed a driver yet for this order"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "")
 		return result
 
 	case "ACCEPTED":
@@ -115,7 +127,7 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = fmt.Sprintf("Order in invalid state for pickup: %s", tripOrder.Status)
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", tripOrder.Status)
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", tripOrder.Status)
 		return result
 	}
 
@@ -123,7 +135,7 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = "No driver assigned to this order yet"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "")
 		return result
 	}
 
@@ -131,16 +143,24 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = "You are not the assigned driver for this order"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "")
 		return result
 	}
 
-	ok, err := c.OrderRepository.UpdateStatusOrderForDriver(ctx, request.OrderID, driverInfo.UserID, "ACCEPTED", "ON_GOING")
+	ok := false
+	err = c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		updated, err := c.OrderRepository.UpdateStatusOrderForDriver(ctx, request.OrderID, driverInfo.UserID, "ACCEPTED", "ON_GOING")
+		if err != nil || !updated {
+			return err
+		}
+		ok = true
+		return c.OrderEvents.StatusChanged(ctx, request.OrderID, "ACCEPTED", "ON_GOING")
+	})
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "Failed to update order status to ON_GOING"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", fmt.Sprintf("Error update status order: %v", err), "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Error update status order: %v", err), "PickupPassanger", "")
 		return result
 	}
 
@@ -148,31 +168,35 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order could not be updated to ON_GOING. It may have been changed or cancelled."
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", "concurrent-update")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", "concurrent-update")
 		return result
 	}
 	tripOrder.Status = "ON_GOING"
+	if err := startTripTrack(ctx, c.Redis, request.OrderID, driverInfo.UserID, time.Now()); err != nil {
+		// completion falls back to the planned distance
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed start trip track: %v", err), "PickupPassanger", request.OrderID)
+	}
 	if err := c.DriverRepository.SetOnTrip(ctx, driverInfo.UserID); err != nil {
-		c.Log.Error("driver-usecase", fmt.Sprintf("Failed update driver availability: %v", err), "PickupPassanger", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed update driver availability: %v", err), "PickupPassanger", "")
 		// to do send event to handle failed update driver availableity
 	}
 
 	marshaledData, _ := json.Marshal(tripOrder)
-	c.Log.Info("driver-usecase", "marshaled trip order", "PickupPassanger", utils.ConvertString(marshaledData))
+	c.Log.WithContext(ctx).Info("driver-usecase", "marshaled trip order", "PickupPassanger", utils.ConvertString(marshaledData))
 	key := fmt.Sprintf("DRIVER:PICKING-PASSANGER:%s", driverInfo.UserID)
 	redisErr := c.Redis.Set(ctx, key, marshaledData, 2*time.Hour).Err()
 	if redisErr != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Internal server error insert to redis: %v", redisErr.Error())
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(redisErr.Error()))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(redisErr.Error()))
 		return result
 	}
 
 	event := converter.OrderToEvent(request)
-	c.Log.Info("driver-usecase", "Publishing user created event", "FindDriver", utils.ConvertString(event))
-	if err = c.DriverProducer.SendRequestRide(event); err != nil {
-		c.Log.Error("driver-usecase", fmt.Sprintf("Failed publish driver created event : %+v", err), "order created", "")
+	c.Log.WithContext(ctx).Info("driver-usecase", "Publishing user created event", "FindDriver", utils.ConvertString(event))
+	if err = c.DriverProducer.SendRequestRide(ctx, event); err != nil {
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed publish driver created event : %+v", err), "order created", "")
 	}
 
 	result.Data = tripOrder
@@ -180,114 +204,266 @@ func (c *DriverUseCase) PickupPassanger(ctx context.Context, request *model.Pick
 	return result
 }
 
-func (c *DriverUseCase) CompletedTrip(ctx context.Context, request *model.RequestCompleteTrip) utils.Result {
+func (c *DriverUseCase) AcceptPickup(ctx context.Context, request *model.AcceptPickupRequest) utils.Result {
 	var result utils.Result
 
 	if err := c.Validate.Struct(request); err != nil {
 		errObj := httpError.NewBadRequest()
 		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", utils.ConvertString(err))
 		return result
 	}
 
-	tripOrder, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{DriverID: &request.DriverID, OrderID: &request.OrderID})
+	tripOrder, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &request.OrderID})
 	if err != nil || tripOrder == nil {
 		errObj := httpError.NewNotFound()
 		errObj.Message = "Order not found"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", utils.ConvertString(err))
 		return result
 	}
-	if tripOrder.Status != "ON_GOING" {
+	if tripOrder.Status != "REQUESTED" && tripOrder.Status != "MATCHING" {
 		errObj := httpError.NewConflict()
-		errObj.Message = fmt.Sprintf("Cannot complete trip in status %s", tripOrder.Status)
+		errObj.Message = "Order is no longer waiting for a driver"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", tripOrder.Status)
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", tripOrder.Status)
 		return result
 	}
-	var tracker model.TripTracker
-	key := fmt.Sprintf("trip:%s", request.OrderID)
-	driverTracker, errRedis := c.Redis.Get(ctx, key).Result()
-	if errRedis != nil || driverTracker == "" {
-		errObj := httpError.NewNotFound()
-		errObj.Message = fmt.Sprintf("Error get data from redis: %v", errRedis)
+
+	offered := false
+	if tripOrder.DispatchMode == model.DispatchModeBatch {
+		batchDriver, _ := c.Redis.Get(ctx, fmt.Sprintf("ORDER:BATCH-OFFER:%s", request.OrderID)).Result()
+		offered = batchDriver == request.DriverID
+	} else {
+		offered, err = c.Redis.SIsMember(ctx, orderCandidatesKey(request.OrderID), request.DriverID).Result()
+		if err != nil && err != redis.Nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = fmt.Sprintf("Internal server error read redis: %v", err)
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", request.OrderID)
+			return result
+		}
+	}
+	if !offered {
+		errObj := httpError.NewConflict()
+		errObj.Message = "This order was not offered to you or the offer has expired"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", request.OrderID)
+		return result
+	}
+
+	if debtBlockedDrivers(ctx, c.Redis, []string{request.DriverID})[request.DriverID] {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Settle your cash commission debt before taking new orders"
+		errObj.Data = map[string]interface{}{"endpoint": "POST /drivers/v1/debt/settle"}
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", utils.ConvertString(errRedis))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", request.DriverID)
 		return result
 	}
-	if err := json.Unmarshal([]byte(driverTracker), &tracker); err != nil {
+
+	destinations := loadDriverDestinations(ctx, c.Redis, []string{request.DriverID})
+	if destination, ok := destinations[request.DriverID]; ok {
+		positions, err := c.Redis.GeoPos(ctx, "drivers-locations", request.DriverID).Result()
+		if err == nil && len(positions) > 0 && positions[0] != nil &&
+			!destinationAllowsTrip(c.Config, destination, positions[0].Latitude, positions[0].Longitude, tripOrder.DestinationLat, tripOrder.DestinationLng) {
+			errObj := httpError.NewConflict()
+			errObj.Message = "This order does not head towards your destination"
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", request.OrderID)
+			return result
+		}
+	}
+
+	// offers are scored by accept time so a repeated accept keeps the driver's original place
+	key := fmt.Sprintf("ORDER:PICKUP-OFFERS:%s", request.OrderID)
+	now := time.Now()
+	pipe := c.Redis.TxPipeline()
+	pipe.ZAddNX(ctx, key, redis.Z{Score: float64(now.UnixMilli()), Member: request.DriverID})
+	pipe.Expire(ctx, key, MatchingTimeoutMinutes*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
 		errObj := httpError.NewInternalServerError()
-		errObj.Message = fmt.Sprintf("Error unmarshal tripdata: %v", err)
+		errObj.Message = fmt.Sprintf("Internal server error insert to redis: %v", err)
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "AcceptPickup", utils.ConvertString(err))
 		return result
 	}
 
-	if tracker.Data.DriverID != "" && tracker.Data.DriverID != request.DriverID {
-		errObj := httpError.NewConflict()
-		errObj.Message = "Trip data does not belong to this driver"
+	result.Data = map[string]interface{}{
+		"order_id":  request.OrderID,
+		"driver_id": request.DriverID,
+		"message":   "Pickup request accepted, waiting for passenger confirmation",
+	}
+
+	return result
+}
+
+func (c *DriverUseCase) CompletedTrip(ctx context.Context, request *model.RequestCompleteTrip) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", utils.ConvertString(tracker))
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "CompletedTrip", utils.ConvertString(err))
 		return result
 	}
 
-	realDistance, err := strconv.ParseFloat(tracker.Data.Distance, 64)
+	tripOrder, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{DriverID: &request.DriverID, OrderID: &request.OrderID})
+	if err != nil || tripOrder == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Order not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "PickupPassanger", utils.ConvertString(err))
+		return result
+	}
+	if tripOrder.Status != "ON_GOING" {
+		errObj := httpError.NewConflict()
+		errObj.Message = fmt.Sprintf("Cannot complete trip in status %s", tripOrder.Status)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "CompletedTrip", tripOrder.Status)
+		return result
+	}
+	if tripOrder.PaymentMethod == PaymentMethodCash && request.CashCollected == nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Confirm the cash amount collected from the passenger"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "CompletedTrip", request.OrderID)
+		return result
+	}
+	// the distance driven comes from the tracked path, the planned route covers a
+	// trip whose driver sent no locations
+	realDistance := tripOrder.BestRouteKm
+	track, path, err := loadTripTrack(ctx, c.Redis, request.OrderID)
 	if err != nil {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = fmt.Sprintf("Invalid distance value: %v", err)
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed load trip track, using planned distance: %v", err), "CompletedTrip", request.OrderID)
+	}
+	if track != nil && track.DriverID != request.DriverID {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Trip data does not belong to this driver"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", tracker.Data.Distance)
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "CompletedTrip", track.DriverID)
 		return result
 	}
+	var tracked *entity.TripTrack
+	if track != nil && track.Points >= 2 {
+		realDistance = math.Round(track.DistanceKm*1000) / 1000
+		rawPath, _ := json.Marshal(path)
+		tracked = &entity.TripTrack{
+			OrderID:    request.OrderID,
+			DriverID:   request.DriverID,
+			DistanceKm: realDistance,
+			PointCount: len(path),
+			Path:       string(rawPath),
+			StartedAt:  track.StartedAt,
+			EndedAt:    track.LastAt,
+		}
+	} else {
+		c.Log.WithContext(ctx).Info("driver-usecase", fmt.Sprintf("No tracked path, using planned distance %.2f km", realDistance), "CompletedTrip", request.OrderID)
+	}
 
 	if tripOrder.DriverID != nil && *tripOrder.DriverID != "" {
 		keyStatusDriver := fmt.Sprintf("DRIVER:PICKING-PASSANGER:%s", *tripOrder.DriverID)
 		if err := c.Redis.Del(ctx, keyStatusDriver).Err(); err != nil {
-			c.Log.Error("driver-usecase", fmt.Sprintf("failed delete redis key %s: %v", keyStatusDriver, err), "CompletedTrip", "")
+			c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("failed delete redis key %s: %v", keyStatusDriver, err), "CompletedTrip", "")
 		}
 	}
 	duration := time.Since(tripOrder.UpdatedAt)
 	durationMinutes := int(duration.Minutes())
 	durationFormatted := utils.FormatDuration(durationMinutes)
-	ok, err := c.OrderRepository.CompleteTrip(ctx, request.OrderID, request.DriverID, realDistance, durationFormatted)
+	fare := finalFare(tripOrder, realDistance, request.FarePercentage)
+	orderUpdate := &model.NotificationUser{
+		EventType:     "ORDER_COMPLETED",
+		OrderID:       request.OrderID,
+		DriverID:      request.DriverID,
+		PassengerID:   tripOrder.PassengerID,
+		FinalFare:     fare,
+		PaymentMethod: tripOrder.PaymentMethod,
+		Timestamp:     tripOrder.UpdatedAt,
+	}
+	// the payment settles with the completion, so a crash can neither leave a wallet
+	// reservation uncollected nor lose the commission of a cash trip, and the
+	// ORDER_COMPLETED event tells the wallet service not to debit again
+	paymentStatus := tripOrder.PaymentStatus
+	method, hasMethod := c.PaymentMethods.Lookup(tripOrder.PaymentMethod)
+	ok := false
+	err = c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		completed, err := c.OrderRepository.CompleteTrip(ctx, request.OrderID, request.DriverID, realDistance, durationFormatted, fare)
+		if err != nil || !completed {
+			return err
+		}
+		ok = true
+		if tracked != nil {
+			if err := c.OrderRepository.InsertTripTrack(ctx, tracked); err != nil {
+				return err
+			}
+		}
+		if err := c.OrderEvents.StatusChanged(ctx, request.OrderID, "ON_GOING", "COMPLETED"); err != nil {
+			return err
+		}
+		if hasMethod {
+			status, err := method.Settle(ctx, &PaymentSettlement{Order: tripOrder, Fare: fare, CashCollected: request.CashCollected})
+			if err != nil {
+				return fmt.Errorf("settle %s payment: %w", method.Code(), err)
+			}
+			if status != tripOrder.PaymentStatus {
+				if err := c.OrderRepository.UpdatePaymentStatus(ctx, request.OrderID, status); err != nil {
+					return err
+				}
+				if err := c.OrderEvents.PaymentStatusChanged(ctx, request.OrderID, tripOrder.PaymentStatus, status); err != nil {
+					return err
+				}
+			}
+			paymentStatus = status
+			orderUpdate.PaymentSettled = status == repository.PaymentPaid
+		}
+		message, err := c.DriverProducer.OutboxOrderCompleted(ctx, orderUpdate)
+		if err != nil {
+			return err
+		}
+		return c.OutboxRepository.InsertMessage(ctx, message)
+	})
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "Failed to complete trip"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", fmt.Sprintf("Error updating order to COMPLETED: %v", err), "CompletedTrip", "")
+		c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Error updating order to COMPLETED: %v", err), "CompletedTrip", "")
 		return result
 	}
 	if !ok {
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order could not be completed, it may have been updated or cancelled"
 		result.Error = errObj
-		c.Log.Error("driver-usecase", errObj.Message, "CompletedTrip", "concurrent-update")
+		c.Log.WithContext(ctx).Error("driver-usecase", errObj.Message, "CompletedTrip", "concurrent-update")
 		return result
 	}
 
-	if err := c.DriverRepository.SetOnline(ctx, request.DriverID); err != nil {
-		c.Log.Error("driver-usecase",
-			fmt.Sprintf("Failed update driver availability to online: %v", err),
-			"CompletedTrip",
-			"")
+	// a pooled driver stays on trip until the last passenger of the group is dropped
+	stillOnTrip := false
+	if tripOrder.SharedGroupID != nil {
+		if err := c.SharedRideRepository.LeaveGroup(ctx, *tripOrder.SharedGroupID); err != nil {
+			c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed leave shared group: %v", err), "CompletedTrip", request.OrderID)
+		}
+		active, err := c.OrderRepository.FindOrders(ctx, entity.OrderFilter{DriverID: &request.DriverID, StatusIn: []string{"ACCEPTED", "ON_GOING"}})
+		stillOnTrip = err == nil && len(active) > 0
 	}
-
-	// if wallet kirim ke kafka buat potong saldo
-	if tripOrder.PaymentMethod == "EWALLET" {
-		orderUpdate := &model.NotificationUser{
-			EventType:   "ORDER_COMPLETED",
-			OrderID:     request.OrderID,
-			DriverID:    request.DriverID,
-			PassengerID: tripOrder.PassengerID,
-			Timestamp:   tripOrder.UpdatedAt,
+	if !stillOnTrip {
+		if err := c.DriverRepository.SetOnline(ctx, request.DriverID); err != nil {
+			c.Log.WithContext(ctx).Error("driver-usecase",
+				fmt.Sprintf("Failed update driver availability to online: %v", err),
+				"CompletedTrip",
+				"")
 		}
-		if err := c.DriverProducer.SendOrderCompleted(orderUpdate); err != nil {
-			c.Log.Error("driver-usecase", fmt.Sprintf("Failed publish driver match event: %v", err), "ConfirmOrder", "")
+	}
+
+	// a QRIS receipt is ready once the passenger paid, the payment callback sends it
+	if paymentStatus != "UNPAID" {
+		if err := publishReceiptReady(ctx, c.OrderRepository, c.Config, c.ReceiptProducer, request.OrderID); err != nil {
+			c.Log.WithContext(ctx).Error("driver-usecase", fmt.Sprintf("Failed publish receipt ready event: %v", err), "CompletedTrip", request.OrderID)
 		}
 	}
 
-	_ = c.Redis.Del(ctx, key).Err()
+	_ = clearTripTrack(ctx, c.Redis, request.OrderID)
 	_ = c.Redis.Del(ctx, fmt.Sprintf("order:%s:distance", request.OrderID)).Err()
 	_ = c.Redis.Del(ctx, fmt.Sprintf("order:%s:driver:%s", request.OrderID, request.DriverID)).Err()
 
@@ -296,6 +472,9 @@ func (c *DriverUseCase) CompletedTrip(ctx context.Context, request *model.Reques
 		"driver_id":       request.DriverID,
 		"status":          "COMPLETED",
 		"distance_actual": realDistance,
+		"final_fare":      fare,
+		"payment_method":  tripOrder.PaymentMethod,
+		"payment_status":  paymentStatus,
 		"message":         "Trip completed successfully",
 	}
 
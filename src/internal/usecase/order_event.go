@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/utils"
+	"time"
+)
+
+// OrderEvents writes an order-events message for every change of an order. It
+// must be called with the context of the transaction making the change, the
+// snapshot is read inside it and the message commits with it.
+type OrderEvents struct {
+	OrderRepository  *repository.OrderRepository
+	OutboxRepository *repository.OutboxRepository
+	Producer         *messaging.OrderProducer
+}
+
+func NewOrderEvents(orderRepository *repository.OrderRepository, outboxRepository *repository.OutboxRepository, producer *messaging.OrderProducer) *OrderEvents {
+	return &OrderEvents{
+		OrderRepository:  orderRepository,
+		OutboxRepository: outboxRepository,
+		Producer:         producer,
+	}
+}
+
+// StatusChanged records the move of the order from one status to another, from
+// is empty for a new order.
+func (e *OrderEvents) StatusChanged(ctx context.Context, orderID, from, to string) error {
+	return e.write(ctx, orderID, model.OrderTransition{Field: model.TransitionStatus, From: from, To: to})
+}
+
+func (e *OrderEvents) PaymentStatusChanged(ctx context.Context, orderID, from, to string) error {
+	return e.write(ctx, orderID, model.OrderTransition{Field: model.TransitionPaymentStatus, From: from, To: to})
+}
+
+func (e *OrderEvents) write(ctx context.Context, orderID string, transition model.OrderTransition) error {
+	order, err := e.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &orderID})
+	if err != nil {
+		return err
+	}
+	message, err := e.Producer.OutboxOrderEvent(ctx, &model.OrderLifecycleEvent{
+		EventID:    utils.GenerateUniqueIDWithPrefix("event"),
+		EventType:  transition.EventType(),
+		OrderID:    orderID,
+		Transition: transition,
+		Order:      *order,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return e.OutboxRepository.InsertMessage(ctx, message)
+}
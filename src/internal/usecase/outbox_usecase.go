@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+)
+
+const TypeOutboxRelay = "outbox:relay"
+
+type OutboxUseCase struct {
+	Log              log.Log
+	OutboxRepository *repository.OutboxRepository
+	OutboxProducer   *messaging.OutboxProducer
+	Config           *viper.Viper
+}
+
+func NewOutboxUseCase(
+	logger log.Log,
+	outboxRepository *repository.OutboxRepository,
+	outboxProducer *messaging.OutboxProducer,
+	cfg *viper.Viper,
+) *OutboxUseCase {
+	return &OutboxUseCase{
+		Log:              logger,
+		OutboxRepository: outboxRepository,
+		OutboxProducer:   outboxProducer,
+		Config:           cfg,
+	}
+}
+
+// RelayOutbox publishes the pending outbox messages in the order they were
+// written. A message is marked sent only after Kafka acknowledged it, so a crash
+// in between publishes it again and consumers see it at least once. Once a
+// message fails the later messages of its key wait for it, keeping every order's
+// events in sequence.
+func (c *OutboxUseCase) RelayOutbox(ctx context.Context, t *asynq.Task) error {
+	batchSize := c.Config.GetInt("outbox.batch_size")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	maxAttempts := c.Config.GetInt("outbox.max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+
+	messages, err := c.OutboxRepository.FindDueMessages(ctx, batchSize)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("outbox-usecase", fmt.Sprintf("Failed query outbox messages: %v", err), "RelayOutbox", "")
+		return err
+	}
+	sent := 0
+	blocked := make(map[string]bool)
+	for i := range messages {
+		message := &messages[i]
+		if blocked[message.MessageKey] {
+			continue
+		}
+		if err := c.OutboxProducer.Relay(message); err != nil {
+			blocked[message.MessageKey] = true
+			attempts := message.Attempts + 1
+			failed := attempts >= maxAttempts
+			if errMark := c.OutboxRepository.MarkRetry(ctx, message.ID, err.Error(), time.Now().Add(outboxBackoff(attempts)), failed); errMark != nil {
+				c.Log.WithContext(ctx).Error("outbox-usecase", fmt.Sprintf("Failed reschedule outbox message: %v", errMark), "RelayOutbox", message.MessageKey)
+			}
+			if failed {
+				c.Log.WithContext(ctx).Error("outbox-usecase", fmt.Sprintf("Outbox message %d to %s failed after %d attempts: %v", message.ID, message.Topic, attempts, err), "RelayOutbox", message.MessageKey)
+			}
+			continue
+		}
+		if err := c.OutboxRepository.MarkSent(ctx, message.ID); err != nil {
+			// the message goes out again on the next run, ahead of the rest of its key
+			blocked[message.MessageKey] = true
+			c.Log.WithContext(ctx).Error("outbox-usecase", fmt.Sprintf("Failed mark outbox message sent: %v", err), "RelayOutbox", message.MessageKey)
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		c.Log.WithContext(ctx).Info("outbox-usecase", fmt.Sprintf("Relayed %d of %d outbox messages", sent, len(messages)), "RelayOutbox", "")
+	}
+
+	retention := c.Config.GetDuration("outbox.retention")
+	if retention <= 0 {
+		retention = 72 * time.Hour
+	}
+	if _, err := c.OutboxRepository.DeleteSent(ctx, time.Now().Add(-retention)); err != nil {
+		c.Log.WithContext(ctx).Error("outbox-usecase", fmt.Sprintf("Failed purge sent outbox messages: %v", err), "RelayOutbox", "")
+	}
+	return nil
+}
+
+// outboxBackoff doubles the wait after every failed attempt, up to five minutes.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 8 {
+		return 5 * time.Minute
+	}
+	return time.Duration(1<<attempts) * time.Second
+}
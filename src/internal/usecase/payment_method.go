@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	PaymentMethodEWallet   = "EWALLET"
+	PaymentMethodQris      = "QRIS"
+	PaymentMethodCash      = "CASH"
+	PaymentMethodCorporate = "CORPORATE"
+)
+
+// PaymentBooking is the booking a payment method checks before the order exists.
+type PaymentBooking struct {
+	UserID string
+	// Amount is the quoted maximum price
+	Amount   float64
+	Route    model.Route
+	BookedAt time.Time
+	// CostCenter is the one the passenger asked to bill, CheckBooking of a method
+	// billing a company resolves it and sets CompanyID.
+	CostCenter string
+	CompanyID  string
+}
+
+// RefundOutcome is what a payment method returned for a cancelled order.
+type RefundOutcome struct {
+	Amount               float64
+	PaymentTransactionID *uint64
+	ProviderReference    *string
+}
+
+// PaymentSettlement is the completed trip a payment method collects the fare of.
+type PaymentSettlement struct {
+	Order *entity.Order
+	Fare  float64
+	// CashCollected is what the driver confirmed collecting, only set for cash trips
+	CashCollected *float64
+}
+
+// PaymentMethod is how a payment method takes part in the order lifecycle. The
+// order flow only talks to this interface, adding a method means registering a
+// new implementation in PaymentMethods.
+type PaymentMethod interface {
+	Code() string
+	// Prepaid methods move money before the trip ends and are refunded when the
+	// order is cancelled.
+	Prepaid() bool
+	// CheckBooking runs before the order is created. A failed check sets
+	// result.Error with the response for the passenger.
+	CheckBooking(ctx context.Context, booking *PaymentBooking) utils.Result
+	// Reserve secures the amount once the order exists.
+	Reserve(ctx context.Context, orderID, userID string, amount float64) error
+	// Release gives the reservation back when the order ends without a trip.
+	Release(ctx context.Context, orderID string) error
+	// Settle collects the final fare of a completed trip and returns the payment
+	// status of the order. It runs in the transaction completing the order and
+	// writes to the database through ctx, an error rolls the completion back.
+	Settle(ctx context.Context, settlement *PaymentSettlement) (string, error)
+	// Refund returns what was paid for a cancelled order minus the fee.
+	Refund(ctx context.Context, refund *entity.Refund) (*RefundOutcome, error)
+}
+
+// PaymentMethods is the registry of the payment methods passengers can book with.
+type PaymentMethods struct {
+	methods map[string]PaymentMethod
+	aliases map[string]string
+}
+
+func NewPaymentMethods() *PaymentMethods {
+	return &PaymentMethods{
+		methods: make(map[string]PaymentMethod),
+		aliases: make(map[string]string),
+	}
+}
+
+// Register adds a method, aliases are the other spellings clients and older order
+// rows use for it.
+func (r *PaymentMethods) Register(method PaymentMethod, aliases ...string) *PaymentMethods {
+	r.methods[method.Code()] = method
+	for _, alias := range aliases {
+		r.aliases[strings.ToUpper(alias)] = method.Code()
+	}
+	return r
+}
+
+// Lookup resolves a method code or alias, case insensitive.
+func (r *PaymentMethods) Lookup(code string) (PaymentMethod, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if alias, ok := r.aliases[code]; ok {
+		code = alias
+	}
+	method, ok := r.methods[code]
+	return method, ok
+}
+
+func (r *PaymentMethods) Codes() []string {
+	codes := make([]string, 0, len(r.methods))
+	for code := range r.methods {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ValidatePaymentMethod backs the payment_method validator tag.
+func (r *PaymentMethods) ValidatePaymentMethod(fl validator.FieldLevel) bool {
+	_, ok := r.Lookup(fl.Field().String())
+	return ok
+}
+
+// releasePayment gives back what was reserved for an order that ends without a trip.
+func (c *UserUseCase) releasePayment(ctx context.Context, order *entity.Order) error {
+	method, ok := c.PaymentMethods.Lookup(order.PaymentMethod)
+	if !ok {
+		return nil
+	}
+	return method.Release(ctx, order.OrderID)
+}
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// CashPaymentMethod is paid to the driver at drop-off. The driver keeps the fare and
+// owes the platform its commission, which is booked against the driver wallet.
+type CashPaymentMethod struct {
+	Log                    log.Log
+	DriverRepository       *repository.DriverRepository
+	DriverLedgerRepository *repository.DriverLedgerRepository
+	Config                 *viper.Viper
+	Redis                  redis.UniversalClient
+}
+
+func NewCashPaymentMethod(
+	logger log.Log,
+	driverRepository *repository.DriverRepository,
+	driverLedgerRepository *repository.DriverLedgerRepository,
+	cfg *viper.Viper,
+	redisClient redis.UniversalClient,
+) *CashPaymentMethod {
+	return &CashPaymentMethod{
+		Log:                    logger,
+		DriverRepository:       driverRepository,
+		DriverLedgerRepository: driverLedgerRepository,
+		Config:                 cfg,
+		Redis:                  redisClient,
+	}
+}
+
+func (m *CashPaymentMethod) Code() string {
+	return PaymentMethodCash
+}
+
+func (m *CashPaymentMethod) Prepaid() bool {
+	return false
+}
+
+func (m *CashPaymentMethod) CheckBooking(ctx context.Context, booking *PaymentBooking) utils.Result {
+	return utils.Result{}
+}
+
+func (m *CashPaymentMethod) Reserve(ctx context.Context, orderID, userID string, amount float64) error {
+	return nil
+}
+
+func (m *CashPaymentMethod) Release(ctx context.Context, orderID string) error {
+	return nil
+}
+
+// Settle books the commission of the fare the driver collected as a debit on the
+// driver wallet and blocks the driver from matching once the debt is over the limit.
+func (m *CashPaymentMethod) Settle(ctx context.Context, settlement *PaymentSettlement) (string, error) {
+	order := settlement.Order
+	if order.DriverID == nil || *order.DriverID == "" {
+		return repository.PaymentPaid, nil
+	}
+	collected := settlement.Fare
+	if settlement.CashCollected != nil {
+		collected = *settlement.CashCollected
+	}
+	description := fmt.Sprintf("Commission of cash trip %s", order.OrderID)
+	if collected < settlement.Fare {
+		description = fmt.Sprintf("%s, collected %.0f of %.0f", description, collected, settlement.Fare)
+	}
+
+	// the commission tier follows the driver city and vehicle, the default rate
+	// applies when the driver profile cannot be read
+	var city, vehicleType string
+	if info, err := m.DriverRepository.GetDetailDriver(ctx, *order.DriverID); err == nil && info != nil {
+		city, vehicleType = info.City, info.JenisKendaraan
+	}
+	_, commission, _ := splitFare(m.Config, city, vehicleType, settlement.Fare)
+
+	entry, booked, err := m.DriverLedgerRepository.BookCommission(ctx, &entity.DriverLedgerEntry{
+		EntryID:       fmt.Sprintf("COMMISSION-%s", order.OrderID),
+		DriverID:      *order.DriverID,
+		OrderID:       &order.OrderID,
+		EntryType:     repository.LedgerCommission,
+		Fare:          settlement.Fare,
+		CashCollected: collected,
+		Amount:        commission,
+		Description:   &description,
+	})
+	if err != nil {
+		return repository.PaymentPaid, err
+	}
+	debt := entry.DebtAfter
+	if booked {
+		m.Log.WithContext(ctx).Info("payment-method", fmt.Sprintf("Booked commission %.2f, driver debt %.2f", entry.Amount, debt), "Settle", order.OrderID)
+	} else if debt, err = m.DriverLedgerRepository.GetDebt(ctx, *order.DriverID); err != nil {
+		return repository.PaymentPaid, err
+	}
+	syncDebtBlock(ctx, m.Redis, m.Config, *order.DriverID, debt)
+	return repository.PaymentPaid, nil
+}
+
+func (m *CashPaymentMethod) Refund(ctx context.Context, refund *entity.Refund) (*RefundOutcome, error) {
+	return &RefundOutcome{}, nil
+}
@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const PaymentBilled = "BILLED"
+
+// CorporatePaymentMethod bills the trip to the company of the passenger, nothing
+// is taken from the passenger wallet. Completed trips are collected on the monthly
+// company invoice.
+type CorporatePaymentMethod struct {
+	Log                 log.Log
+	CorporateRepository *repository.CorporateRepository
+	Config              *viper.Viper
+}
+
+func NewCorporatePaymentMethod(logger log.Log, corporateRepository *repository.CorporateRepository, cfg *viper.Viper) *CorporatePaymentMethod {
+	return &CorporatePaymentMethod{
+		Log:                 logger,
+		CorporateRepository: corporateRepository,
+		Config:              cfg,
+	}
+}
+
+func (m *CorporatePaymentMethod) Code() string {
+	return PaymentMethodCorporate
+}
+
+func (m *CorporatePaymentMethod) Prepaid() bool {
+	return false
+}
+
+// CheckBooking only lets active members of an active company book inside the
+// spending policy of the company. The cost center defaults to the one of the
+// member.
+func (m *CorporatePaymentMethod) CheckBooking(ctx context.Context, booking *PaymentBooking) utils.Result {
+	var result utils.Result
+
+	membership, err := m.CorporateRepository.GetMembership(ctx, booking.UserID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get corporate account: %v", err)
+		result.Error = errObj
+		m.Log.WithContext(ctx).Error("payment-method", errObj.Message, "CheckBooking", utils.ConvertString(err))
+		return result
+	}
+	if membership == nil || membership.Member.Status != repository.CompanyActive {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "You are not a member of a corporate account"
+		result.Error = errObj
+		return result
+	}
+	if membership.Company.Status != repository.CompanyActive {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "The corporate account of your company is not active"
+		result.Error = errObj
+		return result
+	}
+	if violation := checkCorporatePolicy(membership.Policy, booking, corporateLocation(m.Config)); violation != "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = violation
+		result.Error = errObj
+		m.Log.WithContext(ctx).Info("payment-method", violation, "CheckBooking", booking.UserID)
+		return result
+	}
+
+	booking.CompanyID = membership.Company.CompanyID
+	if booking.CostCenter == "" {
+		booking.CostCenter = membership.Member.CostCenter
+	}
+	return result
+}
+
+func (m *CorporatePaymentMethod) Reserve(ctx context.Context, orderID, userID string, amount float64) error {
+	return nil
+}
+
+func (m *CorporatePaymentMethod) Release(ctx context.Context, orderID string) error {
+	return nil
+}
+
+// Settle marks the order billed, the fare is invoiced to the company at the end
+// of the month.
+func (m *CorporatePaymentMethod) Settle(ctx context.Context, settlement *PaymentSettlement) (string, error) {
+	return PaymentBilled, nil
+}
+
+func (m *CorporatePaymentMethod) Refund(ctx context.Context, refund *entity.Refund) (*RefundOutcome, error) {
+	return &RefundOutcome{}, nil
+}
+
+// checkCorporatePolicy returns why a booking breaks the policy, empty when it is
+// allowed.
+func checkCorporatePolicy(policy *entity.CompanyPolicy, booking *PaymentBooking, location *time.Location) string {
+	if policy == nil {
+		return ""
+	}
+	if policy.MaxFare != nil && booking.Amount > *policy.MaxFare {
+		return fmt.Sprintf("The fare is over the company limit of %.0f", *policy.MaxFare)
+	}
+
+	bookedAt := booking.BookedAt.In(location)
+	if policy.Weekdays != nil && *policy.Weekdays != "" {
+		allowed := false
+		for _, day := range strings.Split(*policy.Weekdays, ",") {
+			if weekday, err := strconv.Atoi(strings.TrimSpace(day)); err == nil && time.Weekday(weekday) == bookedAt.Weekday() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "Company rides are not allowed on this day"
+		}
+	}
+	if policy.StartTime != nil && policy.EndTime != nil {
+		start, errStart := minuteOfDay(*policy.StartTime)
+		end, errEnd := minuteOfDay(*policy.EndTime)
+		if errStart == nil && errEnd == nil {
+			now := bookedAt.Hour()*60 + bookedAt.Minute()
+			inside := now >= start && now < end
+			if start > end {
+				// the window runs past midnight
+				inside = now >= start || now < end
+			}
+			if !inside {
+				return fmt.Sprintf("Company rides are only allowed between %s and %s", *policy.StartTime, *policy.EndTime)
+			}
+		}
+	}
+	if policy.AllowedZones != nil && *policy.AllowedZones != "" {
+		var zones []model.DispatchZone
+		if err := json.Unmarshal([]byte(*policy.AllowedZones), &zones); err == nil && len(zones) > 0 {
+			origin, destination := booking.Route.Origin, booking.Route.Destination
+			if !inAnyZone(zones, origin.Latitude, origin.Longitude) || !inAnyZone(zones, destination.Latitude, destination.Longitude) {
+				return "The pickup or destination is outside the areas allowed by your company"
+			}
+		}
+	}
+	return ""
+}
+
+func inAnyZone(zones []model.DispatchZone, lat, lng float64) bool {
+	for _, zone := range zones {
+		if zone.Contains(lat, lng) {
+			return true
+		}
+	}
+	return false
+}
+
+func minuteOfDay(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// corporateLocation is the timezone policies and invoice periods are read in.
+func corporateLocation(cfg *viper.Viper) *time.Location {
+	name := cfg.GetString("corporate.timezone")
+	if name == "" {
+		name = "Asia/Jakarta"
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
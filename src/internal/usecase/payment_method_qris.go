@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/payment"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+)
+
+// QrisPaymentMethod is paid by scanning a QR once the trip is completed, the
+// payment is settled by the provider callback.
+type QrisPaymentMethod struct {
+	Log               log.Log
+	PaymentRepository *repository.PaymentRepository
+	QRProvider        payment.QRProvider
+}
+
+func NewQrisPaymentMethod(logger log.Log, paymentRepository *repository.PaymentRepository, qrProvider payment.QRProvider) *QrisPaymentMethod {
+	return &QrisPaymentMethod{
+		Log:               logger,
+		PaymentRepository: paymentRepository,
+		QRProvider:        qrProvider,
+	}
+}
+
+func (m *QrisPaymentMethod) Code() string {
+	return PaymentMethodQris
+}
+
+func (m *QrisPaymentMethod) Prepaid() bool {
+	return true
+}
+
+func (m *QrisPaymentMethod) CheckBooking(ctx context.Context, booking *PaymentBooking) utils.Result {
+	var result utils.Result
+
+	if booking.Amount < 1000 {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "minimum payment amount is 1,000"
+		result.Error = errObj
+		return result
+	}
+	if booking.Amount > 10000000 {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "maximum payment amount exceeded (10,000,000)"
+		result.Error = errObj
+		return result
+	}
+	return result
+}
+
+func (m *QrisPaymentMethod) Reserve(ctx context.Context, orderID, userID string, amount float64) error {
+	return nil
+}
+
+func (m *QrisPaymentMethod) Release(ctx context.Context, orderID string) error {
+	return nil
+}
+
+// Settle leaves the order unpaid, the passenger is asked for the QR of the final
+// fare and the callback marks the order paid.
+func (m *QrisPaymentMethod) Settle(ctx context.Context, settlement *PaymentSettlement) (string, error) {
+	return "UNPAID", nil
+}
+
+// Refund reverses a captured QRIS payment minus the cancellation fee through the
+// QRIS provider, the refund id is the provider idempotency key.
+func (m *QrisPaymentMethod) Refund(ctx context.Context, refund *entity.Refund) (*RefundOutcome, error) {
+	paid, err := m.PaymentRepository.FindPaymentByOrder(ctx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if paid == nil {
+		return &RefundOutcome{}, nil
+	}
+	switch paid.PaymentStatus {
+	case repository.PaymentPaid, "REFUNDED", "PARTIALLY_REFUNDED":
+	default:
+		// nothing was captured, the fee cannot be collected from an unpaid QRIS order
+		return &RefundOutcome{PaymentTransactionID: &paid.ID}, nil
+	}
+
+	amount := math.Max(paid.Amount-refund.FeeAmount, 0)
+	if amount == 0 {
+		return &RefundOutcome{PaymentTransactionID: &paid.ID}, nil
+	}
+	providerReference := ""
+	if paid.ProviderReferenceID != nil {
+		providerReference = *paid.ProviderReferenceID
+	}
+	result, err := m.QRProvider.Refund(ctx, &payment.RefundRequest{
+		Reference:         refund.RefundID,
+		ProviderReference: providerReference,
+		Amount:            amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != payment.StatusSucceeded {
+		return nil, errors.New("refund is still pending at the payment provider")
+	}
+
+	status := "REFUNDED"
+	if amount < paid.Amount {
+		status = "PARTIALLY_REFUNDED"
+	}
+	if err := m.PaymentRepository.UpdatePaymentStatus(ctx, paid.ID, status); err != nil {
+		return nil, err
+	}
+	return &RefundOutcome{Amount: amount, PaymentTransactionID: &paid.ID, ProviderReference: &result.ProviderReference}, nil
+}
@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
+	"order-service/src/pkg/utils"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// WalletPaymentMethod pays from the passenger wallet. The maximum price is held at
+// booking, the final fare is captured from the hold when the trip completes.
+type WalletPaymentMethod struct {
+	Log              log.Log
+	WalletRepository *repository.WalletRepository
+	AsynqClient      *asynq.Client
+}
+
+func NewWalletPaymentMethod(logger log.Log, walletRepository *repository.WalletRepository, asynqClient *asynq.Client) *WalletPaymentMethod {
+	return &WalletPaymentMethod{
+		Log:              logger,
+		WalletRepository: walletRepository,
+		AsynqClient:      asynqClient,
+	}
+}
+
+func (m *WalletPaymentMethod) Code() string {
+	return PaymentMethodEWallet
+}
+
+func (m *WalletPaymentMethod) Prepaid() bool {
+	return true
+}
+
+func (m *WalletPaymentMethod) CheckBooking(ctx context.Context, booking *PaymentBooking) utils.Result {
+	var result utils.Result
+
+	wallet, err := m.WalletRepository.GetWalletByUserID(ctx, booking.UserID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet: %v", err)
+		result.Error = errObj
+		m.Log.WithContext(ctx).Error("payment-method", errObj.Message, "CheckBooking", utils.ConvertString(err))
+		return result
+	}
+	if wallet == nil {
+		result.Error = walletNotFoundError()
+		m.Log.WithContext(ctx).Error("payment-method", "Wallet not found", "CheckBooking", booking.UserID)
+		return result
+	}
+	if wallet.Balance < booking.Amount {
+		result.Error = insufficientBalanceError(booking.Amount, wallet.Balance)
+		m.Log.WithContext(ctx).Error("payment-method", "insufficient balance, please topup", "CheckBooking", booking.UserID)
+		return result
+	}
+	return result
+}
+
+// Reserve holds the amount and schedules the release of the hold for when the
+// order is still unmatched after the matching timeout.
+func (m *WalletPaymentMethod) Reserve(ctx context.Context, orderID, userID string, amount float64) error {
+	if err := m.WalletRepository.PlaceHold(ctx, userID, orderID, amount); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&model.HoldExpiryTask{OrderID: orderID, PassengerID: userID})
+	if err != nil {
+		m.Log.WithContext(ctx).Error("payment-method", fmt.Sprintf("Error marshalling payload: %v", err), "Reserve", orderID)
+		return nil
+	}
+	task := asynq.NewTask(TypeHoldExpiry, tracing.InjectPayload(ctx, payload), asynq.MaxRetry(5), asynq.ProcessIn(MatchingTimeoutMinutes*time.Minute))
+	if _, err := m.AsynqClient.Enqueue(task); err != nil {
+		m.Log.WithContext(ctx).Error("payment-method", fmt.Sprintf("Error enqueue hold expiry task: %v", err), "Reserve", orderID)
+	}
+	return nil
+}
+
+func (m *WalletPaymentMethod) Release(ctx context.Context, orderID string) error {
+	hold, err := m.WalletRepository.ReleaseHold(ctx, orderID)
+	if errors.Is(err, repository.ErrHoldNotFound) {
+		return nil
+	}
+	if err != nil {
+		m.Log.WithContext(ctx).Error("payment-method", fmt.Sprintf("Failed release wallet hold: %v", err), "Release", orderID)
+		return err
+	}
+	m.Log.WithContext(ctx).Info("payment-method", fmt.Sprintf("Wallet hold %s, released %.2f", hold.Status, hold.ReleasedAmount), "Release", orderID)
+	return nil
+}
+
+// Settle captures the fare from the booking hold in the transaction carried by
+// ctx. An order without a hold has nothing to capture and stays unpaid.
+func (m *WalletPaymentMethod) Settle(ctx context.Context, settlement *PaymentSettlement) (string, error) {
+	_, err := m.WalletRepository.CaptureHold(ctx, settlement.Order.OrderID, settlement.Fare)
+	if errors.Is(err, repository.ErrHoldNotFound) {
+		m.Log.WithContext(ctx).Error("payment-method", "No wallet hold to capture", "Settle", settlement.Order.OrderID)
+		return "UNPAID", nil
+	}
+	if err != nil {
+		return "UNPAID", err
+	}
+	return repository.PaymentPaid, nil
+}
+
+// Refund takes the cancellation fee out of the booking hold and returns the rest,
+// money already captured beyond the fee is credited back as a refund.
+func (m *WalletPaymentMethod) Refund(ctx context.Context, refund *entity.Refund) (*RefundOutcome, error) {
+	hold, err := m.WalletRepository.GetHold(ctx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		return &RefundOutcome{}, nil
+	}
+	if hold.Status == repository.WalletHoldHeld {
+		if hold, err = m.WalletRepository.CaptureHold(ctx, refund.OrderID, refund.FeeAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	amount := hold.ReleasedAmount
+	if extra := hold.CapturedAmount - refund.FeeAmount; extra > 0 {
+		if _, err := m.WalletRepository.Refund(ctx, refund.PassengerID, refund.OrderID, refund.RefundID, extra); err != nil {
+			return nil, err
+		}
+		amount += extra
+	}
+	return &RefundOutcome{Amount: amount}, nil
+}
@@ -0,0 +1,436 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/gateway/payment"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
+
+type PaymentUseCase struct {
+	Log               log.Log
+	Validate          *validator.Validate
+	PaymentRepository *repository.PaymentRepository
+	OrderRepository   *repository.OrderRepository
+	Config            *viper.Viper
+	QRProvider        payment.QRProvider
+	ReceiptProducer   *messaging.ReceiptProducer
+	OutboxRepository  *repository.OutboxRepository
+	OrderEvents       *OrderEvents
+	WalletRepository  *repository.WalletRepository
+}
+
+func NewPaymentUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	paymentRepository *repository.PaymentRepository,
+	orderRepository *repository.OrderRepository,
+	cfg *viper.Viper,
+	qrProvider payment.QRProvider,
+	receiptProducer *messaging.ReceiptProducer,
+	outboxRepository *repository.OutboxRepository,
+	orderEvents *OrderEvents,
+	walletRepository *repository.WalletRepository,
+) *PaymentUseCase {
+	return &PaymentUseCase{
+		Log:               logger,
+		Validate:          validate,
+		PaymentRepository: paymentRepository,
+		OrderRepository:   orderRepository,
+		Config:            cfg,
+		QRProvider:        qrProvider,
+		ReceiptProducer:   receiptProducer,
+		OutboxRepository:  outboxRepository,
+		OrderEvents:       orderEvents,
+		WalletRepository:  walletRepository,
+	}
+}
+
+// GetQrisPayment returns the QR the passenger scans to pay a completed QRIS order.
+// A pending QR is reused until it expires, then a new one is issued.
+func (c *PaymentUseCase) GetQrisPayment(ctx context.Context, request *model.QrisPaymentRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "GetQrisPayment", utils.ConvertString(err))
+		return result
+	}
+
+	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &request.OrderID, PassengerID: &request.UserID})
+	if err != nil || order == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Order not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "GetQrisPayment", request.OrderID)
+		return result
+	}
+	if order.PaymentMethod != PaymentMethodQris {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Order is not paid with QRIS"
+		result.Error = errObj
+		return result
+	}
+	if order.PaymentStatus == repository.PaymentPaid {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Order is already paid"
+		result.Error = errObj
+		return result
+	}
+	if order.Status != "COMPLETED" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Payment is due once the trip is completed"
+		result.Error = errObj
+		return result
+	}
+
+	amount := order.BestRoutePrice
+	if order.FinalFare != nil {
+		amount = *order.FinalFare
+	}
+
+	current, err := c.PaymentRepository.FindPaymentByOrder(ctx, order.OrderID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get payment: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "GetQrisPayment", utils.ConvertString(err))
+		return result
+	}
+	if current != nil && current.PaymentStatus == repository.PaymentPending {
+		if current.ExpiresAt != nil && current.ExpiresAt.After(time.Now()) && current.QRPayload != nil && current.Amount == amount {
+			result.Data = toQrisPaymentResponse(current)
+			return result
+		}
+		if err := c.PaymentRepository.UpdatePaymentStatus(ctx, current.ID, repository.PaymentExpired); err != nil {
+			c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Failed expire previous QR: %v", err), "GetQrisPayment", order.OrderID)
+		}
+	}
+
+	expiry := c.Config.GetDuration("payment.qris.expiry")
+	if expiry <= 0 {
+		expiry = 15 * time.Minute
+	}
+	qr, err := c.QRProvider.CreateQR(ctx, &payment.QRRequest{
+		Reference: fmt.Sprintf("%s-%d", order.OrderID, time.Now().Unix()),
+		OrderID:   order.OrderID,
+		Amount:    amount,
+		ExpiresIn: expiry,
+	})
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "Failed create QRIS payment"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("QRIS provider failed: %v", err), "GetQrisPayment", order.OrderID)
+		return result
+	}
+
+	transaction := &entity.PaymentTransaction{
+		RideOrderID:         order.OrderID,
+		Amount:              amount,
+		ProviderName:        &qr.Provider,
+		ProviderReferenceID: &qr.ProviderReference,
+		QRPayload:           &qr.Payload,
+		ExpiresAt:           &qr.ExpiresAt,
+	}
+	if err := c.PaymentRepository.InsertPendingPayment(ctx, transaction); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed save payment: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "GetQrisPayment", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = toQrisPaymentResponse(transaction)
+	return result
+}
+
+// HandleCallback settles a QRIS payment from the provider webhook. The raw body
+// must be signed with payment.qris.callback_secret, redeliveries of a settled
+// payment are acknowledged without changing anything.
+func (c *PaymentUseCase) HandleCallback(ctx context.Context, body []byte, signature string) utils.Result {
+	var result utils.Result
+
+	secret := c.Config.GetString("payment.qris.callback_secret")
+	if secret == "" {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "Payment callback is not configured"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", "payment.qris.callback_secret is empty", "HandleCallback", "")
+		return result
+	}
+	if !payment.VerifyCallback(secret, body, signature) {
+		errObj := httpError.NewUnauthorized()
+		errObj.Message = "Invalid callback signature"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", signature)
+		return result
+	}
+
+	var event payment.CallbackEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Reference == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Invalid callback payload"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", string(body))
+		return result
+	}
+	var status string
+	switch strings.ToUpper(event.Status) {
+	case "PAID", "SUCCESS", "SUCCEEDED":
+		status = repository.PaymentPaid
+	case "FAILED":
+		status = repository.PaymentFailed
+	case "EXPIRED":
+		status = repository.PaymentExpired
+	default:
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("Unknown payment status %q", event.Status)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", event.Reference)
+		return result
+	}
+
+	provider := c.QRProvider.Name()
+	current, err := c.PaymentRepository.FindPaymentByReference(ctx, provider, event.Reference)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get payment: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", event.Reference)
+		return result
+	}
+	if current == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Payment not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", event.Reference)
+		return result
+	}
+	if status == repository.PaymentPaid && math.Abs(event.Amount-current.Amount) >= 0.01 {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Paid amount does not match the payment"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", utils.ConvertString(event))
+		return result
+	}
+
+	paidAt := event.PaidAt
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+	// the order turns PAID with the payment, its order event commits with both
+	var settledPayment *entity.PaymentTransaction
+	settled := false
+	err = c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &current.RideOrderID})
+		if err != nil {
+			return err
+		}
+		settledPayment, settled, err = c.PaymentRepository.SettlePayment(ctx, provider, event.Reference, status, paidAt)
+		if err != nil || !settled || settledPayment.PaymentStatus != repository.PaymentPaid || order.PaymentStatus == repository.PaymentPaid {
+			return err
+		}
+		return c.OrderEvents.PaymentStatusChanged(ctx, order.OrderID, order.PaymentStatus, repository.PaymentPaid)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrPaymentNotFound) {
+			errObj := httpError.NewNotFound()
+			errObj.Message = "Payment not found"
+			result.Error = errObj
+			return result
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed settle payment: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payment-usecase", errObj.Message, "HandleCallback", event.Reference)
+		return result
+	}
+	if settled && settledPayment.PaymentStatus == repository.PaymentRefundDue {
+		c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Reconciliation error: QRIS payment %s of %.2f paid an order already paid, refund due", event.Reference, event.Amount), "HandleCallback", settledPayment.RideOrderID)
+	} else if settled {
+		c.Log.WithContext(ctx).Info("payment-usecase", fmt.Sprintf("QRIS payment %s", settledPayment.PaymentStatus), "HandleCallback", settledPayment.RideOrderID)
+		if settledPayment.PaymentStatus == repository.PaymentPaid {
+			if err := publishReceiptReady(ctx, c.OrderRepository, c.Config, c.ReceiptProducer, settledPayment.RideOrderID); err != nil {
+				c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Failed publish receipt ready event: %v", err), "HandleCallback", settledPayment.RideOrderID)
+			}
+		}
+	}
+
+	result.Data = model.PaymentCallbackResponse{
+		Reference: event.Reference,
+		OrderID:   settledPayment.RideOrderID,
+		Status:    settledPayment.PaymentStatus,
+		Settled:   settled,
+	}
+	return result
+}
+
+// ErrInvalidPaymentResult is a payment result no retry can apply.
+var ErrInvalidPaymentResult = errors.New("invalid payment result")
+
+const PaymentProviderWallet = "WALLET"
+
+// HandlePaymentResult applies the result of the debit the wallet service made
+// for a completed order. The transaction reference makes it idempotent, a
+// redelivered result is recorded once. A failed debit flags the order for
+// collection, a later successful one resolves it. A successful debit of an order
+// whose fare was already captured from its booking hold charged the passenger
+// twice, it is recorded as reversed and credited back to the wallet.
+func (c *PaymentUseCase) HandlePaymentResult(ctx context.Context, event *model.PaymentResultEvent) error {
+	var status string
+	switch strings.ToUpper(event.Status) {
+	case "PAID", "SUCCESS", "SUCCEEDED":
+		status = repository.PaymentPaid
+	case "FAILED", "DECLINED", "INSUFFICIENT_BALANCE":
+		status = repository.PaymentFailed
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidPaymentResult, event.Status)
+	}
+	reference := event.TransactionID
+	if reference == "" {
+		reference = event.EventID
+	}
+	if event.OrderID == "" || reference == "" {
+		return fmt.Errorf("%w: missing order or transaction id", ErrInvalidPaymentResult)
+	}
+	provider := event.Provider
+	if provider == "" {
+		provider = PaymentProviderWallet
+	}
+	processedAt := event.ProcessedAt
+	if processedAt.IsZero() {
+		processedAt = time.Now()
+	}
+
+	recorded, paid, reversed := false, false, false
+	err := c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		orders, err := c.OrderRepository.FindOrders(ctx, entity.OrderFilter{OrderID: &event.OrderID})
+		if err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return fmt.Errorf("%w: order %s not found", ErrInvalidPaymentResult, event.OrderID)
+		}
+		order := &orders[0]
+
+		transaction := &entity.PaymentTransaction{
+			RideOrderID:         order.OrderID,
+			Amount:              event.Amount,
+			PaymentStatus:       status,
+			ProviderName:        &provider,
+			ProviderReferenceID: &reference,
+		}
+		if status == repository.PaymentPaid {
+			transaction.PaidAt = &processedAt
+			hold, err := c.WalletRepository.GetHold(ctx, order.OrderID)
+			if err != nil {
+				return err
+			}
+			reversed = hold != nil && hold.Status == repository.WalletHoldCaptured
+			if reversed {
+				transaction.PaymentStatus = repository.PaymentReversed
+			}
+		}
+		if recorded, err = c.PaymentRepository.InsertSettledPayment(ctx, transaction); err != nil || !recorded {
+			return err
+		}
+		if reversed {
+			_, err := c.WalletRepository.Refund(ctx, order.PassengerID, order.OrderID, fmt.Sprintf("REVERSAL-%d", transaction.ID), event.Amount)
+			return err
+		}
+
+		if status == repository.PaymentPaid {
+			if err := c.PaymentRepository.ResolveCollection(ctx, order.OrderID, transaction.ID); err != nil {
+				return err
+			}
+		} else {
+			if order.PaymentStatus == repository.PaymentPaid {
+				// the fare was collected another way, nothing is owed
+				return nil
+			}
+			reason := event.Reason
+			if reason == "" {
+				reason = "wallet debit failed"
+			}
+			if err := c.PaymentRepository.FlagForCollection(ctx, &entity.PaymentCollection{
+				OrderID:              order.OrderID,
+				PassengerID:          order.PassengerID,
+				Amount:               event.Amount,
+				Reason:               reason,
+				PaymentTransactionID: &transaction.ID,
+			}); err != nil {
+				return err
+			}
+		}
+		if order.PaymentStatus == status {
+			return nil
+		}
+		changed, err := c.OrderRepository.TransitionPaymentStatus(ctx, order.OrderID, order.PaymentStatus, status)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			// rolled back and applied again on the retry against the new status
+			return fmt.Errorf("payment status of order %s changed concurrently", order.OrderID)
+		}
+		paid = status == repository.PaymentPaid
+		return c.OrderEvents.PaymentStatusChanged(ctx, order.OrderID, order.PaymentStatus, status)
+	})
+	if err != nil {
+		c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Failed apply payment result: %v", err), "HandlePaymentResult", event.OrderID)
+		return err
+	}
+	if !recorded {
+		c.Log.WithContext(ctx).Info("payment-usecase", fmt.Sprintf("Payment result %s already applied", reference), "HandlePaymentResult", event.OrderID)
+		return nil
+	}
+	if reversed {
+		c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Reconciliation error: wallet debit %s of %.2f charged an order already captured from its booking hold, reversed it", reference, event.Amount), "HandlePaymentResult", event.OrderID)
+		return nil
+	}
+	c.Log.WithContext(ctx).Info("payment-usecase", fmt.Sprintf("Wallet payment %s", status), "HandlePaymentResult", event.OrderID)
+	if paid {
+		if err := publishReceiptReady(ctx, c.OrderRepository, c.Config, c.ReceiptProducer, event.OrderID); err != nil {
+			c.Log.WithContext(ctx).Error("payment-usecase", fmt.Sprintf("Failed publish receipt ready event: %v", err), "HandlePaymentResult", event.OrderID)
+		}
+	}
+	return nil
+}
+
+func toQrisPaymentResponse(transaction *entity.PaymentTransaction) model.QrisPaymentResponse {
+	response := model.QrisPaymentResponse{
+		OrderID: transaction.RideOrderID,
+		Amount:  transaction.Amount,
+		Status:  transaction.PaymentStatus,
+	}
+	if transaction.ProviderName != nil {
+		response.Provider = *transaction.ProviderName
+	}
+	if transaction.ProviderReferenceID != nil {
+		response.ProviderReference = *transaction.ProviderReferenceID
+	}
+	if transaction.QRPayload != nil {
+		response.QRPayload = *transaction.QRPayload
+	}
+	if transaction.ExpiresAt != nil {
+		response.ExpiresAt = *transaction.ExpiresAt
+	}
+	return response
+}
@@ -0,0 +1,278 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/gateway/payment"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/databases/mysql/mysqltest"
+	kafka "order-service/src/pkg/kafka/confluent"
+	"order-service/src/pkg/log"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// quietLog drops every entry, its level is above ERROR.
+var quietLog = log.Log{LogLevel: 3}
+
+const callbackSecret = "callback-secret"
+
+func TestVerifyCallback(t *testing.T) {
+	body := []byte(`{"reference":"FQR-1","status":"PAID","amount":25000}`)
+	signature := payment.SignCallback(callbackSecret, body)
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "signed body", secret: callbackSecret, body: body, signature: signature, want: true},
+		{name: "surrounding spaces", secret: callbackSecret, body: body, signature: " " + signature + "\n", want: true},
+		{name: "other secret", secret: "other", body: body, signature: signature},
+		{name: "changed body", secret: callbackSecret, body: []byte(`{"reference":"FQR-1","status":"PAID","amount":1}`), signature: signature},
+		{name: "not hex", secret: callbackSecret, body: body, signature: "zz"},
+		{name: "empty", secret: callbackSecret, body: body, signature: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := payment.VerifyCallback(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Fatalf("VerifyCallback() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCallbackRejects(t *testing.T) {
+	paid := callbackBody("FQR-1", "PAID", 25000)
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      int
+	}{
+		{name: "no secret configured", body: paid, signature: payment.SignCallback("", paid), want: http.StatusInternalServerError},
+		{name: "bad signature", secret: callbackSecret, body: paid, signature: payment.SignCallback("other", paid), want: http.StatusUnauthorized},
+		{name: "not json", secret: callbackSecret, body: []byte("paid"), want: http.StatusBadRequest},
+		{name: "no reference", secret: callbackSecret, body: callbackBody("", "PAID", 25000), want: http.StatusBadRequest},
+		{name: "unknown status", secret: callbackSecret, body: callbackBody("FQR-1", "REVERSED", 25000), want: http.StatusBadRequest},
+		{name: "unknown payment", secret: callbackSecret, body: callbackBody("FQR-404", "PAID", 25000), want: http.StatusNotFound},
+		{name: "amount mismatch", secret: callbackSecret, body: callbackBody("FQR-1", "PAID", 20000), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &paymentStore{paymentStatus: repository.PaymentPending, orderPaymentStatus: "UNPAID"}
+			db := mysqltest.New(store.handle)
+			signature := tt.signature
+			if signature == "" {
+				signature = payment.SignCallback(tt.secret, tt.body)
+			}
+			result := newCallbackUseCase(db, tt.secret).HandleCallback(context.Background(), tt.body, signature)
+			if got := errorCode(result.Error); got != tt.want {
+				t.Fatalf("HandleCallback() error = %+v, want code %d", result.Error, tt.want)
+			}
+			if updates := db.Statements("UPDATE"); len(updates) != 0 {
+				t.Fatalf("a rejected callback ran %v", updates)
+			}
+		})
+	}
+}
+
+func TestHandleCallbackSettles(t *testing.T) {
+	tests := []struct {
+		name               string
+		paymentStatus      string
+		orderPaymentStatus string
+		callbackStatus     string
+		wantSettled        bool
+		wantPayment        string
+		wantOrderPaid      bool
+	}{
+		{
+			name:               "pending QR paid",
+			paymentStatus:      repository.PaymentPending,
+			orderPaymentStatus: "UNPAID",
+			callbackStatus:     "PAID",
+			wantSettled:        true,
+			wantPayment:        repository.PaymentPaid,
+			wantOrderPaid:      true,
+		},
+		{
+			name:               "pending QR expired",
+			paymentStatus:      repository.PaymentPending,
+			orderPaymentStatus: "UNPAID",
+			callbackStatus:     "EXPIRED",
+			wantSettled:        true,
+			wantPayment:        repository.PaymentExpired,
+		},
+		{
+			name:               "redelivered payment",
+			paymentStatus:      repository.PaymentPaid,
+			orderPaymentStatus: repository.PaymentPaid,
+			callbackStatus:     "PAID",
+			wantPayment:        repository.PaymentPaid,
+		},
+		{
+			name:               "expired QR paid late for an unpaid order",
+			paymentStatus:      repository.PaymentExpired,
+			orderPaymentStatus: "UNPAID",
+			callbackStatus:     "PAID",
+			wantSettled:        true,
+			wantPayment:        repository.PaymentPaid,
+			wantOrderPaid:      true,
+		},
+		{
+			name:               "expired QR paid late for a paid order",
+			paymentStatus:      repository.PaymentExpired,
+			orderPaymentStatus: repository.PaymentPaid,
+			callbackStatus:     "PAID",
+			wantSettled:        true,
+			wantPayment:        repository.PaymentRefundDue,
+		},
+		{
+			name:               "pending QR paid for an order paid with another QR",
+			paymentStatus:      repository.PaymentPending,
+			orderPaymentStatus: repository.PaymentPaid,
+			callbackStatus:     "PAID",
+			wantSettled:        true,
+			wantPayment:        repository.PaymentRefundDue,
+		},
+		{
+			name:               "failure after expiry",
+			paymentStatus:      repository.PaymentExpired,
+			orderPaymentStatus: "UNPAID",
+			callbackStatus:     "FAILED",
+			wantPayment:        repository.PaymentExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &paymentStore{paymentStatus: tt.paymentStatus, orderPaymentStatus: tt.orderPaymentStatus}
+			db := mysqltest.New(store.handle)
+			body := callbackBody("FQR-1", tt.callbackStatus, 25000)
+
+			result := newCallbackUseCase(db, callbackSecret).HandleCallback(context.Background(), body, payment.SignCallback(callbackSecret, body))
+			if result.Error != nil {
+				t.Fatalf("HandleCallback() error = %+v", result.Error)
+			}
+			response, ok := result.Data.(model.PaymentCallbackResponse)
+			if !ok {
+				t.Fatalf("HandleCallback() data = %#v", result.Data)
+			}
+			if response.Settled != tt.wantSettled || response.Status != tt.wantPayment || response.OrderID != "order-1" {
+				t.Fatalf("response = %+v, want settled %v with status %s", response, tt.wantSettled, tt.wantPayment)
+			}
+			if store.paymentStatus != tt.wantPayment {
+				t.Fatalf("payment status = %s, want %s", store.paymentStatus, tt.wantPayment)
+			}
+			if paid := store.orderPaymentStatus == repository.PaymentPaid && tt.orderPaymentStatus != repository.PaymentPaid; paid != tt.wantOrderPaid {
+				t.Fatalf("order payment status %s -> %s, want paid %v", tt.orderPaymentStatus, store.orderPaymentStatus, tt.wantOrderPaid)
+			}
+			// the order event commits with the payment
+			events := db.Statements("INSERT INTO outbox_messages")
+			if tt.wantOrderPaid != (len(events) == 1) {
+				t.Fatalf("wrote %d order events, want one only when the order turns paid", len(events))
+			}
+			for _, update := range db.Statements("UPDATE") {
+				if update.Tx == 0 {
+					t.Fatalf("%q ran outside the settlement transaction", update.Query)
+				}
+			}
+		})
+	}
+}
+
+func newCallbackUseCase(db *mysqltest.DB, secret string) *PaymentUseCase {
+	cfg := viper.New()
+	cfg.Set("payment.qris.callback_secret", secret)
+	orderRepository := repository.NewOrderRepository(db)
+	outboxRepository := repository.NewOutboxRepository(db)
+	producer := kafka.DiscardProducer{}
+	return NewPaymentUseCase(
+		quietLog,
+		nil,
+		repository.NewPaymentRepository(db),
+		orderRepository,
+		cfg,
+		payment.NewFakeQRProvider(cfg, quietLog),
+		messaging.NewReceiptProducer(producer, nil, quietLog),
+		outboxRepository,
+		NewOrderEvents(orderRepository, outboxRepository, messaging.NewOrderProducer(producer, nil, quietLog)),
+		repository.NewWalletRepository(db),
+	)
+}
+
+func callbackBody(reference, status string, amount float64) []byte {
+	body, _ := json.Marshal(payment.CallbackEvent{
+		EventID:   "evt-1",
+		Reference: reference,
+		Status:    status,
+		Amount:    amount,
+		PaidAt:    time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC),
+	})
+	return body
+}
+
+// paymentStore answers the statements of the callback for one QRIS payment,
+// FQR-1 of order-1.
+type paymentStore struct {
+	paymentStatus      string
+	orderPaymentStatus string
+}
+
+func (s *paymentStore) handle(query string, args []interface{}) mysqltest.Result {
+	switch {
+	case mysqltest.Contains(query, "FROM payment_transactions WHERE provider_name = ?"):
+		if args[1] != "FQR-1" {
+			return mysqltest.Result{}
+		}
+		return mysqltest.Result{
+			Columns: []string{"id", "ride_order_id", "amount", "payment_status", "provider_name", "provider_reference_id"},
+			Rows:    [][]interface{}{{int64(7), "order-1", 25000.0, s.paymentStatus, "fake-qris", "FQR-1"}},
+		}
+	case mysqltest.Contains(query, "SELECT payment_status FROM orders WHERE order_id = ? FOR UPDATE"):
+		return mysqltest.Result{Columns: []string{"payment_status"}, Rows: [][]interface{}{{s.orderPaymentStatus}}}
+	case mysqltest.Contains(query, "FROM orders o WHERE"):
+		return mysqltest.Result{
+			Columns: []string{"order_id", "passenger_id", "status", "payment_method", "payment_status", "created_at", "updated_at"},
+			Rows:    [][]interface{}{{"order-1", "passenger-1", "COMPLETED", PaymentMethodQris, s.orderPaymentStatus, time.Now(), time.Now()}},
+		}
+	case mysqltest.Contains(query, "UPDATE payment_transactions SET payment_status = ?"):
+		if args[3] != s.paymentStatus {
+			return mysqltest.Result{}
+		}
+		s.paymentStatus = args[0].(string)
+		return mysqltest.Result{RowsAffected: 1}
+	case mysqltest.Contains(query, "UPDATE orders SET payment_status = ?"):
+		s.orderPaymentStatus = args[0].(string)
+		return mysqltest.Result{RowsAffected: 1}
+	case mysqltest.Contains(query, "INSERT INTO outbox_messages"):
+		return mysqltest.Result{RowsAffected: 1, LastInsertID: 1}
+	case mysqltest.Contains(query, "FROM orders o LEFT JOIN"):
+		// the receipt is published once the order detail is found
+		return mysqltest.Result{}
+	}
+	return mysqltest.Result{Err: fmt.Errorf("unexpected statement %q", query)}
+}
+
+// errorCode returns the code of a use case error, 0 without one.
+func errorCode(err interface{}) int {
+	if err == nil {
+		return 0
+	}
+	raw, _ := json.Marshal(err)
+	var body struct {
+		Code int `json:"code"`
+	}
+	if json.Unmarshal(raw, &body) != nil {
+		return -1
+	}
+	return body.Code
+}
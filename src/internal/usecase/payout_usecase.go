@@ -0,0 +1,336 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/constants"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
+	"order-service/src/pkg/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+)
+
+const (
+	TypePayoutSettle   = "payout:settle"
+	TypePayoutDisburse = "payout:disburse"
+)
+
+type PayoutUseCase struct {
+	Log              log.Log
+	Validate         *validator.Validate
+	PayoutRepository *repository.PayoutRepository
+	Config           *viper.Viper
+	AsynqClient      *asynq.Client
+}
+
+func NewPayoutUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	payoutRepository *repository.PayoutRepository,
+	cfg *viper.Viper,
+	asynqClient *asynq.Client,
+) *PayoutUseCase {
+	return &PayoutUseCase{
+		Log:              logger,
+		Validate:         validate,
+		PayoutRepository: payoutRepository,
+		Config:           cfg,
+		AsynqClient:      asynqClient,
+	}
+}
+
+// SettlePayouts is the periodic settlement job. The paid wallet and QRIS orders
+// and the billed corporate orders completed before today are split between the
+// driver and the platform and added to the payout batch of their driver for
+// yesterday, orders paid after that batch was disbursed go to the batch of the
+// next day.
+func (c *PayoutUseCase) SettlePayouts(ctx context.Context, t *asynq.Task) error {
+	now := time.Now().In(c.payoutLocation())
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	payoutDate := cutoff.AddDate(0, 0, -1)
+
+	orders, err := c.PayoutRepository.FindPayableOrders(ctx, cutoff)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed get payable orders: %v", err), "SettlePayouts", "")
+		return err
+	}
+
+	items := make(map[string][]entity.PayoutItem)
+	var drivers []string
+	for _, order := range orders {
+		rate, commission, earning := splitFare(c.Config, order.City, order.VehicleType, order.Fare)
+		if _, ok := items[order.DriverID]; !ok {
+			drivers = append(drivers, order.DriverID)
+		}
+		items[order.DriverID] = append(items[order.DriverID], entity.PayoutItem{
+			OrderID:        order.OrderID,
+			PaymentMethod:  order.PaymentMethod,
+			City:           order.City,
+			VehicleType:    order.VehicleType,
+			Fare:           order.Fare,
+			CommissionRate: rate,
+			Commission:     commission,
+			Earning:        earning,
+			CompletedAt:    order.CompletedAt,
+		})
+	}
+
+	for _, driverID := range drivers {
+		batch, err := c.PayoutRepository.AddToBatch(ctx, &entity.PayoutBatch{
+			BatchID:    fmt.Sprintf("PAYOUT-%s-%s", driverID, payoutDate.Format("20060102")),
+			DriverID:   driverID,
+			PayoutDate: payoutDate,
+		}, items[driverID])
+		if errors.Is(err, repository.ErrPayoutBatchClosed) {
+			c.Log.WithContext(ctx).Info("payout-usecase", "Payout batch already disbursed, orders wait for the next batch", "SettlePayouts", batch.BatchID)
+			continue
+		}
+		if err != nil {
+			c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed add orders to payout batch: %v", err), "SettlePayouts", driverID)
+			continue
+		}
+		c.Log.WithContext(ctx).Info("payout-usecase", fmt.Sprintf("Payout batch has %d trips, net %.2f", batch.TripCount, batch.NetAmount), "SettlePayouts", batch.BatchID)
+	}
+
+	// pending batches are disbursed here, including the ones an earlier run could
+	// not enqueue
+	status := repository.PayoutPending
+	pending, _, err := c.PayoutRepository.FindBatches(ctx, entity.PayoutFilter{Status: &status, To: &cutoff, Limit: 1000})
+	if err != nil {
+		c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed get pending payout batches: %v", err), "SettlePayouts", "")
+		return err
+	}
+	for _, batch := range pending {
+		if err := c.enqueueDisburse(ctx, batch.BatchID); err != nil {
+			c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Error enqueue payout task: %v", err), "SettlePayouts", batch.BatchID)
+		}
+	}
+	return nil
+}
+
+// DisbursePayout pays a batch out to the driver wallet. A failed attempt marks
+// the batch FAILED and is retried by asynq, the batch goes back to PROCESSING on
+// the next attempt.
+func (c *PayoutUseCase) DisbursePayout(ctx context.Context, t *asynq.Task) error {
+	var payload model.PayoutTask
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "DisbursePayout", "")
+		return err
+	}
+
+	claimed, err := c.PayoutRepository.MarkProcessing(ctx, payload.BatchID)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed claim payout batch: %v", err), "DisbursePayout", payload.BatchID)
+		return err
+	}
+	if !claimed {
+		batch, err := c.PayoutRepository.GetBatch(ctx, payload.BatchID)
+		if err != nil {
+			return err
+		}
+		// only a batch left in PROCESSING by an interrupted attempt is picked up again
+		if batch == nil || batch.Status != repository.PayoutProcessing {
+			return nil
+		}
+	}
+
+	batch, err := c.PayoutRepository.PayBatch(ctx, payload.BatchID)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed pay out batch: %v", err), "DisbursePayout", payload.BatchID)
+		if markErr := c.PayoutRepository.MarkFailed(ctx, payload.BatchID, err.Error()); markErr != nil {
+			c.Log.WithContext(ctx).Error("payout-usecase", fmt.Sprintf("Failed mark payout batch failed: %v", markErr), "DisbursePayout", payload.BatchID)
+		}
+		return err
+	}
+	c.Log.WithContext(ctx).Info("payout-usecase", fmt.Sprintf("Paid out %.2f to driver %s", batch.NetAmount, batch.DriverID), "DisbursePayout", batch.BatchID)
+	return nil
+}
+
+// ListPayouts returns a page of payout batches. Drivers only see their own, the
+// admin listing filters on the driver when one is given.
+func (c *PayoutUseCase) ListPayouts(ctx context.Context, request *model.PayoutListRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payout-usecase", errObj.Message, "ListPayouts", utils.ConvertString(err))
+		return result
+	}
+
+	var filter entity.PayoutFilter
+	if request.DriverID != "" {
+		filter.DriverID = &request.DriverID
+	}
+	if request.Status != "" {
+		filter.Status = &request.Status
+	}
+	var err error
+	if filter.From, err = parseDate(request.From, 0); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid from date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+	if filter.To, err = parseDate(request.To, 1); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid to date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+
+	page := request.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	batches, total, err := c.PayoutRepository.FindBatches(ctx, filter)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get payouts: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payout-usecase", errObj.Message, "ListPayouts", utils.ConvertString(err))
+		return result
+	}
+	if batches == nil {
+		batches = []entity.PayoutBatch{}
+	}
+	result.Data = model.PayoutPage{
+		Batches: batches,
+		Meta: constants.MetaData{
+			Page:      page,
+			Count:     int64(len(batches)),
+			TotalPage: (total + limit - 1) / limit,
+			TotalData: total,
+		},
+	}
+	return result
+}
+
+// PayoutReport sums the payout batches per payout date for a date range, both
+// dates inclusive.
+func (c *PayoutUseCase) PayoutReport(ctx context.Context, request *model.PayoutReportRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payout-usecase", errObj.Message, "PayoutReport", utils.ConvertString(err))
+		return result
+	}
+	from, err := parseDate(request.From, 0)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid from date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+	to, err := parseDate(request.To, 1)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid to date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+	if !to.After(*from) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "from date must not be after to date"
+		result.Error = errObj
+		return result
+	}
+
+	rows, err := c.PayoutRepository.Report(ctx, *from, *to)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get payout report: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("payout-usecase", errObj.Message, "PayoutReport", utils.ConvertString(err))
+		return result
+	}
+
+	report := model.PayoutReportResponse{
+		From: request.From,
+		To:   request.To,
+		Days: []model.PayoutReportDay{},
+	}
+	days := make(map[string]int)
+	for _, row := range rows {
+		date := row.PayoutDate.Format("2006-01-02")
+		index, ok := days[date]
+		if !ok {
+			index = len(report.Days)
+			days[date] = index
+			report.Days = append(report.Days, model.PayoutReportDay{PayoutDate: date, ByStatus: make(map[string]int)})
+		}
+		day := &report.Days[index]
+		day.Batches += row.Batches
+		day.TripCount += row.TripCount
+		day.GrossAmount += row.GrossAmount
+		day.CommissionAmount += row.CommissionAmount
+		day.NetAmount += row.NetAmount
+		day.ByStatus[row.Status] += row.Batches
+
+		report.Batches += row.Batches
+		report.TripCount += row.TripCount
+		report.GrossAmount += row.GrossAmount
+		report.CommissionAmount += row.CommissionAmount
+		report.NetAmount += row.NetAmount
+		if row.Status == repository.PayoutPaid {
+			report.PaidAmount += row.NetAmount
+		}
+	}
+
+	result.Data = report
+	return result
+}
+
+func (c *PayoutUseCase) enqueueDisburse(ctx context.Context, batchID string) error {
+	payload, err := json.Marshal(&model.PayoutTask{BatchID: batchID})
+	if err != nil {
+		return err
+	}
+	maxRetry := c.Config.GetInt("payout.max_retry")
+	if maxRetry <= 0 {
+		maxRetry = 5
+	}
+	task := asynq.NewTask(TypePayoutDisburse, tracing.InjectPayload(ctx, payload), asynq.MaxRetry(maxRetry), asynq.TaskID(batchID))
+	if _, err := c.AsynqClient.Enqueue(task); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return nil
+}
+
+// payoutLocation is the timezone the payout days are cut in.
+func (c *PayoutUseCase) payoutLocation() *time.Location {
+	name := c.Config.GetString("payout.timezone")
+	if name == "" {
+		name = "Asia/Jakarta"
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		c.Log.Error("payout-usecase", fmt.Sprintf("Unknown payout timezone %q: %v", name, err), "payoutLocation", "")
+		return time.Local
+	}
+	return location
+}
@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+type QueueUseCase struct {
+	Log              log.Log
+	Validate         *validator.Validate
+	DriverRepository *repository.DriverRepository
+	Config           *viper.Viper
+	Redis            redis.UniversalClient
+}
+
+func NewQueueUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	driverRepository *repository.DriverRepository,
+	cfg *viper.Viper,
+	redisClient redis.UniversalClient,
+) *QueueUseCase {
+	return &QueueUseCase{
+		Log:              logger,
+		Validate:         validate,
+		DriverRepository: driverRepository,
+		Config:           cfg,
+		Redis:            redisClient,
+	}
+}
+
+const (
+	TypeQueueSweep = "order:queue-sweep"
+)
+
+// SweepQueues keeps the FIFO queue of every queue zone in line with the driver
+// locations. Available drivers inside a zone join the tail of its queue, drivers
+// who left the zone or went offline are removed once dispatch.queue.exit_grace has
+// passed so GPS jitter at the boundary does not cost them their place.
+func (c *QueueUseCase) SweepQueues(ctx context.Context, t *asynq.Task) error {
+	grace := c.Config.GetDuration("dispatch.queue.exit_grace")
+	if grace <= 0 {
+		grace = 2 * time.Minute
+	}
+	for _, zone := range loadQueueZones(c.Config) {
+		if err := c.sweepZone(ctx, zone, grace); err != nil {
+			c.Log.WithContext(ctx).Error("queue-usecase", fmt.Sprintf("Failed sweep queue zone: %v", err), "SweepQueues", zone.ID)
+		}
+	}
+	return nil
+}
+
+func (c *QueueUseCase) sweepZone(ctx context.Context, zone model.DispatchZone, grace time.Duration) error {
+	queueKey := fmt.Sprintf("QUEUE:ZONE:%s", zone.ID)
+	leftKey := fmt.Sprintf("QUEUE:ZONE-LEFT:%s", zone.ID)
+
+	locations, err := c.Redis.GeoRadius(ctx, "drivers-locations", zone.Longitude, zone.Latitude, &redis.GeoRadiusQuery{
+		Radius: zone.RadiusKm,
+		Unit:   "km",
+	}).Result()
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(locations))
+	for _, location := range locations {
+		ids = append(ids, location.Name)
+	}
+	available, err := c.DriverRepository.FindAvailableDrivers(ctx, ids)
+	if err != nil {
+		return err
+	}
+	inside := make(map[string]bool, len(available))
+	for _, driver := range available {
+		inside[driver.DriverID] = true
+	}
+
+	members, err := c.Redis.ZRange(ctx, queueKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	leftAt, err := c.Redis.HGetAll(ctx, leftKey).Result()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pipe := c.Redis.TxPipeline()
+	// drivers are queued in the order the sweep first saw them, drivers entering in
+	// the same run share a score and Redis keeps them ordered by id
+	for _, id := range ids {
+		if !inside[id] {
+			continue
+		}
+		pipe.ZAddNX(ctx, queueKey, redis.Z{Score: float64(now.UnixMilli()), Member: id})
+		pipe.HDel(ctx, leftKey, id)
+	}
+	for _, member := range members {
+		if inside[member] {
+			continue
+		}
+		since, err := strconv.ParseInt(leftAt[member], 10, 64)
+		if err != nil {
+			pipe.HSet(ctx, leftKey, member, now.UnixMilli())
+			continue
+		}
+		if now.Sub(time.UnixMilli(since)) >= grace {
+			pipe.ZRem(ctx, queueKey, member)
+			pipe.HDel(ctx, leftKey, member)
+			c.Log.WithContext(ctx).Info("queue-usecase", fmt.Sprintf("Driver %s removed after leaving the zone", member), "sweepZone", zone.ID)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c *QueueUseCase) ListQueueZones(ctx context.Context) utils.Result {
+	var result utils.Result
+
+	zones := loadQueueZones(c.Config)
+	pipe := c.Redis.Pipeline()
+	sizes := make([]*redis.IntCmd, len(zones))
+	for i, zone := range zones {
+		sizes[i] = pipe.ZCard(ctx, fmt.Sprintf("QUEUE:ZONE:%s", zone.ID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error read from redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "ListQueueZones", utils.ConvertString(err))
+		return result
+	}
+
+	queues := make([]model.QueueResponse, 0, len(zones))
+	for i, zone := range zones {
+		queues = append(queues, model.QueueResponse{Zone: zone, Size: int(sizes[i].Val())})
+	}
+	result.Data = queues
+	return result
+}
+
+func (c *QueueUseCase) GetQueue(ctx context.Context, request *model.QueueRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "GetQueue", utils.ConvertString(err))
+		return result
+	}
+	zone, ok := findQueueZone(c.Config, request.ZoneID)
+	if !ok {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Queue zone not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "GetQueue", request.ZoneID)
+		return result
+	}
+
+	members, err := c.Redis.ZRangeWithScores(ctx, fmt.Sprintf("QUEUE:ZONE:%s", zone.ID), 0, -1).Result()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error read from redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "GetQueue", utils.ConvertString(err))
+		return result
+	}
+
+	entries := make([]model.QueueEntry, 0, len(members))
+	ids := make([]string, 0, len(members))
+	for i, member := range members {
+		driverID, _ := member.Member.(string)
+		ids = append(ids, driverID)
+		entries = append(entries, model.QueueEntry{
+			DriverID: driverID,
+			Position: i + 1,
+			JoinedAt: time.UnixMilli(int64(member.Score)),
+		})
+	}
+	if len(ids) > 0 {
+		positions, err := c.Redis.GeoPos(ctx, "drivers-locations", ids...).Result()
+		if err == nil {
+			for i, pos := range positions {
+				if pos != nil {
+					entries[i].Latitude = pos.Latitude
+					entries[i].Longitude = pos.Longitude
+				}
+			}
+		}
+	}
+
+	result.Data = model.QueueResponse{Zone: zone, Size: len(entries), Drivers: entries}
+	return result
+}
+
+func (c *QueueUseCase) RemoveFromQueue(ctx context.Context, request *model.QueueRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil || request.DriverID == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "validation error: zoneId and driverId are required"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "RemoveFromQueue", utils.ConvertString(request))
+		return result
+	}
+	if _, ok := findQueueZone(c.Config, request.ZoneID); !ok {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Queue zone not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "RemoveFromQueue", request.ZoneID)
+		return result
+	}
+
+	removed, err := c.Redis.ZRem(ctx, fmt.Sprintf("QUEUE:ZONE:%s", request.ZoneID), request.DriverID).Result()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Internal server error delete from redis: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("queue-usecase", errObj.Message, "RemoveFromQueue", utils.ConvertString(err))
+		return result
+	}
+	if removed == 0 {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Driver is not in this queue"
+		result.Error = errObj
+		return result
+	}
+	_ = c.Redis.HDel(ctx, fmt.Sprintf("QUEUE:ZONE-LEFT:%s", request.ZoneID), request.DriverID).Err()
+
+	result.Data = map[string]interface{}{
+		"zone_id":   request.ZoneID,
+		"driver_id": request.DriverID,
+		"message":   "Driver removed from queue",
+	}
+	return result
+}
+
+func loadQueueZones(cfg *viper.Viper) []model.DispatchZone {
+	var zones []model.DispatchZone
+	if err := cfg.UnmarshalKey("dispatch.queue.zones", &zones); err != nil {
+		log.GetLogger().Error("queue-usecase", fmt.Sprintf("Invalid dispatch.queue.zones: %v", err), "loadQueueZones", "")
+		return nil
+	}
+	return zones
+}
+
+func findQueueZone(cfg *viper.Viper, zoneID string) (model.DispatchZone, bool) {
+	for _, zone := range loadQueueZones(cfg) {
+		if zone.ID == zoneID {
+			return zone, true
+		}
+	}
+	return model.DispatchZone{}, false
+}
+
+// queueZoneAt returns the queue zone a pickup point falls in.
+func queueZoneAt(cfg *viper.Viper, lat, lng float64) (model.DispatchZone, bool) {
+	for _, zone := range loadQueueZones(cfg) {
+		if zone.Contains(lat, lng) {
+			return zone, true
+		}
+	}
+	return model.DispatchZone{}, false
+}
+
+// queuedDrivers returns the drivers waiting in the zone queue, head first.
+func queuedDrivers(ctx context.Context, rdb redis.UniversalClient, zoneID string) ([]string, error) {
+	return rdb.ZRange(ctx, fmt.Sprintf("QUEUE:ZONE:%s", zoneID), 0, -1).Result()
+}
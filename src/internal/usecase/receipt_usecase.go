@@ -0,0 +1,345 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/messaging"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/pdf"
+	"order-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
+
+type ReceiptUseCase struct {
+	Log             log.Log
+	Validate        *validator.Validate
+	OrderRepository *repository.OrderRepository
+	Config          *viper.Viper
+}
+
+func NewReceiptUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	orderRepository *repository.OrderRepository,
+	cfg *viper.Viper,
+) *ReceiptUseCase {
+	return &ReceiptUseCase{
+		Log:             logger,
+		Validate:        validate,
+		OrderRepository: orderRepository,
+		Config:          cfg,
+	}
+}
+
+// GetReceipt renders the e-receipt of a completed trip for its passenger.
+func (c *ReceiptUseCase) GetReceipt(ctx context.Context, request *model.ReceiptRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("receipt-usecase", errObj.Message, "GetReceipt", utils.ConvertString(err))
+		return result
+	}
+
+	order, err := c.OrderRepository.OrderDetail(ctx, request.OrderID)
+	if err != nil || order == nil || order.PassengerID != request.UserID {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "Order not found"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("receipt-usecase", errObj.Message, "GetReceipt", utils.ConvertString(err))
+		return result
+	}
+	if order.Status != "COMPLETED" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "The receipt is issued once the trip is completed"
+		result.Error = errObj
+		return result
+	}
+
+	receipt := buildReceipt(order)
+	document := model.ReceiptDocument{FileName: fmt.Sprintf("%s.%s", receipt.ReceiptNumber, model.ReceiptFormatHTML), ContentType: "text/html; charset=utf-8"}
+	if strings.EqualFold(request.Format, model.ReceiptFormatPDF) {
+		document.FileName = fmt.Sprintf("%s.%s", receipt.ReceiptNumber, model.ReceiptFormatPDF)
+		document.ContentType = "application/pdf"
+		document.Content = renderReceiptPDF(receipt)
+	} else {
+		document.Content, err = renderReceiptHTML(receipt)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "Failed render receipt"
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("receipt-usecase", fmt.Sprintf("Failed render receipt: %v", err), "GetReceipt", order.OrderID)
+			return result
+		}
+	}
+
+	result.Data = document
+	return result
+}
+
+// publishReceiptReady tells the notification service the receipt of a completed
+// and settled trip can be sent to the passenger.
+func publishReceiptReady(ctx context.Context, orderRepository *repository.OrderRepository, cfg *viper.Viper, producer *messaging.ReceiptProducer, orderID string) error {
+	if producer == nil {
+		return nil
+	}
+	order, err := orderRepository.OrderDetail(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order == nil || order.Status != "COMPLETED" {
+		return nil
+	}
+	receipt := buildReceipt(order)
+	url := fmt.Sprintf("%s/order/v1/%s/receipt", strings.TrimRight(cfg.GetString("receipt.base_url"), "/"), order.OrderID)
+	return producer.SendReceiptReady(ctx, &model.ReceiptReadyEvent{
+		EventID:       fmt.Sprintf("RECEIPT-READY-%s", order.OrderID),
+		ReceiptNumber: receipt.ReceiptNumber,
+		OrderID:       order.OrderID,
+		PassengerID:   order.PassengerID,
+		CompanyID:     receipt.CompanyID,
+		Total:         receipt.Total,
+		Currency:      receipt.Currency,
+		PaymentMethod: receipt.PaymentMethod,
+		HTMLURL:       fmt.Sprintf("%s?format=%s", url, model.ReceiptFormatHTML),
+		PDFURL:        fmt.Sprintf("%s?format=%s", url, model.ReceiptFormatPDF),
+		Timestamp:     time.Now(),
+	})
+}
+
+// buildReceipt lays out the fare of a completed order. The final fare already has
+// the shared ride discount taken off. The total is what the passenger was charged,
+// the wallet capture or the paid QRIS amount and the final fare otherwise, so it
+// matches the payment it is reimbursed against. A promo is only listed when the
+// charge reflects it.
+func buildReceipt(order *entity.OrderDetail) *model.Receipt {
+	fare := order.BestRoutePrice
+	if order.FinalFare != nil {
+		fare = *order.FinalFare
+	}
+	receipt := &model.Receipt{
+		ReceiptNumber:      fmt.Sprintf("RCP-%s", order.OrderID),
+		OrderID:            order.OrderID,
+		PassengerID:        order.PassengerID,
+		OriginAddress:      order.OriginAddress,
+		DestinationAddress: order.DestinationAddress,
+		DistanceKm:         order.BestRouteKm,
+		Duration:           order.BestRouteDuration,
+		RideType:           order.RideType,
+		Currency:           "IDR",
+		PaymentMethod:      order.PaymentMethod,
+		PaymentStatus:      order.PaymentStatus,
+		CompletedAt:        order.UpdatedAt,
+		IssuedAt:           time.Now(),
+	}
+	if order.DriverID != nil {
+		receipt.DriverID = *order.DriverID
+	}
+	if order.DistanceActual != nil {
+		receipt.DistanceKm = *order.DistanceActual
+	}
+	if order.DurationActual != nil {
+		receipt.Duration = *order.DurationActual
+	}
+	if order.CompletedAt != nil {
+		receipt.CompletedAt = *order.CompletedAt
+	}
+
+	if order.SharedDiscount != nil && *order.SharedDiscount > 0 {
+		receipt.Lines = append(receipt.Lines,
+			model.ReceiptLine{Label: "Trip fare", Amount: fare + *order.SharedDiscount},
+			model.ReceiptLine{Label: "Shared ride discount", Amount: -*order.SharedDiscount},
+		)
+	} else {
+		receipt.Lines = append(receipt.Lines, model.ReceiptLine{Label: "Trip fare", Amount: fare})
+	}
+	total := fare
+	if charged, ok := chargedAmount(order); ok {
+		discount := 0.0
+		if order.PromoDetail.Discount != nil {
+			discount = *order.PromoDetail.Discount
+		}
+		if discount > 0 && math.Abs(fare-discount-charged) < 0.005 {
+			label := "Promo discount"
+			if order.PromoDetail.PromoCode != nil {
+				label = fmt.Sprintf("Promo %s", *order.PromoDetail.PromoCode)
+			}
+			receipt.Lines = append(receipt.Lines, model.ReceiptLine{Label: label, Amount: -discount})
+		} else if math.Abs(charged-fare) >= 0.005 {
+			// the hold capped the capture or the QR was issued for another amount
+			receipt.Lines = append(receipt.Lines, model.ReceiptLine{Label: "Fare adjustment", Amount: charged - fare})
+		}
+		total = charged
+	}
+	receipt.Total = total
+
+	switch order.PaymentMethod {
+	case PaymentMethodQris:
+		if order.PaymentDetail.ReferenceID != nil {
+			receipt.PaymentReference = *order.PaymentDetail.ReferenceID
+		}
+	case PaymentMethodEWallet:
+		if order.WalletTransactionID != nil {
+			receipt.PaymentReference = *order.WalletTransactionID
+		}
+	case PaymentMethodCorporate:
+		if order.CompanyID != nil {
+			receipt.CompanyID = *order.CompanyID
+			receipt.PaymentReference = *order.CompanyID
+		}
+		if order.CostCenter != nil {
+			receipt.CostCenter = *order.CostCenter
+		}
+	}
+	return receipt
+}
+
+// chargedAmount returns what was collected from the passenger for a wallet or
+// QRIS order, false while nothing was collected.
+func chargedAmount(order *entity.OrderDetail) (float64, bool) {
+	switch order.PaymentMethod {
+	case PaymentMethodEWallet:
+		if order.WalletCaptured != nil {
+			return *order.WalletCaptured, true
+		}
+	case PaymentMethodQris:
+		paid := order.PaymentDetail.Status != nil && *order.PaymentDetail.Status == repository.PaymentPaid
+		if paid && order.PaymentDetail.Amount != nil {
+			return *order.PaymentDetail.Amount, true
+		}
+	}
+	return 0, false
+}
+
+func formatRupiah(amount float64) string {
+	negative := amount < 0
+	if negative {
+		amount = -amount
+	}
+	digits := fmt.Sprintf("%.0f", amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	if negative {
+		return "-Rp " + b.String()
+	}
+	return "Rp " + b.String()
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"rupiah": formatRupiah,
+	"date":   func(t time.Time) string { return t.Format("02 Jan 2006 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.ReceiptNumber}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 560px; margin: 24px auto; }
+h1 { font-size: 20px; margin-bottom: 4px; }
+.muted { color: #777; font-size: 12px; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+td { padding: 6px 0; border-bottom: 1px solid #eee; }
+td.amount { text-align: right; }
+tr.total td { font-weight: bold; border-bottom: none; }
+</style>
+</head>
+<body>
+<h1>Nebengjek e-receipt</h1>
+<div class="muted">{{.ReceiptNumber}} &middot; issued {{date .IssuedAt}}</div>
+<table>
+<tr><td>Order</td><td class="amount">{{.OrderID}}</td></tr>
+<tr><td>Completed</td><td class="amount">{{date .CompletedAt}}</td></tr>
+<tr><td>From</td><td class="amount">{{.OriginAddress}}</td></tr>
+<tr><td>To</td><td class="amount">{{.DestinationAddress}}</td></tr>
+<tr><td>Distance</td><td class="amount">{{printf "%.2f" .DistanceKm}} km</td></tr>
+<tr><td>Duration</td><td class="amount">{{.Duration}}</td></tr>
+</table>
+<table>
+{{range .Lines}}<tr><td>{{.Label}}</td><td class="amount">{{rupiah .Amount}}</td></tr>
+{{end}}<tr class="total"><td>Total</td><td class="amount">{{rupiah .Total}}</td></tr>
+</table>
+<table>
+<tr><td>Payment method</td><td class="amount">{{.PaymentMethod}}</td></tr>
+<tr><td>Payment status</td><td class="amount">{{.PaymentStatus}}</td></tr>
+{{if .PaymentReference}}<tr><td>Reference</td><td class="amount">{{.PaymentReference}}</td></tr>
+{{end}}{{if .CostCenter}}<tr><td>Cost center</td><td class="amount">{{.CostCenter}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func renderReceiptHTML(receipt *model.Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := receiptTemplate.Execute(&buf, receipt); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderReceiptPDF(receipt *model.Receipt) []byte {
+	const left, right = 56.0, pdf.PageWidth - 56.0
+	doc := pdf.New()
+	y := 72.0
+	row := func(label, value string, bold bool) {
+		// long addresses are cut to stay clear of the label column
+		for runes := []rune(value); pdf.TextWidth(value, 10, bold) > right-left-120 && len(runes) > 4; {
+			runes = runes[:len(runes)-4]
+			value = string(runes) + "..."
+		}
+		doc.Text(left, y, 10, bold, label)
+		doc.TextRight(right, y, 10, bold, value)
+		y += 18
+	}
+	rule := func() {
+		y -= 8
+		doc.Line(left, y, right, y)
+		y += 18
+	}
+
+	doc.Text(left, y, 18, true, "Nebengjek e-receipt")
+	y += 18
+	doc.Text(left, y, 9, false, fmt.Sprintf("%s - issued %s", receipt.ReceiptNumber, receipt.IssuedAt.Format("02 Jan 2006 15:04")))
+	y += 30
+
+	row("Order", receipt.OrderID, false)
+	row("Completed", receipt.CompletedAt.Format("02 Jan 2006 15:04"), false)
+	row("From", receipt.OriginAddress, false)
+	row("To", receipt.DestinationAddress, false)
+	row("Distance", fmt.Sprintf("%.2f km", receipt.DistanceKm), false)
+	row("Duration", receipt.Duration, false)
+	rule()
+
+	for _, line := range receipt.Lines {
+		row(line.Label, formatRupiah(line.Amount), false)
+	}
+	row("Total", formatRupiah(receipt.Total), true)
+	rule()
+
+	row("Payment method", receipt.PaymentMethod, false)
+	row("Payment status", receipt.PaymentStatus, false)
+	if receipt.PaymentReference != "" {
+		row("Reference", receipt.PaymentReference, false)
+	}
+	if receipt.CostCenter != "" {
+		row("Cost center", receipt.CostCenter, false)
+	}
+	return doc.Bytes()
+}
@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+)
+
+type RefundUseCase struct {
+	Log              log.Log
+	RefundRepository *repository.RefundRepository
+	Config           *viper.Viper
+	PaymentMethods   *PaymentMethods
+	AsynqClient      *asynq.Client
+}
+
+func NewRefundUseCase(
+	logger log.Log,
+	refundRepository *repository.RefundRepository,
+	cfg *viper.Viper,
+	paymentMethods *PaymentMethods,
+	asynqClient *asynq.Client,
+) *RefundUseCase {
+	return &RefundUseCase{
+		Log:              logger,
+		RefundRepository: refundRepository,
+		Config:           cfg,
+		PaymentMethods:   paymentMethods,
+		AsynqClient:      asynqClient,
+	}
+}
+
+const (
+	TypeProcessRefund = "refund:process"
+	TypeRefundSweep   = "refund:sweep"
+)
+
+// ProcessRefund settles a recorded refund. Every step is idempotent, a failed
+// attempt returns the error so asynq retries it with backoff.
+func (c *RefundUseCase) ProcessRefund(ctx context.Context, t *asynq.Task) error {
+	var payload model.ProcessRefundTask
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "ProcessRefund", "")
+		return err
+	}
+
+	refund, err := c.RefundRepository.GetRefund(ctx, payload.RefundID)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed get refund: %v", err), "ProcessRefund", payload.RefundID)
+		return err
+	}
+	if refund == nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", "Refund not found, skipping", "ProcessRefund", payload.RefundID)
+		return nil
+	}
+	if refund.Status == repository.RefundSucceeded {
+		return nil
+	}
+
+	method, ok := c.PaymentMethods.Lookup(refund.PaymentMethod)
+	if !ok {
+		c.Log.WithContext(ctx).Error("refund-usecase", "Unknown payment method, skipping", "ProcessRefund", refund.PaymentMethod)
+		// marked failed so the sweep leaves it to reconciliation
+		if err := c.RefundRepository.MarkFailed(ctx, refund.RefundID, "unknown payment method "+refund.PaymentMethod); err != nil {
+			c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed mark refund failed: %v", err), "ProcessRefund", refund.RefundID)
+		}
+		return nil
+	}
+	outcome, err := method.Refund(ctx, refund)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Refund attempt failed: %v", err), "ProcessRefund", refund.RefundID)
+		if errMark := c.RefundRepository.MarkFailed(ctx, refund.RefundID, err.Error()); errMark != nil {
+			c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed mark refund failed: %v", errMark), "ProcessRefund", refund.RefundID)
+		}
+		return err
+	}
+
+	if err := c.RefundRepository.MarkSucceeded(ctx, refund.RefundID, outcome.Amount, outcome.PaymentTransactionID, outcome.ProviderReference); err != nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed mark refund succeeded: %v", err), "ProcessRefund", refund.RefundID)
+		return err
+	}
+	c.Log.WithContext(ctx).Info("refund-usecase", fmt.Sprintf("Refunded %.2f with %.2f cancellation fee", outcome.Amount, refund.FeeAmount), "ProcessRefund", refund.OrderID)
+	return nil
+}
+
+// SweepRefunds is the periodic job handing the refunds no task attempted yet to
+// the refund task, such as a refund whose task was not enqueued after the
+// cancellation committed.
+func (c *RefundUseCase) SweepRefunds(ctx context.Context, t *asynq.Task) error {
+	after := c.Config.GetDuration("refund.sweep_after")
+	if after <= 0 {
+		after = time.Minute
+	}
+	refunds, err := c.RefundRepository.FindPendingRefunds(ctx, time.Now().Add(-after), 100)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed find pending refunds: %v", err), "SweepRefunds", "")
+		return err
+	}
+	for _, refund := range refunds {
+		if err := enqueueRefund(ctx, c.AsynqClient, c.Config, refund.RefundID); err != nil {
+			c.Log.WithContext(ctx).Error("refund-usecase", fmt.Sprintf("Failed enqueue refund task: %v", err), "SweepRefunds", refund.RefundID)
+		}
+	}
+	return nil
+}
+
+// cancellationFee applies the cancellation policy. Orders still matching cancel
+// for free, an accepted order is free within cancellation.free_window of the
+// driver accepting it and costs cancellation.fee afterwards.
+func cancellationFee(cfg *viper.Viper, order *entity.Order, now time.Time) (string, float64) {
+	switch order.Status {
+	case "ACCEPTED":
+		window := cfg.GetDuration("cancellation.free_window")
+		if window <= 0 {
+			window = 2 * time.Minute
+		}
+		// the order row is last updated when the driver is assigned
+		if now.Sub(order.UpdatedAt) <= window {
+			return "ACCEPTED", 0
+		}
+		fee := cfg.GetFloat64("cancellation.fee")
+		if fee <= 0 {
+			fee = 5000
+		}
+		if order.MaxPrice > 0 {
+			fee = math.Min(fee, order.MaxPrice)
+		}
+		return "ACCEPTED", fee
+	default:
+		return "MATCHING", 0
+	}
+}
+
+// enqueueRefund hands a recorded refund to the refund task with retries, a task
+// already queued for the refund is kept.
+func enqueueRefund(ctx context.Context, client *asynq.Client, cfg *viper.Viper, refundID string) error {
+	payload, err := json.Marshal(&model.ProcessRefundTask{RefundID: refundID})
+	if err != nil {
+		return err
+	}
+	maxRetry := cfg.GetInt("refund.max_retry")
+	if maxRetry <= 0 {
+		maxRetry = 10
+	}
+	task := asynq.NewTask(TypeProcessRefund, tracing.InjectPayload(ctx, payload), asynq.MaxRetry(maxRetry), asynq.TaskID(refundID))
+	if _, err := client.Enqueue(task); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return nil
+}
+
+// recordCancellationRefund records the refund of a cancelled prepaid order in the
+// transaction carried by ctx, the caller enqueues it once committed. It returns
+// nil for cash orders where no money moved.
+func (c *UserUseCase) recordCancellationRefund(ctx context.Context, order *entity.Order) (*entity.Refund, error) {
+	method, ok := c.PaymentMethods.Lookup(order.PaymentMethod)
+	if !ok || !method.Prepaid() {
+		return nil, nil
+	}
+	stage, fee := cancellationFee(c.Config, order, time.Now())
+	return c.RefundRepository.InsertRefund(ctx, &entity.Refund{
+		RefundID:      fmt.Sprintf("REFUND-%s", order.OrderID),
+		OrderID:       order.OrderID,
+		PassengerID:   order.PassengerID,
+		PaymentMethod: method.Code(),
+		Stage:         stage,
+		Reason:        "PASSENGER_CANCELLED",
+		FeeAmount:     fee,
+	})
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/utils"
+)
+
+type sharedStop struct {
+	Latitude  float64
+	Longitude float64
+	Pickup    bool
+	OrderID   string
+}
+
+// applySharedDiscount lowers the planned prices of a pooled trip by
+// shared.discount_percent and returns the fare split of the passenger.
+func applySharedDiscount(tripPlan *model.RouteSummary, discountPercent float64) *entity.OrderFareSplit {
+	if discountPercent <= 0 || discountPercent >= 100 {
+		discountPercent = 20
+	}
+	factor := 1 - discountPercent/100
+	baseFare := tripPlan.BestRoutePrice
+	tripPlan.MinPrice = tripPlan.MinPrice * factor
+	tripPlan.MaxPrice = tripPlan.MaxPrice * factor
+	tripPlan.BestRoutePrice = tripPlan.BestRoutePrice * factor
+	return &entity.OrderFareSplit{
+		BaseFare:       baseFare,
+		DiscountAmount: baseFare - tripPlan.BestRoutePrice,
+		Fare:           tripPlan.BestRoutePrice,
+	}
+}
+
+// findSharedGroup picks the open group the new shared order fits with the least
+// detour, nil when none is within the detour threshold. The seat is taken when
+// the order is written.
+func (c *UserUseCase) findSharedGroup(ctx context.Context, order *entity.Order) *entity.SharedRideGroup {
+	groups, err := c.SharedRideRepository.FindOpenGroups(ctx)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed query shared groups: %v", err), "findSharedGroup", order.OrderID)
+		return nil
+	}
+
+	maxDetourKm := c.Config.GetFloat64("shared.max_detour_km")
+	if maxDetourKm <= 0 {
+		maxDetourKm = 2
+	}
+	var best *entity.SharedRideGroup
+	bestDetour := math.Inf(1)
+	for i := range groups {
+		detour, ok := c.sharedDetour(ctx, &groups[i], order)
+		if !ok || detour > maxDetourKm || detour >= bestDetour {
+			continue
+		}
+		best = &groups[i]
+		bestDetour = detour
+	}
+	if best != nil {
+		c.Log.WithContext(ctx).Info("user-usecase", fmt.Sprintf("Shared group %s fits with %.2f km detour", best.GroupID, bestDetour), "findSharedGroup", order.OrderID)
+	}
+	return best
+}
+
+// sharedDetour returns the extra distance the group driver travels to serve the
+// new order, using a cheapest insertion of its pickup and drop into the remaining
+// stops of the group.
+func (c *UserUseCase) sharedDetour(ctx context.Context, group *entity.SharedRideGroup, order *entity.Order) (float64, bool) {
+	members, err := c.OrderRepository.FindOrders(ctx, entity.OrderFilter{
+		SharedGroupID: &group.GroupID,
+		StatusIn:      []string{"ACCEPTED", "ON_GOING"},
+	})
+	if err != nil || len(members) == 0 {
+		return 0, false
+	}
+	positions, err := c.Redis.GeoPos(ctx, "drivers-locations", group.DriverID).Result()
+	if err != nil || len(positions) == 0 || positions[0] == nil {
+		return 0, false
+	}
+	start := sharedStop{Latitude: positions[0].Latitude, Longitude: positions[0].Longitude}
+
+	var pending []sharedStop
+	for _, member := range members {
+		if member.Status == "ACCEPTED" {
+			pending = append(pending, sharedStop{Latitude: member.OriginLat, Longitude: member.OriginLng, Pickup: true, OrderID: member.OrderID})
+		}
+		pending = append(pending, sharedStop{Latitude: member.DestinationLat, Longitude: member.DestinationLng, OrderID: member.OrderID})
+	}
+	route := nearestStopOrder(start, pending)
+	baseline := routeLength(start, route)
+
+	pickup := sharedStop{Latitude: order.OriginLat, Longitude: order.OriginLng, Pickup: true, OrderID: order.OrderID}
+	drop := sharedStop{Latitude: order.DestinationLat, Longitude: order.DestinationLng, OrderID: order.OrderID}
+	best := math.Inf(1)
+	for i := 0; i <= len(route); i++ {
+		for j := i; j <= len(route); j++ {
+			candidate := make([]sharedStop, 0, len(route)+2)
+			candidate = append(candidate, route[:i]...)
+			candidate = append(candidate, pickup)
+			candidate = append(candidate, route[i:j]...)
+			candidate = append(candidate, drop)
+			candidate = append(candidate, route[j:]...)
+			if length := routeLength(start, candidate); length < best {
+				best = length
+			}
+		}
+	}
+	return best - baseline, true
+}
+
+// nearestStopOrder visits the closest reachable stop first, a drop only becomes
+// reachable after the pickup of the same order.
+func nearestStopOrder(start sharedStop, stops []sharedStop) []sharedStop {
+	picked := make(map[string]bool)
+	for _, stop := range stops {
+		if stop.Pickup {
+			picked[stop.OrderID] = false
+		}
+	}
+	remaining := append([]sharedStop(nil), stops...)
+	route := make([]sharedStop, 0, len(stops))
+	current := start
+	for len(remaining) > 0 {
+		next := -1
+		nextDistance := math.Inf(1)
+		for i, stop := range remaining {
+			if pickedUp, ok := picked[stop.OrderID]; !stop.Pickup && ok && !pickedUp {
+				continue
+			}
+			distance := utils.HaversineKm(current.Latitude, current.Longitude, stop.Latitude, stop.Longitude)
+			if distance < nextDistance {
+				next = i
+				nextDistance = distance
+			}
+		}
+		stop := remaining[next]
+		if stop.Pickup {
+			picked[stop.OrderID] = true
+		}
+		route = append(route, stop)
+		current = stop
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return route
+}
+
+func routeLength(start sharedStop, stops []sharedStop) float64 {
+	total := 0.0
+	current := start
+	for _, stop := range stops {
+		total += utils.HaversineKm(current.Latitude, current.Longitude, stop.Latitude, stop.Longitude)
+		current = stop
+	}
+	return total
+}
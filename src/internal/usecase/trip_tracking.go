@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/internal/model"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// ErrInvalidTripLocation is a location event no retry can apply.
+var ErrInvalidTripLocation = errors.New("invalid trip location")
+
+const tripTrackTTL = 24 * time.Hour
+
+// The track and path of a trip share a hash tag so they live in the same slot
+// of a cluster and can be written in one transaction.
+func tripTrackKey(orderID string) string {
+	return fmt.Sprintf("TRIP:{%s}:TRACK", orderID)
+}
+
+func tripPathKey(orderID string) string {
+	return fmt.Sprintf("TRIP:{%s}:PATH", orderID)
+}
+
+// tripTrack is the distance driven so far on a trip, accumulated from the
+// location events of its driver.
+type tripTrack struct {
+	DriverID   string
+	DistanceKm float64
+	Points     int
+	LastLat    float64
+	LastLng    float64
+	LastAt     time.Time
+	StartedAt  time.Time
+}
+
+func parseTripTrack(values map[string]string) *tripTrack {
+	if len(values) == 0 {
+		return nil
+	}
+	track := &tripTrack{DriverID: values["driver_id"]}
+	track.DistanceKm, _ = strconv.ParseFloat(values["distance_km"], 64)
+	track.Points, _ = strconv.Atoi(values["points"])
+	track.LastLat, _ = strconv.ParseFloat(values["last_lat"], 64)
+	track.LastLng, _ = strconv.ParseFloat(values["last_lng"], 64)
+	if ms, err := strconv.ParseInt(values["last_at"], 10, 64); err == nil {
+		track.LastAt = time.UnixMilli(ms)
+	}
+	if ms, err := strconv.ParseInt(values["started_at"], 10, 64); err == nil {
+		track.StartedAt = time.UnixMilli(ms)
+	}
+	return track
+}
+
+// startTripTrack opens the track of a trip once the passenger is picked up, the
+// locations of the driver before that are not part of the trip.
+func startTripTrack(ctx context.Context, rdb redis.UniversalClient, orderID, driverID string, startedAt time.Time) error {
+	key := tripTrackKey(orderID)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key, tripPathKey(orderID))
+		pipe.HSet(ctx, key,
+			"driver_id", driverID,
+			"distance_km", 0,
+			"points", 0,
+			"started_at", startedAt.UnixMilli(),
+		)
+		pipe.Expire(ctx, key, tripTrackTTL)
+		return nil
+	})
+	return err
+}
+
+// loadTripTrack returns the track of a trip with its path, nil when the trip was
+// never tracked.
+func loadTripTrack(ctx context.Context, rdb redis.UniversalClient, orderID string) (*tripTrack, []model.TripPoint, error) {
+	values, err := rdb.HGetAll(ctx, tripTrackKey(orderID)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	track := parseTripTrack(values)
+	if track == nil {
+		return nil, nil, nil
+	}
+	raw, err := rdb.LRange(ctx, tripPathKey(orderID), 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	path := make([]model.TripPoint, 0, len(raw))
+	for _, entry := range raw {
+		parts := strings.Split(entry, ",")
+		if len(parts) != 3 {
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(parts[0], 64)
+		lng, errLng := strconv.ParseFloat(parts[1], 64)
+		ms, errAt := strconv.ParseInt(parts[2], 10, 64)
+		if errLat != nil || errLng != nil || errAt != nil {
+			continue
+		}
+		path = append(path, model.TripPoint{Lat: lat, Lng: lng, RecordedAt: time.UnixMilli(ms)})
+	}
+	return track, path, nil
+}
+
+func clearTripTrack(ctx context.Context, rdb redis.UniversalClient, orderID string) error {
+	return rdb.Del(ctx, tripTrackKey(orderID), tripPathKey(orderID)).Err()
+}
+
+type TripTrackingUseCase struct {
+	Log    log.Log
+	Config *viper.Viper
+	Redis  redis.UniversalClient
+}
+
+func NewTripTrackingUseCase(logger log.Log, cfg *viper.Viper, redisClient redis.UniversalClient) *TripTrackingUseCase {
+	return &TripTrackingUseCase{
+		Log:    logger,
+		Config: cfg,
+		Redis:  redisClient,
+	}
+}
+
+// TrackLocation adds a location of the driver to the track of the trip. Fixes
+// for a trip that is not on going, redelivered or older than the last one, too
+// inaccurate, or implying an impossible speed are dropped.
+func (c *TripTrackingUseCase) TrackLocation(ctx context.Context, event *model.TripLocationEvent) error {
+	if event.OrderID == "" || event.DriverID == "" {
+		return fmt.Errorf("%w: missing order or driver id", ErrInvalidTripLocation)
+	}
+	if event.Lat < -90 || event.Lat > 90 || event.Lng < -180 || event.Lng > 180 || (event.Lat == 0 && event.Lng == 0) {
+		return fmt.Errorf("%w: coordinate %f,%f", ErrInvalidTripLocation, event.Lat, event.Lng)
+	}
+	maxAccuracy := c.Config.GetFloat64("tracking.max_accuracy_m")
+	if maxAccuracy <= 0 {
+		maxAccuracy = 50
+	}
+	if event.Accuracy > maxAccuracy {
+		return nil
+	}
+	maxSpeed := c.Config.GetFloat64("tracking.max_speed_kmh")
+	if maxSpeed <= 0 {
+		maxSpeed = 160
+	}
+	recordedAt := event.RecordedAt
+	if recordedAt.IsZero() {
+		recordedAt = time.Now()
+	}
+
+	key := tripTrackKey(event.OrderID)
+	update := func(tx *redis.Tx) error {
+		values, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		track := parseTripTrack(values)
+		if track == nil || track.DriverID != event.DriverID || !recordedAt.After(track.LastAt) {
+			return nil
+		}
+		distance := 0.0
+		if track.Points > 0 {
+			distance = utils.HaversineKm(track.LastLat, track.LastLng, event.Lat, event.Lng)
+			if hours := recordedAt.Sub(track.LastAt).Hours(); distance/hours > maxSpeed {
+				// a GPS jump, the next fix is measured from the last good one
+				return nil
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key,
+				"distance_km", strconv.FormatFloat(track.DistanceKm+distance, 'f', -1, 64),
+				"points", track.Points+1,
+				"last_lat", strconv.FormatFloat(event.Lat, 'f', -1, 64),
+				"last_lng", strconv.FormatFloat(event.Lng, 'f', -1, 64),
+				"last_at", recordedAt.UnixMilli(),
+			)
+			pipe.RPush(ctx, tripPathKey(event.OrderID), fmt.Sprintf("%s,%s,%d",
+				strconv.FormatFloat(event.Lat, 'f', -1, 64),
+				strconv.FormatFloat(event.Lng, 'f', -1, 64),
+				recordedAt.UnixMilli(),
+			))
+			pipe.Expire(ctx, key, tripTrackTTL)
+			pipe.Expire(ctx, tripPathKey(event.OrderID), tripTrackTTL)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = c.Redis.Watch(ctx, update, key); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		c.Log.WithContext(ctx).Error("tracking-usecase", fmt.Sprintf("Failed track location: %v", err), "TrackLocation", event.OrderID)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"order-service/src/internal/entity"
@@ -11,7 +12,9 @@ import (
 	"order-service/src/internal/model/converter"
 	httpError "order-service/src/pkg/http-error"
 	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
 	"order-service/src/pkg/utils"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,26 +30,34 @@ import (
 )
 
 type UserUseCase struct {
-	Log              log.Log
-	Validate         *validator.Validate
-	UserRepository   *repository.UserRepository
-	WalletRepository *repository.WalletRepository
-	OrderRepository  *repository.OrderRepository
-	DriverRepository *repository.DriverRepository
-	Config           *viper.Viper
-	Redis            redis.UniversalClient
-	UserProducer     *messaging.UserProducer
-	Geoservice       *maps.Client
-	AsynqClient      *asynq.Client
+	Log                  log.Log
+	Validate             *validator.Validate
+	UserRepository       *repository.UserRepository
+	PaymentMethods       *PaymentMethods
+	OrderRepository      *repository.OrderRepository
+	DriverRepository     *repository.DriverRepository
+	SharedRideRepository *repository.SharedRideRepository
+	RefundRepository     *repository.RefundRepository
+	OutboxRepository     *repository.OutboxRepository
+	OrderEvents          *OrderEvents
+	Config               *viper.Viper
+	Redis                redis.UniversalClient
+	UserProducer         *messaging.UserProducer
+	Geoservice           *maps.Client
+	AsynqClient          *asynq.Client
 }
 
 func NewUserUseCase(
 	logger log.Log,
 	validate *validator.Validate,
 	userRepository *repository.UserRepository,
-	walletRepository *repository.WalletRepository,
+	paymentMethods *PaymentMethods,
 	orderRepository *repository.OrderRepository,
 	driverRepository *repository.DriverRepository,
+	sharedRideRepository *repository.SharedRideRepository,
+	refundRepository *repository.RefundRepository,
+	outboxRepository *repository.OutboxRepository,
+	orderEvents *OrderEvents,
 	cfg *viper.Viper,
 	redisClient redis.UniversalClient,
 	userProducer *messaging.UserProducer,
@@ -54,22 +65,27 @@ func NewUserUseCase(
 	asynqClient *asynq.Client,
 ) *UserUseCase {
 	return &UserUseCase{
-		Log:              logger,
-		Validate:         validate,
-		UserRepository:   userRepository,
-		WalletRepository: walletRepository,
-		OrderRepository:  orderRepository,
-		DriverRepository: driverRepository,
-		Config:           cfg,
-		Redis:            redisClient,
-		UserProducer:     userProducer,
-		Geoservice:       geo,
-		AsynqClient:      asynqClient,
+		Log:                  logger,
+		Validate:             validate,
+		UserRepository:       userRepository,
+		PaymentMethods:       paymentMethods,
+		OrderRepository:      orderRepository,
+		DriverRepository:     driverRepository,
+		SharedRideRepository: sharedRideRepository,
+		RefundRepository:     refundRepository,
+		OutboxRepository:     outboxRepository,
+		OrderEvents:          orderEvents,
+		Config:               cfg,
+		Redis:                redisClient,
+		UserProducer:         userProducer,
+		Geoservice:           geo,
+		AsynqClient:          asynqClient,
 	}
 }
 
 const (
 	TypeBroadcastDriver    = "passanger:request-ride"
+	TypeAutoDispatch       = "order:auto-dispatch"
 	MatchingTimeoutMinutes = 15
 )
 
@@ -80,19 +96,19 @@ func (c *UserUseCase) GetUser(ctx context.Context, request *model.GetUserRequest
 		errObj := httpError.NewBadRequest()
 		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("GetUser-validation", err.Error(), "request", utils.ConvertString(request))
+		c.Log.WithContext(ctx).Error("GetUser-validation", err.Error(), "request", utils.ConvertString(request))
 		return result
 	}
 	user, err := c.UserRepository.FindByID(ctx, request.ID)
 	fmt.Println(err)
 	if err != nil {
-		c.Log.Error("GetUser-FindByID", err.Error(), "request", utils.ConvertString(request))
+		c.Log.WithContext(ctx).Error("GetUser-FindByID", err.Error(), "request", utils.ConvertString(request))
 		errObj := httpError.NewNotFound()
 		errObj.Message = fmt.Sprintf("user with id %s not found", request.ID)
 		result.Error = errObj
 		return result
 	}
-	c.Log.Info("GetUser", "user found", "userID", request.ID)
+	c.Log.WithContext(ctx).Info("GetUser", "user found", "userID", request.ID)
 	result.Data = converter.UserToResponse(user)
 	return result
 }
@@ -105,19 +121,21 @@ func (c *UserUseCase) PostLocation(ctx context.Context, request *model.LocationS
 		errObj := httpError.NewNotFound()
 		errObj.Message = fmt.Sprintf("error getRouteSuggestions: %v", err)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(err))
 		return result
 	}
 
 	key := fmt.Sprintf("USER:ROUTE:%s", request.UserID)
 	routeSuggestion.Route.Origin = request.CurrentLocation
+	routeSuggestion.Route.Origin.City = cityAt(c.Config, request.CurrentLocation.Latitude, request.CurrentLocation.Longitude)
 	routeSuggestion.Route.Destination = request.Destination
+	routeSuggestion.Route.Destination.City = cityAt(c.Config, request.Destination.Latitude, request.Destination.Longitude)
 	routeSummaryJSON, err := json.Marshal(routeSuggestion)
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Error marshalling RouteSummary: %v", err)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(err))
 		return result
 	}
 
@@ -126,7 +144,7 @@ func (c *UserUseCase) PostLocation(ctx context.Context, request *model.LocationS
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Error saving to redis: %v", redisErr)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(redisErr))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "PostLocation", utils.ConvertString(redisErr))
 		return result
 	}
 	result.Data = routeSuggestion
@@ -137,6 +155,14 @@ func (c *UserUseCase) PostLocation(ctx context.Context, request *model.LocationS
 func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverRequest) utils.Result {
 	var result utils.Result
 
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
+		return result
+	}
+
 	key := fmt.Sprintf("USER:ROUTE:%s", request.UserID)
 	var tripPlan model.RouteSummary
 	redisData, errRedis := c.Redis.Get(ctx, key).Result()
@@ -144,7 +170,7 @@ func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverR
 		errObj := httpError.NewNotFound()
 		errObj.Message = fmt.Sprintf("Error get data from redis: %v", errRedis)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(errRedis))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(errRedis))
 		return result
 	}
 	err := json.Unmarshal([]byte(redisData), &tripPlan)
@@ -152,63 +178,47 @@ func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverR
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Error unmarshal tripdata: %v", err)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
 		return result
 	}
 
-	switch request.PaymentMethod {
-	case "EWALLET":
-		walletCheck, err := c.WalletRepository.GetWalletByUserID(ctx, request.UserID)
-		if err != nil {
-			errObj := httpError.NewInternalServerError()
-			errObj.Message = fmt.Sprintf("Wallet not found: %v, Please create wallet first", err)
-			result.Error = errObj
-			c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
-			return result
-		}
-		if walletCheck.Balance < tripPlan.MaxPrice {
-			errObj := httpError.NewBadRequest()
-			errObj.Message = "insufficient balance, please topup"
-			result.Error = errObj
-			c.Log.Error("user-usecase", errObj.Message, "FindDriver", "")
-			return result
-		}
-	case "QRIS":
-		if tripPlan.MaxPrice < 1000 {
-			errObj := httpError.NewBadRequest()
-			errObj.Message = "minimum payment amount is 1,000"
-			result.Error = errObj
-			return result
-		}
+	rideType := model.RideTypePrivate
+	var fareSplit *entity.OrderFareSplit
+	if strings.EqualFold(request.RideType, model.RideTypeShared) {
+		rideType = model.RideTypeShared
+		fareSplit = applySharedDiscount(&tripPlan, c.Config.GetFloat64("shared.discount_percent"))
+	}
 
-		if tripPlan.MaxPrice > 10000000 {
-			errObj := httpError.NewBadRequest()
-			errObj.Message = "maximum payment amount exceeded (10,000,000)"
-			result.Error = errObj
-			return result
-		}
-	default:
+	paymentMethod, ok := c.PaymentMethods.Lookup(request.PaymentMethod)
+	if !ok {
 		errObj := httpError.NewBadRequest()
-		errObj.Message = "invalid payment method, only 'EWALLET' or 'CASH' allowed"
+		errObj.Message = fmt.Sprintf("invalid payment method, only %s allowed", strings.Join(c.PaymentMethods.Codes(), ", "))
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", request.PaymentMethod)
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "FindDriver", request.PaymentMethod)
 		return result
 	}
+	booking := &PaymentBooking{
+		UserID:     request.UserID,
+		Amount:     tripPlan.MaxPrice,
+		Route:      tripPlan.Route,
+		BookedAt:   time.Now(),
+		CostCenter: request.CostCenter,
+	}
+	if check := paymentMethod.CheckBooking(ctx, booking); check.Error != nil {
+		return check
+	}
+	if booking.CompanyID == "" {
+		// cost centers only exist on orders billed to a company
+		booking.CostCenter = ""
+	}
 
-	radius := 3.0
-	drivers, err := c.Redis.GeoRadius(ctx, "drivers-locations", tripPlan.Route.Origin.Longitude, tripPlan.Route.Origin.Latitude, &redis.GeoRadiusQuery{
-		Radius:    radius,
-		Unit:      "km",
-		WithDist:  true,
-		WithCoord: true,
-		Sort:      "ASC",
-	}).Result()
-
+	dispatchMode := c.resolveDispatchMode(tripPlan.Route.Origin, request.Segment)
+	drivers, err := c.findCandidateDrivers(ctx, tripPlan)
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Error searching drivers: %v", err)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
 		return result
 	}
 	posibleDriver := "No driver available. Don't worry, please try again later."
@@ -216,85 +226,42 @@ func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverR
 	if len(drivers) > 0 {
 		orderID = utils.GenerateUniqueIDWithPrefix("user")
 		payload := &model.RequestRide{
-			UserId:       request.UserID,
-			OrderTempID:  orderID,
-			RouteSummary: tripPlan,
-			Attempt:      1,
+			UserId:             request.UserID,
+			OrderTempID:        orderID,
+			RouteSummary:       tripPlan,
+			Attempt:            1,
+			CandidateDriverIDs: driverIDs(drivers),
 		}
 
 		statusNot := "COMPLETED"
 		orderData, errOrder := c.OrderRepository.FindOrders(ctx, entity.OrderFilter{PassengerID: &request.UserID, StatusNot: &statusNot, StatusIn: []string{"REQUESTED", "MATCHING", "ACCEPTED", "ON_GOING"}})
 		if errOrder != nil {
-			c.Log.Error("user-usecase", fmt.Sprintf("Failed query orders: %+v", errOrder), "FindDriver", "")
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed query orders: %+v", errOrder), "FindDriver", "")
 			errObj := httpError.NewInternalServerError()
 			errObj.Message = "Failed check existing order"
 			result.Error = errObj
 			return result
 		}
-		if len(orderData) == 0 {
-			tripOrder := &entity.CreateOrder{
-				OrderID:            orderID,
-				PassengerID:        request.UserID,
-				OriginLat:          tripPlan.Route.Origin.Latitude,
-				OriginLng:          tripPlan.Route.Origin.Longitude,
-				DestinationLat:     tripPlan.Route.Destination.Latitude,
-				DestinationLng:     tripPlan.Route.Destination.Longitude,
-				OriginAddress:      tripPlan.Route.Origin.Address,
-				DestinationAddress: tripPlan.Route.Destination.Address,
-				MinPrice:           tripPlan.MinPrice,
-				MaxPrice:           tripPlan.MaxPrice,
-				BestRouteKm:        tripPlan.BestRouteKm,
-				BestRoutePrice:     tripPlan.BestRoutePrice,
-				BestRouteDuration:  tripPlan.BestRouteDuration,
-				PaymentMethod:      request.PaymentMethod,
-			}
-			err := c.OrderRepository.InsertOrder(ctx, tripOrder)
-			if err != nil {
-				c.Log.Error("user-usecase", fmt.Sprintf("Failed insert order to db : %+v", err), "InsertOrder", "")
-				errObj := httpError.NewInternalServerError()
-				errObj.Message = "Failed create order"
-				result.Error = errObj
-				return result
-			}
-		} else {
+		var stale *entity.Order
+		if len(orderData) > 0 {
 			current := orderData[0]
 			switch current.Status {
 			case "REQUESTED", "MATCHING":
 				elapsed := time.Since(current.CreatedAt)
-				if elapsed > MatchingTimeoutMinutes*time.Minute {
-					updateReq := &entity.UpdateOrderRequest{
-						ID:                 current.ID,
-						OrderID:            orderID,
-						PassengerID:        request.UserID,
-						OriginLat:          tripPlan.Route.Origin.Latitude,
-						OriginLng:          tripPlan.Route.Origin.Longitude,
-						DestinationLat:     tripPlan.Route.Destination.Latitude,
-						DestinationLng:     tripPlan.Route.Destination.Longitude,
-						OriginAddress:      tripPlan.Route.Origin.Address,
-						DestinationAddress: tripPlan.Route.Destination.Address,
-						MinPrice:           tripPlan.MinPrice,
-						MaxPrice:           tripPlan.MaxPrice,
-						BestRouteKm:        tripPlan.BestRouteKm,
-						BestRoutePrice:     tripPlan.BestRoutePrice,
-						BestRouteDuration:  tripPlan.BestRouteDuration,
-						Status:             "REQUESTED",
-						PaymentMethod:      request.PaymentMethod,
-						PaymentStatus:      "UNPAID",
-						DriverID:           nil,
-					}
-					if err := c.OrderRepository.UpdateOrder(ctx, updateReq); err != nil {
-						c.Log.Error("user-usecase", fmt.Sprintf("Failed update existing order : %+v", err), "UpdateOrder", "")
-						errObj := httpError.NewInternalServerError()
-						errObj.Message = "Failed update existing order"
-						result.Error = errObj
-						return result
-					}
-				} else {
+				if elapsed <= MatchingTimeoutMinutes*time.Minute {
 					errObj := httpError.NewBadRequest()
 					errObj.Message = "There are still orders being processed, please wait for the driver or cancel the previous order."
 					result.Error = errObj
 					return result
 				}
+				// the stale order is replaced below, its reservation must not outlive it
+				if err := c.releasePayment(ctx, &current); err != nil {
+					errObj := httpError.NewInternalServerError()
+					errObj.Message = "Failed release previous payment reservation"
+					result.Error = errObj
+					return result
+				}
+				stale = &current
 			case "ACCEPTED":
 				errObj := httpError.NewBadRequest()
 				errObj.Message = "Your order has been accepted by the driver. Complete or cancel this order before creating a new one.."
@@ -305,7 +272,69 @@ func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverR
 				errObj.Message = "Your trip is in progress. Please complete your trip before placing a new order."
 				result.Error = errObj
 				return result
-			default:
+			}
+		}
+
+		// the fare is held before the order is written, a failed write releases it
+		if err := paymentMethod.Reserve(ctx, orderID, request.UserID, tripPlan.MaxPrice); err != nil {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed reserve payment : %+v", err), "FindDriver", orderID)
+			var balanceErr *repository.InsufficientBalanceError
+			if errors.As(err, &balanceErr) {
+				result.Error = insufficientBalanceError(balanceErr.Amount, balanceErr.Balance)
+				return result
+			}
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "Failed reserve payment"
+			result.Error = errObj
+			return result
+		}
+
+		var sharedGroup *entity.SharedRideGroup
+		if fareSplit != nil {
+			fareSplit.OrderID = orderID
+			sharedGroup = c.findSharedGroup(ctx, &entity.Order{
+				OrderID:        orderID,
+				PassengerID:    request.UserID,
+				OriginLat:      tripPlan.Route.Origin.Latitude,
+				OriginLng:      tripPlan.Route.Origin.Longitude,
+				DestinationLat: tripPlan.Route.Destination.Latitude,
+				DestinationLng: tripPlan.Route.Destination.Longitude,
+			})
+		}
+
+		// the order and the event announcing it commit together, the outbox relay
+		// publishes the event
+		pooledDriver := ""
+		err := c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+			if stale != nil {
+				updateReq := &entity.UpdateOrderRequest{
+					ID:                 stale.ID,
+					OrderID:            orderID,
+					PassengerID:        request.UserID,
+					OriginLat:          tripPlan.Route.Origin.Latitude,
+					OriginLng:          tripPlan.Route.Origin.Longitude,
+					DestinationLat:     tripPlan.Route.Destination.Latitude,
+					DestinationLng:     tripPlan.Route.Destination.Longitude,
+					OriginAddress:      tripPlan.Route.Origin.Address,
+					DestinationAddress: tripPlan.Route.Destination.Address,
+					MinPrice:           tripPlan.MinPrice,
+					MaxPrice:           tripPlan.MaxPrice,
+					BestRouteKm:        tripPlan.BestRouteKm,
+					BestRoutePrice:     tripPlan.BestRoutePrice,
+					BestRouteDuration:  tripPlan.BestRouteDuration,
+					Status:             "REQUESTED",
+					PaymentMethod:      paymentMethod.Code(),
+					PaymentStatus:      "UNPAID",
+					CompanyID:          optionalString(booking.CompanyID),
+					CostCenter:         optionalString(booking.CostCenter),
+					DispatchMode:       dispatchMode,
+					RideType:           rideType,
+					DriverID:           nil,
+				}
+				if err := c.OrderRepository.UpdateOrder(ctx, updateReq); err != nil {
+					return fmt.Errorf("update existing order: %w", err)
+				}
+			} else {
 				tripOrder := &entity.CreateOrder{
 					OrderID:            orderID,
 					PassengerID:        request.UserID,
@@ -320,37 +349,100 @@ func (c *UserUseCase) FindDriver(ctx context.Context, request *model.FindDriverR
 					BestRouteKm:        tripPlan.BestRouteKm,
 					BestRoutePrice:     tripPlan.BestRoutePrice,
 					BestRouteDuration:  tripPlan.BestRouteDuration,
-					PaymentMethod:      request.PaymentMethod,
+					PaymentMethod:      paymentMethod.Code(),
+					CompanyID:          optionalString(booking.CompanyID),
+					CostCenter:         optionalString(booking.CostCenter),
+					DispatchMode:       dispatchMode,
+					RideType:           rideType,
 				}
-
 				if err := c.OrderRepository.InsertOrder(ctx, tripOrder); err != nil {
-					c.Log.Error("user-usecase", fmt.Sprintf("Failed insert order to db : %+v", err), "InsertOrder", "")
-					errObj := httpError.NewInternalServerError()
-					errObj.Message = "Failed create order"
-					result.Error = errObj
-					return result
+					return fmt.Errorf("insert order: %w", err)
 				}
+			}
 
+			// a replaced stale order carries the new order id, it starts over as a new order
+			if err := c.OrderEvents.StatusChanged(ctx, orderID, "", "REQUESTED"); err != nil {
+				return err
 			}
+			if fareSplit != nil {
+				if err := c.SharedRideRepository.InsertFareSplit(ctx, fareSplit); err != nil {
+					c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed insert fare split : %+v", err), "FindDriver", orderID)
+				}
+			}
+			if sharedGroup != nil {
+				ok, err := c.SharedRideRepository.JoinGroup(ctx, sharedGroup.GroupID, orderID, request.UserID, sharedGroup.DriverID)
+				if err != nil {
+					return fmt.Errorf("join shared group %s: %w", sharedGroup.GroupID, err)
+				}
+				if ok {
+					pooledDriver = sharedGroup.DriverID
+					if err := c.OrderEvents.StatusChanged(ctx, orderID, "REQUESTED", "ACCEPTED"); err != nil {
+						return err
+					}
+					return c.writeDriverMatch(ctx, orderID, request.UserID, pooledDriver, tripPlan)
+				}
+				// the group filled up meanwhile, the order is matched on its own
+			}
+			// written before the commit, the relay may publish the request as soon as it commits
+			if err := recordCandidates(ctx, c.Redis, orderID, payload.CandidateDriverIDs); err != nil {
+				return fmt.Errorf("record candidate drivers: %w", err)
+			}
+			// batch orders are offered to a single driver by the batch dispatcher instead of broadcast
+			if dispatchMode == model.DispatchModeBatch {
+				return nil
+			}
+			event := converter.UserToEvent(payload)
+			c.Log.WithContext(ctx).Info("user-usecase", "Writing user created event to outbox", "FindDriver", utils.ConvertString(event))
+			message, err := c.UserProducer.OutboxRequestRide(ctx, event)
+			if err != nil {
+				return err
+			}
+			return c.OutboxRepository.InsertMessage(ctx, message)
+		})
+		if err != nil {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed write order : %+v", err), "FindDriver", orderID)
+			if errRelease := paymentMethod.Release(ctx, orderID); errRelease != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed release payment of unwritten order : %+v", errRelease), "FindDriver", orderID)
+			}
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "Failed create order"
+			result.Error = errObj
+			return result
 		}
-		posibleDriver = fmt.Sprintf("Please sit back, there are %d drivers available, we will let you know", len(drivers))
-
-		event := converter.UserToEvent(payload)
-		c.Log.Info("user-usecase", "Publishing user created event", "FindDriver", utils.ConvertString(event))
-		if err = c.UserProducer.SendRequestRide(event); err != nil {
-			c.Log.Error("user-usecase", fmt.Sprintf("Failed publish user created event : %+v", err), "FindDriver", "")
-			result.Error = httpError.NewInternalServerError()
+		if pooledDriver != "" {
+			c.Log.WithContext(ctx).Info("user-usecase", fmt.Sprintf("Order pooled into group %s", sharedGroup.GroupID), "FindDriver", orderID)
+			result.Data = model.FindDriverResponse{
+				OrderID: orderID,
+				Message: "You joined a shared ride, your driver is on the way",
+				Driver:  pooledDriver,
+			}
 			return result
 		}
-		task, err := c.NewBroadcastPassanger(ctx, payload)
-		if err != nil {
-			c.Log.Error("user-usecase", fmt.Sprintf("Error creating broadcast task: %v", err), "FindDriver", "")
+		posibleDriver = fmt.Sprintf("Please sit back, there are %d drivers available, we will let you know", len(drivers))
+
+		if dispatchMode != model.DispatchModeBatch {
+			task, err := c.NewBroadcastPassanger(ctx, payload)
+			if err != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error creating broadcast task: %v", err), "FindDriver", "")
+			}
+			info, err := c.AsynqClient.Enqueue(task)
+			if err != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error enqueuing broadcast task: %v", err), "FindDriver", "")
+			}
+			c.Log.WithContext(ctx).Info("user-usecase", "Enqueued broadcast task", "FindDriver", utils.ConvertString(info))
 		}
-		info, err := c.AsynqClient.Enqueue(task)
-		if err != nil {
-			c.Log.Error("user-usecase", fmt.Sprintf("Error enqueuing broadcast task: %v", err), "FindDriver", "")
+		if dispatchMode != model.DispatchModeManual {
+			dispatchTask, err := c.NewAutoDispatchTask(ctx, &model.AutoDispatchTask{
+				OrderID:     orderID,
+				PassengerID: request.UserID,
+				Deadline:    time.Now().Add(MatchingTimeoutMinutes * time.Minute),
+			})
+			if err != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error creating auto dispatch task: %v", err), "FindDriver", "")
+			} else if _, err := c.AsynqClient.Enqueue(dispatchTask); err != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error enqueuing auto dispatch task: %v", err), "FindDriver", "")
+			}
 		}
-		c.Log.Info("user-usecase", "Enqueued broadcast task", "FindDriver", utils.ConvertString(info))
 	}
 	result.Data = model.FindDriverResponse{
 		OrderID: orderID,
@@ -367,69 +459,148 @@ func (c *UserUseCase) NewBroadcastPassanger(ctx context.Context, payload *model.
 	}
 	pyld, err := json.Marshal(payload)
 	if err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Error marshalling payload: %v", err), "FindDriver", "")
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error marshalling payload: %v", err), "FindDriver", "")
 		return nil, err
 	}
-	return asynq.NewTask(TypeBroadcastDriver, pyld, asynq.MaxRetry(5), asynq.ProcessIn(60*time.Second)), nil
+	return asynq.NewTask(TypeBroadcastDriver, tracing.InjectPayload(ctx, pyld), asynq.MaxRetry(5), asynq.ProcessIn(60*time.Second)), nil
 }
 
 func (c *UserUseCase) RequestRide(ctx context.Context, t *asynq.Task) error {
 	var payload model.RequestRide
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "RequestRide", "")
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "RequestRide", "")
 		return err
 	}
 	// validate payload if order temp id now already picked by driver
 	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &payload.OrderTempID})
 	if order != nil && err == nil {
-		c.Log.Info("user-usecase", "Order already picked by driver, skipping broadcast", "RequestRide", payload.OrderTempID)
+		c.Log.WithContext(ctx).Info("user-usecase", "Order already picked by driver, skipping broadcast", "RequestRide", payload.OrderTempID)
 		return nil
 	}
 	if payload.Attempt >= 5 {
-		c.Log.Info("user-usecase",
+		c.Log.WithContext(ctx).Info("user-usecase",
 			fmt.Sprintf("Max attempts reached (%d), giving up broadcast", payload.Attempt),
 			"RequestRide",
 			payload.OrderTempID,
 		)
 		return nil
 	}
+	// drivers move between attempts, the candidates are selected again on every broadcast
+	drivers, err := c.findCandidateDrivers(ctx, payload.RouteSummary)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error searching drivers: %v", err), "RequestRide", payload.OrderTempID)
+		return err
+	}
+	if err := recordCandidates(ctx, c.Redis, payload.OrderTempID, driverIDs(drivers)); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error record candidate drivers: %v", err), "RequestRide", payload.OrderTempID)
+		return err
+	}
 	event := converter.UserToEvent(&model.RequestRide{
-		UserId:       payload.UserId,
-		OrderTempID:  payload.OrderTempID,
-		RouteSummary: payload.RouteSummary,
+		UserId:             payload.UserId,
+		OrderTempID:        payload.OrderTempID,
+		RouteSummary:       payload.RouteSummary,
+		CandidateDriverIDs: driverIDs(drivers),
 	})
-	c.Log.Info("user-usecase", "Publishing user created event", "FindDriver", utils.ConvertString(event))
-	if err := c.UserProducer.Send(event); err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Failed publish user created event : %+v", err), "FindDriver", "")
+	c.Log.WithContext(ctx).Info("user-usecase", "Publishing user created event", "FindDriver", utils.ConvertString(event))
+	if err := c.UserProducer.Send(ctx, event); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed publish user created event : %+v", err), "FindDriver", "")
 		return err
 	}
 	nextPayload := payload
 	nextPayload.Attempt = payload.Attempt + 1
 	nextBytes, err := json.Marshal(&nextPayload)
 	if err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Error marshalling next payload: %v", err), "RequestRide", "")
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error marshalling next payload: %v", err), "RequestRide", "")
 		return err
 	}
 	nextTask := asynq.NewTask(
 		TypeBroadcastDriver,
-		nextBytes,
+		tracing.InjectPayload(ctx, nextBytes),
 		asynq.MaxRetry(1),
 		asynq.ProcessIn(60*time.Second),
 	)
 	if _, err := c.AsynqClient.Enqueue(nextTask); err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Error enqueue next broadcast task: %v", err), "RequestRide", "")
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error enqueue next broadcast task: %v", err), "RequestRide", "")
 		return err
 	}
 	return nil
 }
 
+// findCandidateDrivers returns the drivers around the pickup, nearest first, without
+// the drivers in destination mode the trip does not bring closer to home.
+func (c *UserUseCase) findCandidateDrivers(ctx context.Context, tripPlan model.RouteSummary) ([]redis.GeoLocation, error) {
+	origin := tripPlan.Route.Origin
+	if zone, ok := queueZoneAt(c.Config, origin.Latitude, origin.Longitude); ok {
+		drivers, err := c.queueCandidates(ctx, zone.ID, origin)
+		if err != nil || len(drivers) > 0 {
+			drivers = filterDebtBlocked(ctx, c.Redis, drivers)
+			return filterByDestination(ctx, c.Redis, c.Config, drivers, tripPlan.Route.Destination.Latitude, tripPlan.Route.Destination.Longitude), err
+		}
+		// an empty queue falls back to the nearest drivers so the order is still served
+	}
+
+	radius := 3.0
+	drivers, err := c.Redis.GeoRadius(ctx, "drivers-locations", tripPlan.Route.Origin.Longitude, tripPlan.Route.Origin.Latitude, &redis.GeoRadiusQuery{
+		Radius:    radius,
+		Unit:      "km",
+		WithDist:  true,
+		WithCoord: true,
+		Sort:      "ASC",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	drivers = filterDebtBlocked(ctx, c.Redis, drivers)
+	return filterByDestination(ctx, c.Redis, c.Config, drivers, tripPlan.Route.Destination.Latitude, tripPlan.Route.Destination.Longitude), nil
+}
+
+// queueCandidates returns the drivers of a queue zone in queue order.
+func (c *UserUseCase) queueCandidates(ctx context.Context, zoneID string, origin model.LocationRequest) ([]redis.GeoLocation, error) {
+	ids, err := queuedDrivers(ctx, c.Redis, zoneID)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	positions, err := c.Redis.GeoPos(ctx, "drivers-locations", ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	drivers := make([]redis.GeoLocation, 0, len(ids))
+	for i, pos := range positions {
+		if pos == nil {
+			continue
+		}
+		drivers = append(drivers, redis.GeoLocation{
+			Name:      ids[i],
+			Latitude:  pos.Latitude,
+			Longitude: pos.Longitude,
+			Dist:      utils.HaversineKm(origin.Latitude, origin.Longitude, pos.Latitude, pos.Longitude),
+		})
+	}
+	return drivers, nil
+}
+
+func driverIDs(drivers []redis.GeoLocation) []string {
+	ids := make([]string, 0, len(drivers))
+	for _, driver := range drivers {
+		ids = append(ids, driver.Name)
+	}
+	return ids
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 func (c *UserUseCase) ConfirmOrder(ctx context.Context, request *model.ConfirmOrderRequest) utils.Result {
 	var result utils.Result
 	if err := c.Validate.Struct(request); err != nil {
 		errObj := httpError.NewBadRequest()
 		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "ConfirmOrder", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", utils.ConvertString(err))
 		return result
 	}
 	filter := entity.OrderFilter{
@@ -441,7 +612,7 @@ func (c *UserUseCase) ConfirmOrder(ctx context.Context, request *model.ConfirmOr
 		errObj := httpError.NewNotFound()
 		errObj.Message = "Order not found"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "ConfirmOrder", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", utils.ConvertString(err))
 		return result
 	}
 	switch order.Status {
@@ -449,59 +620,37 @@ func (c *UserUseCase) ConfirmOrder(ctx context.Context, request *model.ConfirmOr
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order is already completed or cancelled"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "ConfirmOrder", "")
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", "")
 		return result
 	case "ACCEPTED", "ON_GOING":
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order already has a driver assigned"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "ConfirmOrder", "")
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", "")
+		return result
+	}
+	if order.DispatchMode == model.DispatchModeAuto || order.DispatchMode == model.DispatchModeBatch {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Order is auto-dispatched, the first driver who accepts is assigned automatically"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", request.OrderID)
 		return result
 	}
-	ok, err := c.OrderRepository.AssignDriverToOrder(ctx, request.OrderID, request.UserID, request.DriverID)
+	ok, err := c.assignDriver(ctx, order, request.DriverID)
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "Failed to assign driver to order"
 		result.Error = errObj
-		c.Log.Error("user-usecase", fmt.Sprintf("AssignDriverToOrder error: %v", err), "ConfirmOrder", "")
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("AssignDriverToOrder error: %v", err), "ConfirmOrder", "")
 		return result
 	}
 	if !ok {
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order has already been taken or no longer in a confirmable state"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "ConfirmOrder", "concurrent-update")
-		return result
-	}
-	key := fmt.Sprintf("USER:ROUTE:%s", request.UserID)
-	var tripPlan model.RouteSummary
-	redisData, errRedis := c.Redis.Get(ctx, key).Result()
-	if errRedis != nil || redisData == "" {
-		errObj := httpError.NewNotFound()
-		errObj.Message = fmt.Sprintf("Error get data from redis: %v", errRedis)
-		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(errRedis))
-		return result
-	}
-	err = json.Unmarshal([]byte(redisData), &tripPlan)
-	if err != nil {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = fmt.Sprintf("Error unmarshal tripdata: %v", err)
-		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "FindDriver", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "ConfirmOrder", "concurrent-update")
 		return result
 	}
-
-	driverMatchEvent := &model.DriverMatchEvent{
-		EventID:      utils.GenerateUniqueIDWithPrefix("driver_match"),
-		OrderID:      request.OrderID,
-		PassengerID:  request.UserID,
-		DriverID:     request.DriverID,
-		RouteSummary: tripPlan,
-	}
-	if err := c.UserProducer.SendDriverMatch(driverMatchEvent); err != nil {
-		c.Log.Error("user-usecase", fmt.Sprintf("Failed publish driver match event: %v", err), "ConfirmOrder", "")
-	}
 	resp := model.ConfirmOrderResponse{
 		OrderID:  request.OrderID,
 		UserID:   request.UserID,
@@ -521,7 +670,7 @@ func (c *UserUseCase) CancelOrder(ctx context.Context, request *model.CancelOrde
 		errObj := httpError.NewBadRequest()
 		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(err))
 		return result
 	}
 
@@ -534,7 +683,7 @@ func (c *UserUseCase) CancelOrder(ctx context.Context, request *model.CancelOrde
 		errObj := httpError.NewNotFound()
 		errObj.Message = "Order not found"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(err))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(err))
 		return result
 	}
 
@@ -544,16 +693,30 @@ func (c *UserUseCase) CancelOrder(ctx context.Context, request *model.CancelOrde
 		errObj := httpError.NewConflict()
 		errObj.Message = fmt.Sprintf("Cannot cancel order in status %s", order.Status)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "CancelOrder", order.Status)
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "CancelOrder", order.Status)
 		return result
 	}
 
-	ok, err := c.OrderRepository.UpdateStatusOrder(ctx, request.OrderID, "CANCELLED")
+	ok := false
+	var refund *entity.Refund
+	err = c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		updated, err := c.OrderRepository.UpdateStatusOrder(ctx, request.OrderID, order.Status, "CANCELLED")
+		if err != nil || !updated {
+			return err
+		}
+		ok = true
+		if err := c.OrderEvents.StatusChanged(ctx, request.OrderID, order.Status, "CANCELLED"); err != nil {
+			return err
+		}
+		// the refund commits with the cancellation, the refund sweep enqueues it if the task below is lost
+		refund, err = c.recordCancellationRefund(ctx, order)
+		return err
+	})
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = fmt.Sprintf("Failed to update order status: %v", err)
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(request))
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "CancelOrder", utils.ConvertString(request))
 		return result
 	}
 
@@ -561,18 +724,35 @@ func (c *UserUseCase) CancelOrder(ctx context.Context, request *model.CancelOrde
 		errObj := httpError.NewConflict()
 		errObj.Message = "Order could not be cancelled, possibly already processed"
 		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "CancelOrder", request.OrderID)
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "CancelOrder", request.OrderID)
 		return result
 	}
 
+	if order.SharedGroupID != nil {
+		if err := c.SharedRideRepository.LeaveGroup(ctx, *order.SharedGroupID); err != nil {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed leave shared group: %v", err), "CancelOrder", request.OrderID)
+		}
+	}
+	if refund != nil {
+		if err := enqueueRefund(ctx, c.AsynqClient, c.Config, refund.RefundID); err != nil {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed enqueue refund task, left to the refund sweep: %v", err), "CancelOrder", request.OrderID)
+		}
+	}
+
 	key := fmt.Sprintf("USER:ROUTE:%s", request.UserID)
 	_ = c.Redis.Del(ctx, key).Err()
 
-	result.Data = map[string]interface{}{
+	data := map[string]interface{}{
 		"order_id": order.OrderID,
 		"status":   "CANCELLED",
 		"message":  "Order cancelled successfully",
 	}
+	if refund != nil {
+		data["refund_id"] = refund.RefundID
+		data["refund_status"] = refund.Status
+		data["cancellation_fee"] = refund.FeeAmount
+	}
+	result.Data = data
 
 	return result
 }
@@ -595,7 +775,7 @@ func (c *UserUseCase) OrderDetail(ctx context.Context, request *model.OrderDetai
 
 func (c *UserUseCase) GetDriverPickupRequest(ctx context.Context, request *model.OrderDetailRequest) utils.Result {
 	var result utils.Result
-	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &request.OrderID})
+	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &request.OrderID, PassengerID: &request.UserID})
 	if err != nil || order == nil {
 		errObj := httpError.NewNotFound()
 		errObj.Message = "Order Not Found"
@@ -613,24 +793,40 @@ func (c *UserUseCase) GetDriverPickupRequest(ctx context.Context, request *model
 		BestRoutePrice:     order.BestRoutePrice,
 		CreatedAt:          order.CreatedAt,
 	}
-	// get list driver from redis
-	// listDrivers
-	pattern := fmt.Sprintf("DRIVER:REQUEST-PICKUP:%s:*", request.OrderID)
-	var drivers []model.DriverPickupInfo
 
-	iter := c.Redis.Scan(ctx, 0, pattern, 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-		parts := strings.Split(key, ":")
-		if len(parts) < 4 {
-			c.Log.Error("user-usecase", fmt.Sprintf("unexpected key format: %s", key), "GetDriverPickupRequest", "")
-			continue
+	var driverIDs []string
+	assigned := false
+	if order.DispatchMode == model.DispatchModeAuto || order.DispatchMode == model.DispatchModeBatch {
+		// auto-dispatched orders never wait for the passenger, the AutoDispatch task
+		// assigns the driver and the passenger only sees it once assigned
+		if order.DriverID != nil && *order.DriverID != "" {
+			driverIDs = []string{*order.DriverID}
+			assigned = true
+		}
+	} else {
+		driverIDs, err = c.listPickupOffers(ctx, request.OrderID)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "Failed to read driver pickup data"
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "GetDriverPickupRequest", utils.ConvertString(err))
+			return result
 		}
-		driverID := parts[len(parts)-1]
-		driver, err := c.DriverRepository.GetDetailDriver(ctx, driverID)
-		fmt.Println(driver, "<<<<JANCOOOKKKK")
-		if err != nil || driver == nil {
-			c.Log.Error("user-usecase", fmt.Sprintf("driver %s not found in DB", driverID), "GetDriverPickupRequest", "")
+	}
+
+	driverInfos, err := c.getDriverInfos(ctx, driverIDs)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "Failed to read driver pickup data"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "GetDriverPickupRequest", utils.ConvertString(err))
+		return result
+	}
+	var drivers []model.DriverPickupInfo
+	for _, driverID := range driverIDs {
+		driver, ok := driverInfos[driverID]
+		if !ok {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("driver %s not found in DB", driverID), "GetDriverPickupRequest", "")
 			continue
 		}
 
@@ -642,36 +838,39 @@ func (c *UserUseCase) GetDriverPickupRequest(ctx context.Context, request *model
 			City:        driver.City,
 		})
 	}
-	if err := iter.Err(); err != nil {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = "Failed to read driver pickup data"
-		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "GetDriverPickupRequest", utils.ConvertString(err))
-		return result
-	}
 	if len(drivers) == 0 {
 		errObj := httpError.NewNotFound()
 		errObj.Message = "No driver pickup request found for this order, Wait we find for you"
 		result.Error = errObj
-		c.Log.Info("user-usecase", errObj.Message, "GetDriverPickupRequest", request.OrderID)
+		c.Log.WithContext(ctx).Info("user-usecase", errObj.Message, "GetDriverPickupRequest", request.OrderID)
 		return result
 	}
 
-	ok, err := c.OrderRepository.UpdateStatusOrder(ctx, request.OrderID, "MATCHING")
-	if err != nil {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = fmt.Sprintf("Failed to update order status: %v", err)
-		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "UpdateStatusOrder", utils.ConvertString(request))
-		return result
-	}
+	if !assigned && order.Status != "MATCHING" {
+		ok := false
+		err := c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+			updated, err := c.OrderRepository.UpdateStatusOrder(ctx, request.OrderID, order.Status, "MATCHING")
+			if err != nil || !updated {
+				return err
+			}
+			ok = true
+			return c.OrderEvents.StatusChanged(ctx, request.OrderID, order.Status, "MATCHING")
+		})
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = fmt.Sprintf("Failed to update order status: %v", err)
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "UpdateStatusOrder", utils.ConvertString(request))
+			return result
+		}
 
-	if !ok {
-		errObj := httpError.NewNotFound()
-		errObj.Message = "Order not found or status not updated"
-		result.Error = errObj
-		c.Log.Error("user-usecase", errObj.Message, "UpdateStatusOrder", request.OrderID)
-		return result
+		if !ok {
+			errObj := httpError.NewNotFound()
+			errObj.Message = "Order not found or status not updated"
+			result.Error = errObj
+			c.Log.WithContext(ctx).Error("user-usecase", errObj.Message, "UpdateStatusOrder", request.OrderID)
+			return result
+		}
 	}
 
 	result.Data = model.OrderPickupSummaryResponse{
@@ -683,6 +882,383 @@ func (c *UserUseCase) GetDriverPickupRequest(ctx context.Context, request *model
 
 }
 
+func (c *UserUseCase) NewAutoDispatchTask(ctx context.Context, payload *model.AutoDispatchTask) (*asynq.Task, error) {
+	pyld, err := json.Marshal(payload)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error marshalling payload: %v", err), "NewAutoDispatchTask", "")
+		return nil, err
+	}
+	interval := c.Config.GetDuration("dispatch.auto.poll_interval")
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return asynq.NewTask(TypeAutoDispatch, tracing.InjectPayload(ctx, pyld), asynq.MaxRetry(3), asynq.ProcessIn(interval)), nil
+}
+
+// AutoDispatch polls the driver offers of an auto-dispatched order and assigns
+// the best-ranked driver, it re-enqueues itself until the matching deadline.
+func (c *UserUseCase) AutoDispatch(ctx context.Context, t *asynq.Task) error {
+	var payload model.AutoDispatchTask
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "AutoDispatch", "")
+		return err
+	}
+	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &payload.OrderID, PassengerID: &payload.PassengerID})
+	if err != nil || order == nil {
+		c.Log.WithContext(ctx).Error("user-usecase", "Order not found, stop auto dispatch", "AutoDispatch", payload.OrderID)
+		return nil
+	}
+	if order.Status != "REQUESTED" && order.Status != "MATCHING" {
+		c.Log.WithContext(ctx).Info("user-usecase", fmt.Sprintf("Order in status %s, stop auto dispatch", order.Status), "AutoDispatch", payload.OrderID)
+		return nil
+	}
+	driverID, err := c.autoAssign(ctx, order)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("autoAssign error: %v", err), "AutoDispatch", payload.OrderID)
+		return err
+	}
+	if driverID != "" {
+		c.Log.WithContext(ctx).Info("user-usecase", fmt.Sprintf("Driver %s assigned automatically", driverID), "AutoDispatch", payload.OrderID)
+		return nil
+	}
+	if time.Now().After(payload.Deadline) {
+		c.Log.WithContext(ctx).Info("user-usecase", "Matching deadline reached, giving up auto dispatch", "AutoDispatch", payload.OrderID)
+		return nil
+	}
+	nextTask, err := c.NewAutoDispatchTask(ctx, &payload)
+	if err != nil {
+		return err
+	}
+	if _, err := c.AsynqClient.Enqueue(nextTask); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error enqueue next auto dispatch task: %v", err), "AutoDispatch", "")
+		return err
+	}
+	return nil
+}
+
+// autoAssign assigns the best-ranked driver who accepted the order. It returns an
+// empty driver id when nobody has accepted yet or the order was taken concurrently.
+func (c *UserUseCase) autoAssign(ctx context.Context, order *entity.Order) (string, error) {
+	driverIDs, err := c.listPickupOffers(ctx, order.OrderID)
+	if err != nil {
+		return "", err
+	}
+	ranked := c.rankPickupOffers(ctx, order, driverIDs)
+	if len(ranked) == 0 {
+		return "", nil
+	}
+	ok, err := c.assignDriver(ctx, order, ranked[0])
+	if err != nil || !ok {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// assignDriver moves the order to ACCEPTED for the given driver and writes the
+// driver-match event with it. It returns false when the order is no longer
+// confirmable.
+func (c *UserUseCase) assignDriver(ctx context.Context, order *entity.Order, driverID string) (bool, error) {
+	routeSummary := c.tripPlanForOrder(ctx, order)
+	assigned := false
+	err := c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+		ok, err := c.OrderRepository.AssignDriverToOrder(ctx, order.OrderID, order.PassengerID, driverID)
+		if err != nil || !ok {
+			return err
+		}
+		assigned = true
+		if err := c.OrderEvents.StatusChanged(ctx, order.OrderID, order.Status, "ACCEPTED"); err != nil {
+			return err
+		}
+
+		// the first passenger of a shared ride opens the group later passengers are pooled into
+		if order.RideType == model.RideTypeShared && order.SharedGroupID == nil {
+			seats := c.Config.GetInt("shared.max_seats")
+			if seats <= 0 {
+				seats = 3
+			}
+			groupID := utils.GenerateUniqueIDWithPrefix("shared")
+			if err := c.SharedRideRepository.CreateGroup(ctx, groupID, driverID, seats, order.OrderID); err != nil {
+				return fmt.Errorf("create shared group: %w", err)
+			}
+		}
+		return c.writeDriverMatch(ctx, order.OrderID, order.PassengerID, driverID, routeSummary)
+	})
+	if err != nil || !assigned {
+		return false, err
+	}
+
+	// the driver leaves the queue with the passenger instead of waiting for the sweep
+	if zone, ok := queueZoneAt(c.Config, order.OriginLat, order.OriginLng); ok {
+		_ = c.Redis.ZRem(ctx, fmt.Sprintf("QUEUE:ZONE:%s", zone.ID), driverID).Err()
+	}
+	return true, nil
+}
+
+// writeDriverMatch writes the driver-match event to the outbox in the transaction
+// carried by ctx.
+func (c *UserUseCase) writeDriverMatch(ctx context.Context, orderID, passengerID, driverID string, routeSummary model.RouteSummary) error {
+	message, err := c.UserProducer.OutboxDriverMatch(ctx, &model.DriverMatchEvent{
+		EventID:      utils.GenerateUniqueIDWithPrefix("driver_match"),
+		OrderID:      orderID,
+		PassengerID:  passengerID,
+		DriverID:     driverID,
+		RouteSummary: routeSummary,
+	})
+	if err != nil {
+		return err
+	}
+	return c.OutboxRepository.InsertMessage(ctx, message)
+}
+
+// tripPlanForOrder returns the route the passenger planned, falling back to the
+// route stored on the order once the cached plan has expired.
+func (c *UserUseCase) tripPlanForOrder(ctx context.Context, order *entity.Order) model.RouteSummary {
+	var tripPlan model.RouteSummary
+	key := fmt.Sprintf("USER:ROUTE:%s", order.PassengerID)
+	redisData, errRedis := c.Redis.Get(ctx, key).Result()
+	if errRedis == nil && redisData != "" {
+		if err := json.Unmarshal([]byte(redisData), &tripPlan); err == nil {
+			return tripPlan
+		}
+	}
+	c.Log.WithContext(ctx).Info("user-usecase", "Trip plan not cached, rebuild from order", "tripPlanForOrder", order.OrderID)
+	return routeSummaryFromOrder(order)
+}
+
+// listPickupOffers returns the drivers who accepted the pickup request of an order,
+// oldest offer first. Offers older than dispatch.offer_ttl are dropped.
+func (c *UserUseCase) listPickupOffers(ctx context.Context, orderID string) ([]string, error) {
+	key := fmt.Sprintf("ORDER:PICKUP-OFFERS:%s", orderID)
+	ttl := c.Config.GetDuration("dispatch.offer_ttl")
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	minScore := fmt.Sprintf("%d", time.Now().Add(-ttl).UnixMilli())
+	pipe := c.Redis.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+minScore)
+	offers := pipe.ZRange(ctx, key, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	driverIDs := offers.Val()
+	if c.Config.GetBool("dispatch.legacy_pickup_keys") {
+		legacy, err := c.listLegacyPickupOffers(ctx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool, len(driverIDs))
+		for _, driverID := range driverIDs {
+			seen[driverID] = true
+		}
+		for _, driverID := range legacy {
+			if !seen[driverID] {
+				seen[driverID] = true
+				driverIDs = append(driverIDs, driverID)
+			}
+		}
+	}
+	return driverIDs, nil
+}
+
+// listLegacyPickupOffers returns the drivers who accepted through the
+// DRIVER:REQUEST-PICKUP:<orderId>:<driverId> keys older driver services write,
+// they carry no accept time and rank after the indexed offers. It scans Redis
+// and only runs with the temporary dispatch.legacy_pickup_keys opt-in.
+// TODO: remove with dispatch.legacy_pickup_keys once the driver services write the offer index.
+func (c *UserUseCase) listLegacyPickupOffers(ctx context.Context, orderID string) ([]string, error) {
+	pattern := fmt.Sprintf("DRIVER:REQUEST-PICKUP:%s:*", orderID)
+	var driverIDs []string
+
+	iter := c.Redis.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		parts := strings.Split(key, ":")
+		if len(parts) < 4 {
+			c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("unexpected key format: %s", key), "listLegacyPickupOffers", "")
+			continue
+		}
+		driverIDs = append(driverIDs, parts[len(parts)-1])
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(driverIDs)
+	return driverIDs, nil
+}
+
+func orderCandidatesKey(orderID string) string {
+	return fmt.Sprintf("ORDER:CANDIDATES:%s", orderID)
+}
+
+// recordCandidates remembers the drivers the order was broadcast to, only they
+// may accept its pickup request.
+func recordCandidates(ctx context.Context, rdb redis.UniversalClient, orderID string, driverIDs []string) error {
+	if len(driverIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(driverIDs))
+	for _, driverID := range driverIDs {
+		members = append(members, driverID)
+	}
+	key := orderCandidatesKey(orderID)
+	pipe := rdb.TxPipeline()
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, MatchingTimeoutMinutes*time.Minute)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// rankPickupOffers orders the offering drivers for auto dispatch. With the "first"
+// strategy the first driver who accepted wins, otherwise the drivers are sorted by
+// their distance to the pickup point and drivers without a known position go last.
+func (c *UserUseCase) rankPickupOffers(ctx context.Context, order *entity.Order, driverIDs []string) []string {
+	if len(driverIDs) < 2 {
+		return driverIDs
+	}
+	if zone, ok := queueZoneAt(c.Config, order.OriginLat, order.OriginLng); ok {
+		return c.rankByQueue(ctx, zone.ID, driverIDs)
+	}
+	if strings.EqualFold(c.Config.GetString("dispatch.auto.strategy"), "first") {
+		return driverIDs
+	}
+	positions, err := c.Redis.GeoPos(ctx, "drivers-locations", driverIDs...).Result()
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error get driver positions: %v", err), "rankPickupOffers", order.OrderID)
+		return driverIDs
+	}
+	distances := make(map[string]float64, len(driverIDs))
+	for i, pos := range positions {
+		if pos == nil {
+			continue
+		}
+		distances[driverIDs[i]] = utils.HaversineKm(order.OriginLat, order.OriginLng, pos.Latitude, pos.Longitude)
+	}
+	ranked := append([]string(nil), driverIDs...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		di, iok := distances[ranked[i]]
+		dj, jok := distances[ranked[j]]
+		if iok != jok {
+			return iok
+		}
+		return iok && di < dj
+	})
+	return ranked
+}
+
+// rankByQueue puts the queued drivers first in queue order, drivers outside the
+// queue keep their accept order behind them.
+func (c *UserUseCase) rankByQueue(ctx context.Context, zoneID string, driverIDs []string) []string {
+	queued, err := queuedDrivers(ctx, c.Redis, zoneID)
+	if err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error read queue: %v", err), "rankByQueue", zoneID)
+		return driverIDs
+	}
+	position := make(map[string]int, len(queued))
+	for i, driverID := range queued {
+		position[driverID] = i
+	}
+	ranked := append([]string(nil), driverIDs...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		pi, iok := position[ranked[i]]
+		pj, jok := position[ranked[j]]
+		if iok != jok {
+			return iok
+		}
+		return iok && pi < pj
+	})
+	return ranked
+}
+
+// getDriverInfos loads driver details from the DRIVER:INFO cache and fetches the
+// misses in one query, caching them for dispatch.driver_info_ttl.
+func (c *UserUseCase) getDriverInfos(ctx context.Context, driverIDs []string) (map[string]entity.DriverInfo, error) {
+	infos := make(map[string]entity.DriverInfo, len(driverIDs))
+	if len(driverIDs) == 0 {
+		return infos, nil
+	}
+
+	pipe := c.Redis.Pipeline()
+	cached := make([]*redis.StringCmd, len(driverIDs))
+	for i, driverID := range driverIDs {
+		cached[i] = pipe.Get(ctx, fmt.Sprintf("DRIVER:INFO:%s", driverID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error read driver info cache: %v", err), "getDriverInfos", "")
+	}
+
+	var misses []string
+	for i, driverID := range driverIDs {
+		var info entity.DriverInfo
+		raw, err := cached[i].Result()
+		if err != nil || json.Unmarshal([]byte(raw), &info) != nil {
+			misses = append(misses, driverID)
+			continue
+		}
+		infos[driverID] = info
+	}
+	if len(misses) == 0 {
+		return infos, nil
+	}
+
+	drivers, err := c.DriverRepository.GetDetailDrivers(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.Config.GetDuration("dispatch.driver_info_ttl")
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	pipe = c.Redis.Pipeline()
+	for _, driver := range drivers {
+		infos[driver.DriverID] = driver
+		raw, err := json.Marshal(driver)
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, fmt.Sprintf("DRIVER:INFO:%s", driver.DriverID), raw, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error write driver info cache: %v", err), "getDriverInfos", "")
+	}
+	return infos, nil
+}
+
+// resolveDispatchMode picks the dispatch mode of a new order. A passenger segment
+// override wins over the override of the city the pickup point lies in,
+// otherwise dispatch.mode applies. BATCH only
+// applies inside a batch zone while the batch dispatcher is enabled, elsewhere the
+// order falls back to AUTO.
+func (c *UserUseCase) resolveDispatchMode(origin model.LocationRequest, segment string) string {
+	candidates := []string{
+		c.Config.GetStringMapString("dispatch.segments")[strings.ToLower(segment)],
+		c.Config.GetStringMapString("dispatch.cities")[strings.ToLower(cityAt(c.Config, origin.Latitude, origin.Longitude))],
+		c.Config.GetString("dispatch.mode"),
+	}
+	for _, mode := range candidates {
+		switch strings.ToUpper(mode) {
+		case model.DispatchModeBatch:
+			if !c.Config.GetBool("dispatch.batch.enabled") {
+				return model.DispatchModeAuto
+			}
+			// the batch solver ignores queue order, queue zones stay first come first served
+			if _, ok := queueZoneAt(c.Config, origin.Latitude, origin.Longitude); ok {
+				return model.DispatchModeAuto
+			}
+			for _, zone := range loadDispatchZones(c.Config) {
+				if zone.Contains(origin.Latitude, origin.Longitude) {
+					return model.DispatchModeBatch
+				}
+			}
+			return model.DispatchModeAuto
+		case model.DispatchModeAuto:
+			return model.DispatchModeAuto
+		case model.DispatchModeManual:
+			return model.DispatchModeManual
+		}
+	}
+	return model.DispatchModeManual
+}
+
 func (c *UserUseCase) getRouteSuggestions(ctx context.Context, currentRequest model.LocationRequest, destinationRequest model.LocationRequest) (*model.RouteSummary, error) {
 	origin := fmt.Sprintf("%f,%f", currentRequest.Latitude, currentRequest.Longitude)
 	destination := fmt.Sprintf("%f,%f", destinationRequest.Latitude, destinationRequest.Longitude)
@@ -700,12 +1276,12 @@ func (c *UserUseCase) getRouteSuggestions(ctx context.Context, currentRequest mo
 
 	routes, _, err := c.Geoservice.Directions(ctx, req)
 	if err != nil {
-		c.Log.Error("user-usecase", err.Error(), "getRouteSuggestions", fmt.Sprintf("Origin: %s, Destination: %s, err: %w", origin, destination, err.Error()))
+		c.Log.WithContext(ctx).Error("user-usecase", err.Error(), "getRouteSuggestions", fmt.Sprintf("Origin: %s, Destination: %s, err: %v", origin, destination, err.Error()))
 		return nil, fmt.Errorf("error making directions request: %w", err)
 	}
 
 	if len(routes) == 0 {
-		c.Log.Error("user-usecase", "no routes found", "getRouteSuggestions", fmt.Sprintf("Origin: %s, Destination: %s, result: %s", origin, destination, utils.ConvertString(routes)))
+		c.Log.WithContext(ctx).Error("user-usecase", "no routes found", "getRouteSuggestions", fmt.Sprintf("Origin: %s, Destination: %s, result: %s", origin, destination, utils.ConvertString(routes)))
 		return nil, fmt.Errorf("no routes found")
 	}
 
@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// dispatch test points, 0.009 degrees of latitude is about a kilometre
+var (
+	jakartaCenter = model.LocationRequest{Latitude: -6.2, Longitude: 106.82}
+	jakartaOuter  = model.LocationRequest{Latitude: -6.35, Longitude: 106.82}
+	gambir        = model.LocationRequest{Latitude: -6.176, Longitude: 106.83}
+	bandung       = model.LocationRequest{Latitude: -6.91, Longitude: 107.61}
+	yogyakarta    = model.LocationRequest{Latitude: -7.8, Longitude: 110.36}
+)
+
+func dispatchConfig() *viper.Viper {
+	cfg := viper.New()
+	cfg.Set("dispatch.mode", "MANUAL")
+	cfg.Set("dispatch.cities", map[string]string{"jakarta": "batch", "bandung": "AUTO"})
+	cfg.Set("dispatch.segments", map[string]string{"corporate": "AUTO", "legacy": "FAST"})
+	cfg.Set("dispatch.city_areas", []map[string]interface{}{
+		{"id": "jakarta", "latitude": -6.2, "longitude": 106.82, "radius_km": 30},
+		{"id": "bandung", "latitude": -6.91, "longitude": 107.61, "radius_km": 20},
+	})
+	cfg.Set("dispatch.batch.enabled", true)
+	cfg.Set("dispatch.batch.zones", []map[string]interface{}{
+		{"id": "jakarta-center", "latitude": -6.2, "longitude": 106.82, "radius_km": 10},
+	})
+	cfg.Set("dispatch.queue.zones", []map[string]interface{}{
+		{"id": "gambir", "latitude": -6.176, "longitude": 106.83, "radius_km": 1},
+	})
+	return cfg
+}
+
+func TestResolveDispatchMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		origin        model.LocationRequest
+		segment       string
+		batchDisabled bool
+		want          string
+	}{
+		{name: "outside every city", origin: yogyakarta, want: model.DispatchModeManual},
+		{name: "city override", origin: bandung, want: model.DispatchModeAuto},
+		{name: "batch city inside a batch zone", origin: jakartaCenter, want: model.DispatchModeBatch},
+		{name: "batch city outside the batch zones", origin: jakartaOuter, want: model.DispatchModeAuto},
+		{name: "batch city in a queue zone", origin: gambir, want: model.DispatchModeAuto},
+		{name: "batch dispatcher disabled", origin: jakartaCenter, batchDisabled: true, want: model.DispatchModeAuto},
+		{name: "segment wins over the city", origin: jakartaCenter, segment: "Corporate", want: model.DispatchModeAuto},
+		{name: "unknown segment", origin: jakartaCenter, segment: "student", want: model.DispatchModeBatch},
+		{name: "invalid segment mode falls through", origin: bandung, segment: "legacy", want: model.DispatchModeAuto},
+		{name: "city sent by the client is ignored", origin: withCity(yogyakarta, "jakarta"), want: model.DispatchModeManual},
+		{name: "city resolved from the pickup point", origin: withCity(jakartaCenter, "bandung"), want: model.DispatchModeBatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := dispatchConfig()
+			if tt.batchDisabled {
+				cfg.Set("dispatch.batch.enabled", false)
+			}
+			c := &UserUseCase{Log: quietLog, Config: cfg}
+			if got := c.resolveDispatchMode(tt.origin, tt.segment); got != tt.want {
+				t.Fatalf("resolveDispatchMode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankPickupOffers(t *testing.T) {
+	tests := []struct {
+		name      string
+		origin    model.LocationRequest
+		strategy  string
+		offers    []string
+		positions map[string]*redis.GeoPos
+		geoErr    error
+		queue     []string
+		want      []string
+	}{
+		{
+			name:   "single offer",
+			origin: jakartaCenter,
+			offers: []string{"d1"},
+			want:   []string{"d1"},
+		},
+		{
+			name:   "nearest first",
+			origin: jakartaCenter,
+			offers: []string{"d1", "d2", "d3"},
+			positions: map[string]*redis.GeoPos{
+				"d1": {Latitude: -6.245, Longitude: 106.82},
+				"d2": {Latitude: -6.209, Longitude: 106.82},
+				"d3": {Latitude: -6.227, Longitude: 106.82},
+			},
+			want: []string{"d2", "d3", "d1"},
+		},
+		{
+			name:   "unknown positions last in accept order",
+			origin: jakartaCenter,
+			offers: []string{"d1", "d2", "d3", "d4"},
+			positions: map[string]*redis.GeoPos{
+				"d2": {Latitude: -6.218, Longitude: 106.82},
+				"d4": {Latitude: -6.209, Longitude: 106.82},
+			},
+			want: []string{"d4", "d2", "d1", "d3"},
+		},
+		{
+			name:     "first accepted wins",
+			origin:   jakartaCenter,
+			strategy: "First",
+			offers:   []string{"d1", "d2"},
+			positions: map[string]*redis.GeoPos{
+				"d1": {Latitude: -6.245, Longitude: 106.82},
+				"d2": {Latitude: -6.209, Longitude: 106.82},
+			},
+			want: []string{"d1", "d2"},
+		},
+		{
+			name:   "positions unavailable",
+			origin: jakartaCenter,
+			offers: []string{"d1", "d2"},
+			geoErr: errors.New("connection refused"),
+			want:   []string{"d1", "d2"},
+		},
+		{
+			name:   "queue zone in queue order",
+			origin: gambir,
+			offers: []string{"d1", "d2", "d3", "d4"},
+			positions: map[string]*redis.GeoPos{
+				"d2": {Latitude: -6.176, Longitude: 106.83},
+			},
+			queue: []string{"d3", "d5", "d1"},
+			want:  []string{"d3", "d1", "d2", "d4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := dispatchConfig()
+			cfg.Set("dispatch.auto.strategy", tt.strategy)
+			rdb := &dispatchRedis{positions: tt.positions, geoErr: tt.geoErr, queue: tt.queue}
+			c := &UserUseCase{Log: quietLog, Config: cfg, Redis: rdb}
+			order := &entity.Order{OrderID: "order-1", OriginLat: tt.origin.Latitude, OriginLng: tt.origin.Longitude}
+
+			offers := append([]string(nil), tt.offers...)
+			got := c.rankPickupOffers(context.Background(), order, offers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("rankPickupOffers() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(offers, tt.offers) {
+				t.Fatalf("rankPickupOffers() reordered its input to %v", offers)
+			}
+		})
+	}
+}
+
+func withCity(location model.LocationRequest, city string) model.LocationRequest {
+	location.City = city
+	return location
+}
+
+// dispatchRedis answers the driver position and queue reads of dispatch, any
+// other command panics on the nil client.
+type dispatchRedis struct {
+	redis.UniversalClient
+	positions map[string]*redis.GeoPos
+	geoErr    error
+	queue     []string
+}
+
+func (r *dispatchRedis) GeoPos(ctx context.Context, key string, members ...string) *redis.GeoPosCmd {
+	positions := make([]*redis.GeoPos, len(members))
+	for i, member := range members {
+		positions[i] = r.positions[member]
+	}
+	return redis.NewGeoPosCmdResult(positions, r.geoErr)
+}
+
+func (r *dispatchRedis) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	if key != "QUEUE:ZONE:gambir" {
+		return redis.NewStringSliceResult(nil, nil)
+	}
+	return redis.NewStringSliceResult(r.queue, nil)
+}
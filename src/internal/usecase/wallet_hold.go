@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/model"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TypeHoldExpiry = "wallet:hold-expiry"
+)
+
+// ExpireHold expires an order still waiting for a driver at the matching timeout
+// and gives its wallet hold back to the passenger.
+func (c *UserUseCase) ExpireHold(ctx context.Context, t *asynq.Task) error {
+	var payload model.HoldExpiryTask
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Error unmarshalling task payload: %v", err), "ExpireHold", "")
+		return err
+	}
+
+	order, err := c.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &payload.OrderID, PassengerID: &payload.PassengerID})
+	if err == nil && order != nil {
+		switch order.Status {
+		case "REQUESTED", "MATCHING":
+			expired := false
+			err := c.OutboxRepository.WithinTx(ctx, func(ctx context.Context) error {
+				ok, err := c.OrderRepository.ExpireOrder(ctx, order.OrderID)
+				if err != nil || !ok {
+					return err
+				}
+				expired = true
+				return c.OrderEvents.StatusChanged(ctx, order.OrderID, order.Status, "EXPIRED")
+			})
+			if err != nil {
+				c.Log.WithContext(ctx).Error("user-usecase", fmt.Sprintf("Failed expire order: %v", err), "ExpireHold", order.OrderID)
+				return err
+			}
+			if !expired {
+				return nil
+			}
+		case "CANCELLED", "EXPIRED":
+		default:
+			// the hold is captured when the trip completes
+			return nil
+		}
+	}
+
+	method, ok := c.PaymentMethods.Lookup(PaymentMethodEWallet)
+	if !ok {
+		return nil
+	}
+	return method.Release(ctx, payload.OrderID)
+}
+
+// finalFare prices the trip on the actual distance at the planned price per km,
+// kept inside the quoted price range and scaled by the fare percentage the driver
+// charges.
+func finalFare(order *entity.Order, distanceActual, farePercentage float64) float64 {
+	fare := order.BestRoutePrice
+	if order.BestRouteKm > 0 && distanceActual > 0 {
+		fare = distanceActual * order.BestRoutePrice / order.BestRouteKm
+	}
+	fare = math.Max(fare, order.MinPrice)
+	if order.MaxPrice > 0 {
+		fare = math.Min(fare, order.MaxPrice)
+	}
+	if farePercentage > 0 && farePercentage < 100 {
+		fare = fare * farePercentage / 100
+	}
+	return math.Round(fare)
+}
@@ -0,0 +1,433 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"order-service/src/internal/entity"
+	"order-service/src/internal/gateway/payment"
+	"order-service/src/internal/model"
+	"order-service/src/internal/repository"
+	"order-service/src/pkg/constants"
+	httpError "order-service/src/pkg/http-error"
+	"order-service/src/pkg/log"
+	"order-service/src/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+type WalletUseCase struct {
+	Log              log.Log
+	Validate         *validator.Validate
+	WalletRepository *repository.WalletRepository
+	Config           *viper.Viper
+	Redis            redis.UniversalClient
+	PaymentProvider  payment.Provider
+}
+
+func NewWalletUseCase(
+	logger log.Log,
+	validate *validator.Validate,
+	walletRepository *repository.WalletRepository,
+	cfg *viper.Viper,
+	redisClient redis.UniversalClient,
+	paymentProvider payment.Provider,
+) *WalletUseCase {
+	return &WalletUseCase{
+		Log:              logger,
+		Validate:         validate,
+		WalletRepository: walletRepository,
+		Config:           cfg,
+		Redis:            redisClient,
+		PaymentProvider:  paymentProvider,
+	}
+}
+
+func (c *WalletUseCase) GetWallet(ctx context.Context, userID string) utils.Result {
+	var result utils.Result
+
+	wallet, err := c.WalletRepository.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "GetWallet", utils.ConvertString(err))
+		return result
+	}
+	if wallet == nil {
+		result.Error = walletNotFoundError()
+		return result
+	}
+
+	result.Data = toWalletResponse(wallet)
+	return result
+}
+
+func (c *WalletUseCase) CreateWallet(ctx context.Context, userID string) utils.Result {
+	var result utils.Result
+
+	wallet, created, err := c.WalletRepository.CreateWallet(ctx, userID)
+	if err != nil || wallet == nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed create wallet: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "CreateWallet", utils.ConvertString(err))
+		return result
+	}
+	if created {
+		c.Log.WithContext(ctx).Info("wallet-usecase", "Wallet created", "CreateWallet", userID)
+	}
+
+	result.Data = toWalletResponse(wallet)
+	return result
+}
+
+// TopUp charges the payment provider and credits the wallet. The idempotency key
+// names both the provider charge and the wallet transaction, so a retried request
+// never credits the wallet twice.
+func (c *WalletUseCase) TopUp(ctx context.Context, request *model.TopUpRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+	minAmount := c.Config.GetFloat64("wallet.topup.min_amount")
+	if minAmount <= 0 {
+		minAmount = 1000
+	}
+	maxAmount := c.Config.GetFloat64("wallet.topup.max_amount")
+	if maxAmount <= 0 {
+		maxAmount = 10000000
+	}
+	if request.Amount < minAmount || request.Amount > maxAmount {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("top up amount must be between %.0f and %.0f", minAmount, maxAmount)
+		result.Error = errObj
+		return result
+	}
+
+	wallet, err := c.WalletRepository.GetWalletByUserID(ctx, request.UserID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+	if wallet == nil {
+		result.Error = walletNotFoundError()
+		return result
+	}
+
+	// a concurrent request with the same key waits for the first one to finish
+	lockKey := fmt.Sprintf("WALLET:TOPUP-LOCK:%s:%s", request.UserID, request.IdempotencyKey)
+	locked, err := c.Redis.SetNX(ctx, lockKey, "1", 30*time.Second).Result()
+	if err != nil || !locked {
+		errObj := httpError.NewConflict()
+		errObj.Message = "A top up with this idempotency key is in progress"
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", request.IdempotencyKey)
+		return result
+	}
+	defer c.Redis.Del(ctx, lockKey)
+
+	transactionID := topUpTransactionID(request.UserID, request.IdempotencyKey)
+	// top ups credited before the key was hashed keep their raw key id
+	legacyID := fmt.Sprintf("TOPUP-%s-%s", request.UserID, request.IdempotencyKey)
+	legacy, err := c.WalletRepository.GetTransaction(ctx, legacyID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet transaction: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+	if legacy != nil {
+		transactionID = legacyID
+	}
+	charge, err := c.PaymentProvider.Charge(ctx, &payment.ChargeRequest{
+		Reference: transactionID,
+		UserID:    request.UserID,
+		Amount:    request.Amount,
+	})
+	if err != nil || charge.Status == payment.StatusFailed {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "Top up was declined by the payment provider"
+		if err != nil && !errors.Is(err, payment.ErrChargeDeclined) {
+			errObj.Message = fmt.Sprintf("Top up failed: %v", err)
+		}
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+	if charge.Status != payment.StatusSucceeded {
+		errObj := httpError.NewConflict()
+		errObj.Message = "Top up is waiting for the payment provider, retry with the same idempotency key"
+		result.Error = errObj
+		return result
+	}
+
+	credited, err := c.WalletRepository.TopUp(ctx, request.UserID, transactionID, request.Amount)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed top up wallet: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+
+	wallet, err = c.WalletRepository.GetWalletByUserID(ctx, request.UserID)
+	if err != nil || wallet == nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "TopUp", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = model.TopUpResponse{
+		Wallet:            toWalletResponse(wallet),
+		TransactionID:     transactionID,
+		Provider:          charge.Provider,
+		ProviderReference: charge.ProviderReference,
+		Replayed:          !credited,
+	}
+	return result
+}
+
+// topUpTransactionID is the wallet transaction id of a top up, also used as its
+// charge reference. The idempotency key is hashed like a settlement's so the id
+// keeps a fixed length whatever key the client sends.
+func topUpTransactionID(userID, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(userID + ":" + idempotencyKey))
+	return "TOPUP-" + hex.EncodeToString(sum[:20])
+}
+
+// walletTransactionTypes maps the history filters to the stored transaction types.
+var walletTransactionTypes = map[string][]string{
+	"credit": {"credit", "release", "refund", "settlement", "payout"},
+	"debit":  {"debit", "capture", "commission"},
+	"hold":   {"hold"},
+	"refund": {"refund"},
+}
+
+func (c *WalletUseCase) GetTransactions(ctx context.Context, request *model.WalletTransactionRequest) utils.Result {
+	var result utils.Result
+
+	if err := c.Validate.Struct(request); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("validation error: %v", err.Error())
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "GetTransactions", utils.ConvertString(err))
+		return result
+	}
+
+	filter := entity.WalletTransactionFilter{UserID: request.UserID}
+	if request.Type != "" {
+		for _, t := range strings.Split(strings.ToLower(request.Type), ",") {
+			types, ok := walletTransactionTypes[strings.TrimSpace(t)]
+			if !ok {
+				errObj := httpError.NewBadRequest()
+				errObj.Message = fmt.Sprintf("invalid type %q, allowed: credit, debit, hold, refund", t)
+				result.Error = errObj
+				return result
+			}
+			filter.Types = append(filter.Types, types...)
+		}
+	}
+	var err error
+	if filter.From, err = parseDate(request.From, 0); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid from date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+	// the to date is inclusive, the query bound is the start of the next day
+	if filter.To, err = parseDate(request.To, 1); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "invalid to date, use YYYY-MM-DD"
+		result.Error = errObj
+		return result
+	}
+
+	page := request.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	transactions, total, err := c.WalletRepository.FindTransactions(ctx, filter)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet transactions: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "GetTransactions", utils.ConvertString(err))
+		return result
+	}
+
+	items := make([]model.WalletTransactionResponse, 0, len(transactions))
+	for _, transaction := range transactions {
+		items = append(items, toWalletTransactionResponse(transaction))
+	}
+	result.Data = model.WalletTransactionPage{
+		Transactions: items,
+		Meta: constants.MetaData{
+			Page:      page,
+			Count:     int64(len(items)),
+			TotalPage: (total + limit - 1) / limit,
+			TotalData: total,
+		},
+	}
+	return result
+}
+
+// ExportStatement renders the transactions of one month as a CSV statement.
+func (c *WalletUseCase) ExportStatement(ctx context.Context, request *model.WalletStatementRequest) utils.Result {
+	var result utils.Result
+
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if request.Month != "" {
+		parsed, err := time.ParseInLocation("2006-01", request.Month, now.Location())
+		if err != nil {
+			errObj := httpError.NewBadRequest()
+			errObj.Message = "invalid month, use YYYY-MM"
+			result.Error = errObj
+			return result
+		}
+		month = parsed
+	}
+	end := month.AddDate(0, 1, 0)
+
+	transactions, _, err := c.WalletRepository.FindTransactions(ctx, entity.WalletTransactionFilter{
+		UserID: request.UserID,
+		From:   &month,
+		To:     &end,
+	})
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed get wallet transactions: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "ExportStatement", utils.ConvertString(err))
+		return result
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"date", "transaction_id", "type", "direction", "amount", "description", "order_id"})
+	// the statement lists the oldest transaction first
+	for i := len(transactions) - 1; i >= 0; i-- {
+		item := toWalletTransactionResponse(transactions[i])
+		_ = writer.Write([]string{
+			item.CreatedAt.Format(time.RFC3339),
+			item.TransactionID,
+			item.Type,
+			item.Direction,
+			strconv.FormatFloat(item.Amount, 'f', 2, 64),
+			item.Description,
+			item.OrderID,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("Failed write statement: %v", err)
+		result.Error = errObj
+		c.Log.WithContext(ctx).Error("wallet-usecase", errObj.Message, "ExportStatement", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = model.WalletStatement{
+		FileName: fmt.Sprintf("wallet-statement-%s.csv", month.Format("2006-01")),
+		Content:  buf.Bytes(),
+	}
+	return result
+}
+
+func toWalletTransactionResponse(transaction entity.WalletTransaction) model.WalletTransactionResponse {
+	direction := transaction.Type
+	switch transaction.Type {
+	case "credit", "release", "refund", "settlement", "payout":
+		direction = "credit"
+	case "debit", "capture", "commission":
+		direction = "debit"
+	}
+	item := model.WalletTransactionResponse{
+		TransactionID: transaction.TransactionID,
+		Type:          transaction.Type,
+		Direction:     direction,
+		Amount:        transaction.Amount,
+		Description:   transaction.Description,
+		CreatedAt:     transaction.CreatedAt,
+	}
+	if transaction.OrderID != nil && *transaction.OrderID != "" {
+		item.OrderID = *transaction.OrderID
+		item.OrderLink = fmt.Sprintf("/users/v1/order-status/%s", *transaction.OrderID)
+	}
+	return item
+}
+
+func parseDate(value string, addDays int) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	date = date.AddDate(0, 0, addDays)
+	return &date, nil
+}
+
+func toWalletResponse(wallet *entity.Wallet) model.WalletResponse {
+	return model.WalletResponse{
+		WalletID:    wallet.ID,
+		UserID:      wallet.UserID,
+		Balance:     wallet.Balance,
+		HeldBalance: wallet.HeldBalance,
+		LastUpdated: wallet.LastUpdated,
+	}
+}
+
+func walletNotFoundError() httpError.NotFoundData {
+	errObj := httpError.NewNotFound()
+	errObj.Message = "Wallet not found, please create a wallet first"
+	errObj.Data = model.WalletAction{
+		Action:   model.WalletActionCreate,
+		Endpoint: "POST /wallets/v1",
+	}
+	return errObj
+}
+
+func insufficientBalanceError(required, balance float64) httpError.BadRequestData {
+	errObj := httpError.NewBadRequest()
+	errObj.Message = "insufficient balance, please topup"
+	errObj.Data = model.WalletAction{
+		Action:   model.WalletActionTopUp,
+		Endpoint: "POST /wallets/v1/top-up",
+		Required: required,
+		Balance:  balance,
+	}
+	return errObj
+}
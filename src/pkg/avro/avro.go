@@ -0,0 +1,281 @@
+// Package avro derives Avro schemas from Go structs and writes values in the
+// Avro binary encoding. Field names follow the avro tag, or the json tag when
+// there is none, so a struct published as JSON keeps its field names as Avro.
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Record is the schema of an Avro record, it marshals to the JSON schema
+// definition.
+type Record struct {
+	Type      string  `json:"type"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace,omitempty"`
+	Fields    []Field `json:"fields"`
+}
+
+type Field struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+type field struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	nullValue = json.RawMessage("null")
+)
+
+// RecordOf returns the record schema of a struct value. Nested structs become
+// named records, a pointer becomes a union with null.
+func RecordOf(v interface{}, namespace string) (*Record, error) {
+	t := indirect(reflect.TypeOf(v))
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("avro: %T is not a struct", v)
+	}
+	b := &builder{namespace: namespace, named: make(map[string]reflect.Type)}
+	return b.record(t, t.Name())
+}
+
+// Marshal writes a struct value in the binary encoding of its RecordOf schema.
+func Marshal(v interface{}) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, fmt.Errorf("avro: cannot marshal nil %T", v)
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("avro: %T is not a struct", v)
+	}
+	return appendValue(nil, value)
+}
+
+type builder struct {
+	namespace string
+	named     map[string]reflect.Type
+}
+
+func (b *builder) record(t reflect.Type, name string) (*Record, error) {
+	if defined, ok := b.named[name]; ok && defined != t {
+		return nil, fmt.Errorf("avro: record name %s is used by %s and %s", name, defined, t)
+	}
+	b.named[name] = t
+	record := &Record{Type: "record", Name: name, Namespace: b.namespace}
+	for _, f := range fields(t) {
+		schema, err := b.schema(f.typ, name+exportName(f.name))
+		if err != nil {
+			return nil, fmt.Errorf("avro: field %s.%s: %w", name, f.name, err)
+		}
+		entry := Field{Name: f.name, Type: schema}
+		if f.typ.Kind() == reflect.Ptr {
+			entry.Default = nullValue
+		}
+		record.Fields = append(record.Fields, entry)
+	}
+	return record, nil
+}
+
+// schema returns the schema of t, name is used when t is an anonymous struct.
+func (b *builder) schema(t reflect.Type, name string) (interface{}, error) {
+	if t == timeType {
+		return map[string]string{"type": "long", "logicalType": "timestamp-millis"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema, err := b.schema(t.Elem(), name)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{"null", schema}, nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int", nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "long", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes", nil
+		}
+		items, err := b.schema(t.Elem(), name+"Item")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key %s is not a string", t.Key())
+		}
+		values, err := b.schema(t.Elem(), name+"Value")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "map", "values": values}, nil
+	case reflect.Struct:
+		if t.Name() != "" {
+			name = t.Name()
+		}
+		if defined, ok := b.named[name]; ok && defined == t {
+			// a record is defined once and referenced by name after that
+			return name, nil
+		}
+		return b.record(t, name)
+	default:
+		return nil, fmt.Errorf("type %s has no avro schema", t)
+	}
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Type() == timeType {
+		return binary.AppendVarint(buf, v.Interface().(time.Time).UnixMilli()), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return binary.AppendVarint(buf, 0), nil
+		}
+		return appendValue(binary.AppendVarint(buf, 1), v.Elem())
+	case reflect.String:
+		return appendBytes(buf, []byte(v.String())), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendVarint(buf, int64(v.Uint())), nil
+	case reflect.Float32:
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(raw), v)
+			return appendBytes(buf, raw), nil
+		}
+		var err error
+		if v.Len() > 0 {
+			buf = binary.AppendVarint(buf, int64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				if buf, err = appendValue(buf, v.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return binary.AppendVarint(buf, 0), nil
+	case reflect.Map:
+		var err error
+		if v.Len() > 0 {
+			buf = binary.AppendVarint(buf, int64(v.Len()))
+			iter := v.MapRange()
+			for iter.Next() {
+				buf = appendBytes(buf, []byte(iter.Key().String()))
+				if buf, err = appendValue(buf, iter.Value()); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return binary.AppendVarint(buf, 0), nil
+	case reflect.Struct:
+		var err error
+		for _, f := range fields(v.Type()) {
+			if buf, err = appendValue(buf, fieldByIndex(v, f.index)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("avro: cannot marshal %s", v.Type())
+	}
+}
+
+func appendBytes(buf, raw []byte) []byte {
+	return append(binary.AppendVarint(buf, int64(len(raw))), raw...)
+}
+
+// fieldByIndex follows embedded struct pointers, a nil one reads as zero.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Zero(v.Type().Elem().FieldByIndex(index[i:]).Type)
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fields lists the encoded fields of a struct in declaration order, the fields
+// of an untagged embedded struct are promoted like encoding/json does.
+func fields(t reflect.Type) []field {
+	var list []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, ok := sf.Tag.Lookup("avro")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && indirect(sf.Type).Kind() == reflect.Struct {
+			for _, promoted := range fields(indirect(sf.Type)) {
+				promoted.index = append([]int{i}, promoted.index...)
+				list = append(list, promoted)
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		list = append(list, field{name: name, index: []int{i}, typ: sf.Type})
+	}
+	return list
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// exportName turns a field name like best_route_km into BestRouteKm for the
+// name of an anonymous nested record.
+func exportName(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
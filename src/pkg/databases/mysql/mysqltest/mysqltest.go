@@ -0,0 +1,218 @@
+// Package mysqltest runs code written against mysql.DBInterface on a scripted
+// database/sql driver. The test answers every statement from Go and inspects the
+// statements afterwards, no MySQL server is needed.
+package mysqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"order-service/src/pkg/databases/mysql"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Result answers one statement. A query returns Rows under Columns, an exec
+// reports RowsAffected and LastInsertID.
+type Result struct {
+	Columns      []string
+	Rows         [][]interface{}
+	RowsAffected int64
+	LastInsertID int64
+	Err          error
+}
+
+// Statement is a statement the code ran, Query has its whitespace collapsed.
+type Statement struct {
+	Query string
+	Args  []interface{}
+	// Tx is the number of the transaction the statement ran in, 0 outside one
+	Tx int
+}
+
+// Handler answers a statement, query has its whitespace collapsed.
+type Handler func(query string, args []interface{}) Result
+
+// DB is a mysql.DBInterface backed by a Handler.
+type DB struct {
+	db      *sqlx.DB
+	handler Handler
+
+	mu         sync.Mutex
+	statements []Statement
+	txs        int
+	commits    int
+	rollbacks  int
+}
+
+// New returns a database answering every statement with handler.
+func New(handler Handler) *DB {
+	d := &DB{handler: handler}
+	d.db = sqlx.NewDb(sql.OpenDB(connector{d}), "mysql")
+	return d
+}
+
+func (d *DB) Connect(string) *mysql.DatabaseConnection {
+	return &mysql.DatabaseConnection{Connection: d.db}
+}
+
+func (d *DB) GetDB() (*sqlx.DB, error) {
+	return d.db, nil
+}
+
+// Statements returns the statements run so far whose query contains every part.
+func (d *DB) Statements(parts ...string) []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var matched []Statement
+	for _, statement := range d.statements {
+		if Contains(statement.Query, parts...) {
+			matched = append(matched, statement)
+		}
+	}
+	return matched
+}
+
+// Commits returns how many transactions were committed and rolled back.
+func (d *DB) Commits() (commits, rollbacks int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commits, d.rollbacks
+}
+
+// Contains reports whether query contains every part, handlers use it to tell
+// statements apart.
+func Contains(query string, parts ...string) bool {
+	for _, part := range parts {
+		if !strings.Contains(query, part) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *DB) run(tx int, query string, named []driver.NamedValue) Result {
+	query = strings.Join(strings.Fields(query), " ")
+	args := make([]interface{}, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	d.mu.Lock()
+	d.statements = append(d.statements, Statement{Query: query, Args: args, Tx: tx})
+	d.mu.Unlock()
+	return d.handler(query, args)
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return scriptedDriver{}
+}
+
+type scriptedDriver struct{}
+
+func (scriptedDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type conn struct {
+	db *DB
+	tx int
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.db.txs++
+	c.tx = c.db.txs
+	c.db.mu.Unlock()
+	return c, nil
+}
+
+func (c *conn) Commit() error {
+	c.db.mu.Lock()
+	c.db.commits++
+	c.db.mu.Unlock()
+	c.tx = 0
+	return nil
+}
+
+func (c *conn) Rollback() error {
+	c.db.mu.Lock()
+	c.db.rollbacks++
+	c.db.mu.Unlock()
+	c.tx = 0
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.db.run(c.tx, query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return execResult{result}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.run(c.tx, query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{columns: result.Columns, values: result.Rows}, nil
+}
+
+type execResult struct {
+	result Result
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return r.result.LastInsertID, nil
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.result.RowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]interface{}
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	for i, value := range r.values[0] {
+		dest[i] = value
+	}
+	r.values = r.values[1:]
+	return nil
+}
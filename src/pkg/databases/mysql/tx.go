@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type txKey struct{}
+
+type (
+	// Executor is the part of sqlx shared by the connection pool and a transaction
+	Executor interface {
+		sqlx.ExtContext
+		GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+		SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	}
+
+	// Tx is a transaction returned by BeginTx, Commit and Rollback are left to the
+	// owner when it joined the transaction carried by the context
+	Tx struct {
+		*sqlx.Tx
+		joined bool
+	}
+)
+
+// WithinTx runs fn in one transaction. Repositories called with the context fn
+// receives write through that transaction, a nested call joins it.
+func WithinTx(ctx context.Context, db DBInterface, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+	conn, err := db.GetDB()
+	if err != nil {
+		return err
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Conn returns the transaction carried by ctx, or the connection pool outside one.
+func Conn(ctx context.Context, db DBInterface) (Executor, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx, nil
+	}
+	return db.GetDB()
+}
+
+// BeginTx joins the transaction carried by ctx or starts a new one.
+func BeginTx(ctx context.Context, db DBInterface) (*Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return &Tx{Tx: tx, joined: true}, nil
+	}
+	conn, err := db.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+func (t *Tx) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	if t.joined {
+		// the error reaches the owner, which rolls the whole transaction back
+		return nil
+	}
+	return t.Tx.Rollback()
+}
@@ -1,31 +1,155 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"order-service/src/pkg/log"
+	"order-service/src/pkg/tracing"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
 
+// Header keys added to a message sent to the dead letter topic.
+const (
+	HeaderDeadLetterTopic     = "dlq.topic"
+	HeaderDeadLetterPartition = "dlq.partition"
+	HeaderDeadLetterOffset    = "dlq.offset"
+	HeaderDeadLetterError     = "dlq.error"
+	HeaderDeadLetterAttempts  = "dlq.attempts"
+)
+
+// ConsumerOptions tune the consumer runtime, a zero value takes the default.
+type ConsumerOptions struct {
+	// Retries is how often a failed message is handled again before it goes to
+	// the dead letter topic, default 3.
+	Retries int
+	// Backoff is the wait before the first retry, doubled on every retry up to
+	// MaxBackoff, default 1s and 30s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DeadLetterProducer publishes the messages that failed every attempt to their
+	// topic suffixed with DeadLetterSuffix, default ".dlq". Without a producer the
+	// message is logged and skipped.
+	DeadLetterProducer Producer
+	DeadLetterSuffix   string
+	// QueueSize is the number of messages buffered per partition before the
+	// partition is paused, default 100.
+	QueueSize int
+	// CommitInterval is how often the offsets of handled messages are committed,
+	// default 1s.
+	CommitInterval time.Duration
+}
+
+func (o *ConsumerOptions) setDefaults() {
+	if o.Retries < 0 {
+		o.Retries = 0
+	} else if o.Retries == 0 {
+		o.Retries = 3
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.DeadLetterSuffix == "" {
+		o.DeadLetterSuffix = ".dlq"
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	if o.CommitInterval <= 0 {
+		o.CommitInterval = time.Second
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that no retry can fix, the message goes to the
+// dead letter topic right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionWorker handles the messages of one partition in order.
+type partitionWorker struct {
+	key   partitionKey
+	queue chan *kafka.Message
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu        sync.Mutex
+	next      kafka.Offset // offset after the last finished message
+	committed kafka.Offset
+
+	// read by the poll loop only
+	paused   bool
+	pausedAt kafka.Offset
+}
+
+func (w *partitionWorker) finish(message *kafka.Message) {
+	w.mu.Lock()
+	w.next = message.TopicPartition.Offset + 1
+	w.mu.Unlock()
+}
+
+// delivery hands a message to the handler with the retries and dead letter
+// topic of the options, every consumer backend settles messages through it.
+type delivery struct {
+	handler ConsumerHandler
+	options ConsumerOptions
+	logger  log.Log
+}
+
 type consumer struct {
-	sync.Mutex
 	handler  ConsumerHandler
 	consumer *kafka.Consumer
+	options  ConsumerOptions
 	logger   log.Log
+
+	// workers is touched by the poll loop and the rebalance callback it runs
+	workers map[partitionKey]*partitionWorker
+	ctx     context.Context
 }
 
-// NewConsumer is a constructor of kafka consumer
-func NewConsumer(config *kafka.ConfigMap, log log.Log) (Consumer, error) {
-	c, err := kafka.NewConsumer(config)
+// NewConsumer is a constructor of kafka consumer. Offsets are committed by the
+// consumer once a message is handled, auto commit is turned off.
+func NewConsumer(config *kafka.ConfigMap, options ConsumerOptions, log log.Log) (Consumer, error) {
+	cfg := kafka.ConfigMap{}
+	for key, value := range *config {
+		cfg[key] = value
+	}
+	cfg["enable.auto.commit"] = false
+	cfg["enable.auto.offset.store"] = false
+
+	c, err := kafka.NewConsumer(&cfg)
 	if err != nil {
 		return nil, err
 	}
+	options.setDefaults()
 
 	return &consumer{
 		logger:   log,
 		consumer: c,
+		options:  options,
+		workers:  make(map[partitionKey]*partitionWorker),
 	}, nil
 }
 
@@ -33,33 +157,275 @@ func (c *consumer) SetHandler(handler ConsumerHandler) {
 	c.handler = handler
 }
 
-func (c *consumer) Subscribe(topics ...string) {
+// Subscribe consumes the topics until ctx is done. Each partition gets a worker
+// so partitions are handled concurrently and a partition in order. On stop the
+// in-flight messages finish, their offsets are committed and the consumer leaves
+// the group.
+func (c *consumer) Subscribe(ctx context.Context, topics ...string) error {
+	joinTopic := strings.Join(topics, ", ")
 	if c.handler == nil {
-		joinTopic := strings.Join(topics, ", ")
 		msg := fmt.Sprintf("Kafka Consumer Error: Topics: [%s] There is no consumer handler to handle message from incoming event", joinTopic)
-		c.logger.Error("", msg, "", "")
-		return
+		c.logger.Error("kafka-consumer", msg, "Subscribe", "")
+		return errors.New(msg)
+	}
+	// handlers finish the message they hold when the consumer stops
+	c.ctx = context.WithoutCancel(ctx)
+	if err := c.consumer.SubscribeTopics(topics, c.rebalance); err != nil {
+		return err
 	}
-	var wg sync.WaitGroup
-	var mtx sync.Mutex
+	c.logger.Info("kafka-consumer", fmt.Sprintf("Consuming topics [%s]", joinTopic), "Subscribe", "")
 
-	c.consumer.SubscribeTopics(topics, nil)
-	go func() {
-		for {
-			wg.Add(1)
+	ticker := time.NewTicker(c.options.CommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.stopWorkers(c.assigned(), true)
+			c.logger.Info("kafka-consumer", fmt.Sprintf("Stopped consuming topics [%s]", joinTopic), "Subscribe", "")
+			return c.consumer.Close()
+		case <-ticker.C:
+			c.commit(c.assigned())
+		default:
+		}
+		c.resume()
 
-			msg, err := c.consumer.ReadMessage(-1)
-			if err != nil {
-				msg := fmt.Sprintf("Kafka Consumer Error: %v (%v)\n", err, msg)
-				c.logger.Error("", msg, "", "")
+		switch ev := c.consumer.Poll(100).(type) {
+		case *kafka.Message:
+			if ev.TopicPartition.Error != nil {
+				c.logger.Error("kafka-consumer", fmt.Sprintf("Kafka Consumer Error: %v", ev.TopicPartition.Error), "Subscribe", ev.TopicPartition.String())
 				continue
 			}
-			mtx.Lock()
-			c.handler.HandleMessage(msg)
-			mtx.Unlock()
-			wg.Done()
-			c.consumer.CommitMessage(msg)
-		}
-	}()
-	wg.Wait()
+			c.dispatch(ev)
+		case kafka.Error:
+			c.logger.Error("kafka-consumer", fmt.Sprintf("Kafka Consumer Error: %v", ev), "Subscribe", ev.Code().String())
+			if ev.IsFatal() {
+				c.stopWorkers(c.assigned(), false)
+				c.consumer.Close()
+				return ev
+			}
+		}
+	}
+}
+
+// rebalance runs inside Poll. Revoked partitions finish their in-flight message
+// and commit before another member gets them, a lost assignment is not committed
+// since the partitions already belong to someone else.
+func (c *consumer) rebalance(_ *kafka.Consumer, event kafka.Event) error {
+	switch ev := event.(type) {
+	case kafka.AssignedPartitions:
+		c.logger.Info("kafka-consumer", fmt.Sprintf("Assigned partitions %v", ev.Partitions), "rebalance", "")
+	case kafka.RevokedPartitions:
+		keys := make([]partitionKey, 0, len(ev.Partitions))
+		for _, tp := range ev.Partitions {
+			keys = append(keys, partitionKey{topic: *tp.Topic, partition: tp.Partition})
+		}
+		c.stopWorkers(keys, !c.consumer.AssignmentLost())
+		c.logger.Info("kafka-consumer", fmt.Sprintf("Revoked partitions %v", ev.Partitions), "rebalance", "")
+	}
+	// the client assigns and unassigns after the callback
+	return nil
+}
+
+func (c *consumer) assigned() []partitionKey {
+	keys := make([]partitionKey, 0, len(c.workers))
+	for key := range c.workers {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// dispatch queues the message on the worker of its partition. A full queue
+// pauses the partition, the message is fetched again once the worker caught up.
+func (c *consumer) dispatch(message *kafka.Message) {
+	key := partitionKey{topic: *message.TopicPartition.Topic, partition: message.TopicPartition.Partition}
+	w, ok := c.workers[key]
+	if !ok {
+		w = &partitionWorker{
+			key:       key,
+			queue:     make(chan *kafka.Message, c.options.QueueSize),
+			stop:      make(chan struct{}),
+			done:      make(chan struct{}),
+			next:      kafka.OffsetInvalid,
+			committed: kafka.OffsetInvalid,
+		}
+		c.workers[key] = w
+		go c.work(w)
+	}
+	if w.paused {
+		// fetched before the pause took effect
+		return
+	}
+	select {
+	case w.queue <- message:
+	default:
+		if err := c.consumer.Pause([]kafka.TopicPartition{message.TopicPartition}); err != nil {
+			c.logger.Error("kafka-consumer", fmt.Sprintf("Failed pause partition: %v", err), "dispatch", message.TopicPartition.String())
+		}
+		w.paused = true
+		w.pausedAt = message.TopicPartition.Offset
+	}
+}
+
+// resume rewinds paused partitions to the first dropped message once their
+// queue is half empty.
+func (c *consumer) resume() {
+	for _, w := range c.workers {
+		if !w.paused || len(w.queue) > cap(w.queue)/2 {
+			continue
+		}
+		tp := kafka.TopicPartition{Topic: &w.key.topic, Partition: w.key.partition, Offset: w.pausedAt}
+		if err := c.consumer.Seek(tp, 0); err != nil {
+			c.logger.Error("kafka-consumer", fmt.Sprintf("Failed seek partition: %v", err), "resume", tp.String())
+			continue
+		}
+		if err := c.consumer.Resume([]kafka.TopicPartition{tp}); err != nil {
+			c.logger.Error("kafka-consumer", fmt.Sprintf("Failed resume partition: %v", err), "resume", tp.String())
+			continue
+		}
+		w.paused = false
+	}
+}
+
+func (c *consumer) stopWorkers(keys []partitionKey, commit bool) {
+	var stopped []partitionKey
+	for _, key := range keys {
+		if w, ok := c.workers[key]; ok {
+			close(w.stop)
+			stopped = append(stopped, key)
+		}
+	}
+	for _, key := range stopped {
+		<-c.workers[key].done
+	}
+	if commit {
+		c.commit(stopped)
+	}
+	for _, key := range stopped {
+		delete(c.workers, key)
+	}
+}
+
+// commit stores the offsets the workers of the partitions moved past since the
+// last commit.
+func (c *consumer) commit(keys []partitionKey) {
+	var offsets []kafka.TopicPartition
+	var workers []*partitionWorker
+	for _, key := range keys {
+		w, ok := c.workers[key]
+		if !ok {
+			continue
+		}
+		w.mu.Lock()
+		if w.next != kafka.OffsetInvalid && w.next != w.committed {
+			topic := w.key.topic
+			offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: w.key.partition, Offset: w.next})
+			workers = append(workers, w)
+		}
+		w.mu.Unlock()
+	}
+	if len(offsets) == 0 {
+		return
+	}
+	if _, err := c.consumer.CommitOffsets(offsets); err != nil {
+		c.logger.Error("kafka-consumer", fmt.Sprintf("Failed commit offsets: %v", err), "commit", fmt.Sprint(offsets))
+		return
+	}
+	for i, w := range workers {
+		w.mu.Lock()
+		w.committed = offsets[i].Offset
+		w.mu.Unlock()
+	}
+}
+
+func (c *consumer) work(w *partitionWorker) {
+	defer close(w.done)
+	d := &delivery{handler: c.handler, options: c.options, logger: c.logger}
+	for {
+		select {
+		case <-w.stop:
+			return
+		case message := <-w.queue:
+			if d.process(c.ctx, w.stop, message) {
+				w.finish(message)
+			}
+		}
+	}
+}
+
+// process handles the message with retries, then hands it to the dead letter
+// topic. It reports false when stop closed before the message was settled, so
+// the offset stays uncommitted and the message is read again. The handler gets
+// the trace of the message in its context.
+func (d *delivery) process(ctx context.Context, stop <-chan struct{}, message *kafka.Message) bool {
+	ctx = tracing.WithTrace(ctx, ExtractTrace(message))
+	logger := d.logger.WithContext(ctx)
+	backoff := d.options.Backoff
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		err = d.handler.HandleMessage(ctx, message)
+		if err == nil {
+			return true
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempts > d.options.Retries {
+			break
+		}
+		logger.Error("kafka-consumer", fmt.Sprintf("Failed handle message, retry %d in %s: %v", attempts, backoff, err), "process", message.TopicPartition.String())
+		if !sleep(stop, backoff) {
+			return false
+		}
+		backoff = min(backoff*2, d.options.MaxBackoff)
+	}
+	return d.deadLetter(stop, logger, message, err, attempts)
+}
+
+func (d *delivery) deadLetter(stop <-chan struct{}, logger log.Log, message *kafka.Message, cause error, attempts int) bool {
+	meta := message.TopicPartition.String()
+	if d.options.DeadLetterProducer == nil {
+		logger.Error("kafka-consumer", fmt.Sprintf("Skipped message after %d attempts, no dead letter producer: %v", attempts, cause), "deadLetter", meta)
+		return true
+	}
+
+	topic := *message.TopicPartition.Topic + d.options.DeadLetterSuffix
+	headers := append([]kafka.Header{}, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDeadLetterTopic, Value: []byte(*message.TopicPartition.Topic)},
+		kafka.Header{Key: HeaderDeadLetterPartition, Value: []byte(fmt.Sprint(message.TopicPartition.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOffset, Value: []byte(message.TopicPartition.Offset.String())},
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDeadLetterAttempts, Value: []byte(fmt.Sprint(attempts))},
+	)
+	backoff := d.options.Backoff
+	for {
+		err := d.options.DeadLetterProducer.Publish(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Key:            message.Key,
+			Value:          message.Value,
+			Headers:        headers,
+		})
+		if err == nil {
+			logger.Error("kafka-consumer", fmt.Sprintf("Sent message to %s after %d attempts: %v", topic, attempts, cause), "deadLetter", meta)
+			return true
+		}
+		// the partition holds until the message is parked, skipping it loses it
+		logger.Error("kafka-consumer", fmt.Sprintf("Failed send message to %s: %v", topic, err), "deadLetter", meta)
+		if !sleep(stop, backoff) {
+			return false
+		}
+		backoff = min(backoff*2, d.options.MaxBackoff)
+	}
+}
+
+// sleep waits for d and reports false when stop closed first.
+func sleep(stop <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
 }
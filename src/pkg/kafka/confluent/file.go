@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// FileSink writes every published message as a JSON line, to stdout or a file.
+// It only produces, nothing consumes from it.
+type FileSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+type sinkRecord struct {
+	Topic     string            `json:"topic"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Value     json.RawMessage   `json:"value,omitempty"`
+	Raw       []byte            `json:"raw,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// NewFileSink appends to the file at path, an empty path or "-" writes to stdout.
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" || path == "-" {
+		return &FileSink{writer: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{writer: file}, nil
+}
+
+func (s *FileSink) Publish(message *kafka.Message) error {
+	if message.TopicPartition.Topic == nil || *message.TopicPartition.Topic == "" {
+		return errors.New("file sink: message has no topic")
+	}
+	record := sinkRecord{
+		Topic:     *message.TopicPartition.Topic,
+		Key:       string(message.Key),
+		Timestamp: time.Now(),
+	}
+	// JSON values stay readable, anything else is kept as base64
+	if utf8.Valid(message.Value) && json.Valid(message.Value) {
+		record.Value = message.Value
+	} else {
+		record.Raw = message.Value
+	}
+	if len(message.Headers) > 0 {
+		record.Headers = make(map[string]string, len(message.Headers))
+		for _, header := range message.Headers {
+			record.Headers[header.Key] = string(header.Value)
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) PublishChannel(topic string, message []byte) {
+	_ = s.Publish(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          message,
+	})
+}
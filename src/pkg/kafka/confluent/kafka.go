@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"encoding/base64"
 
 	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
@@ -13,11 +14,22 @@ type Producer interface {
 
 type Consumer interface {
 	SetHandler(handler ConsumerHandler)
-	Subscribe(topics ...string)
+	// Subscribe blocks consuming the topics until ctx is done
+	Subscribe(ctx context.Context, topics ...string) error
 }
 
+// ConsumerHandler handles one message, an error retries it and after the last
+// retry sends it to the dead letter topic. Wrap an error with Permanent to skip
+// the retries.
 type ConsumerHandler interface {
-	HandleMessage(message *k.Message)
+	HandleMessage(ctx context.Context, message *k.Message) error
+}
+
+// ConsumerHandlerFunc adapts a function to a ConsumerHandler.
+type ConsumerHandlerFunc func(ctx context.Context, message *k.Message) error
+
+func (f ConsumerHandlerFunc) HandleMessage(ctx context.Context, message *k.Message) error {
+	return f(ctx, message)
 }
 
 type KafkaConfig struct {
@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/pkg/log"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// MemoryBus is a broker inside the process: every topic is one partition kept in
+// memory and its consumers form one group. Like the partition of a Kafka group,
+// a topic is assigned to one consumer at a time and handed to another one when
+// it stops, from the last committed offset. Nothing survives a restart, it is
+// meant for tests and for running the service without a broker.
+type MemoryBus struct {
+	mu        sync.Mutex
+	topics    map[string][]*kafka.Message
+	committed map[string]int
+	owners    map[string]*memoryConsumer
+	// changed is closed and replaced on every publish and every topic released
+	// to wake the consumers
+	changed chan struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics:    make(map[string][]*kafka.Message),
+		committed: make(map[string]int),
+		owners:    make(map[string]*memoryConsumer),
+		changed:   make(chan struct{}),
+	}
+}
+
+func (b *MemoryBus) Publish(message *kafka.Message) error {
+	if message.TopicPartition.Topic == nil || *message.TopicPartition.Topic == "" {
+		return errors.New("memory bus: message has no topic")
+	}
+	topic := *message.TopicPartition.Topic
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stored := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(len(b.topics[topic]))},
+		Key:            append([]byte(nil), message.Key...),
+		Value:          append([]byte(nil), message.Value...),
+		Headers:        append([]kafka.Header(nil), message.Headers...),
+		Timestamp:      time.Now(),
+	}
+	b.topics[topic] = append(b.topics[topic], stored)
+	b.wake()
+	return nil
+}
+
+func (b *MemoryBus) PublishChannel(topic string, message []byte) {
+	_ = b.Publish(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          message,
+	})
+}
+
+// Messages returns the messages published to the topic so far.
+func (b *MemoryBus) Messages(topic string) []*kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*kafka.Message(nil), b.topics[topic]...)
+}
+
+// wake signals the consumers waiting for a change, b.mu must be held.
+func (b *MemoryBus) wake() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// next returns the message of the topic at offset, with the channel closed on
+// the next publish when there is none yet.
+func (b *MemoryBus) next(topic string, offset int) (*kafka.Message, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if offset < len(b.topics[topic]) {
+		return b.topics[topic][offset], nil
+	}
+	return nil, b.changed
+}
+
+// assign gives the topic to the consumer unless another one of the group has it,
+// the channel is closed once that may have changed.
+func (b *MemoryBus) assign(topic string, consumer *memoryConsumer) (bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if owner, ok := b.owners[topic]; ok && owner != consumer {
+		return false, b.changed
+	}
+	b.owners[topic] = consumer
+	return true, nil
+}
+
+func (b *MemoryBus) release(topic string, consumer *memoryConsumer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.owners[topic] == consumer {
+		delete(b.owners, topic)
+		b.wake()
+	}
+}
+
+func (b *MemoryBus) offset(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed[topic]
+}
+
+func (b *MemoryBus) commit(topic string, offset int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.committed[topic] = offset
+}
+
+// NewConsumer returns a consumer of the bus, messages failing every retry go to
+// the dead letter topic on the same bus.
+func (b *MemoryBus) NewConsumer(options ConsumerOptions, log log.Log) Consumer {
+	options.DeadLetterProducer = b
+	options.setDefaults()
+	return &memoryConsumer{bus: b, options: options, logger: log}
+}
+
+type memoryConsumer struct {
+	bus     *MemoryBus
+	handler ConsumerHandler
+	options ConsumerOptions
+	logger  log.Log
+}
+
+func (c *memoryConsumer) SetHandler(handler ConsumerHandler) {
+	c.handler = handler
+}
+
+// Subscribe handles each topic in its own goroutine until ctx is done, starting
+// after the last message the group handled. A topic another consumer of the bus
+// is handling waits until that consumer stops.
+func (c *memoryConsumer) Subscribe(ctx context.Context, topics ...string) error {
+	joinTopic := strings.Join(topics, ", ")
+	if c.handler == nil {
+		return fmt.Errorf("memory bus: no consumer handler for topics [%s]", joinTopic)
+	}
+	d := &delivery{handler: c.handler, options: c.options, logger: c.logger}
+	handlerCtx := context.WithoutCancel(ctx)
+	c.logger.Info("memory-consumer", fmt.Sprintf("Consuming topics [%s]", joinTopic), "Subscribe", "")
+
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			if !c.waitAssigned(ctx, topic) {
+				return
+			}
+			defer c.bus.release(topic, c)
+			offset := c.bus.offset(topic)
+			for ctx.Err() == nil {
+				message, published := c.bus.next(topic, offset)
+				if message == nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-published:
+						continue
+					}
+				}
+				if !d.process(handlerCtx, ctx.Done(), message) {
+					return
+				}
+				offset++
+				c.bus.commit(topic, offset)
+			}
+		}(topic)
+	}
+	wg.Wait()
+	c.logger.Info("memory-consumer", fmt.Sprintf("Stopped consuming topics [%s]", joinTopic), "Subscribe", "")
+	return nil
+}
+
+// waitAssigned blocks until the topic is assigned to the consumer, false when ctx
+// is done first.
+func (c *memoryConsumer) waitAssigned(ctx context.Context, topic string) bool {
+	for {
+		assigned, changed := c.bus.assign(topic, c)
+		if assigned {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/pkg/log"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// quietLog drops every entry, its level is above ERROR.
+var quietLog = log.Log{LogLevel: 3}
+
+// recorder is a handler keeping the values it handled.
+type recorder struct {
+	mu     sync.Mutex
+	values []string
+	fail   func(value string, attempt int) error
+	tries  map[string]int
+}
+
+func (r *recorder) HandleMessage(ctx context.Context, message *kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value := string(message.Value)
+	if r.tries == nil {
+		r.tries = make(map[string]int)
+	}
+	r.tries[value]++
+	if r.fail != nil {
+		if err := r.fail(value, r.tries[value]); err != nil {
+			return err
+		}
+	}
+	r.values = append(r.values, value)
+	return nil
+}
+
+func (r *recorder) handled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.values...)
+}
+
+func publishValues(t *testing.T, bus *MemoryBus, topic string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if err := bus.Publish(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          []byte(value),
+		}); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+	}
+}
+
+// subscribe runs the consumer until the returned stop is called, stop waits for
+// Subscribe to return.
+func subscribe(t *testing.T, consumer Consumer, topics ...string) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Subscribe(ctx, topics...)
+	}()
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Subscribe() = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Subscribe() did not return after cancel")
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func values(n int, prefix string) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return list
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryBusPublish(t *testing.T) {
+	bus := NewMemoryBus()
+	topic := "orders"
+	message := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte("key"),
+		Value:          []byte("first"),
+		Headers:        []kafka.Header{{Key: "traceparent", Value: []byte("value")}},
+	}
+	if err := bus.Publish(message); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	// the bus keeps a copy, the caller may reuse its message
+	message.Value[0] = 'F'
+	bus.PublishChannel(topic, []byte("second"))
+
+	stored := bus.Messages(topic)
+	if len(stored) != 2 {
+		t.Fatalf("Messages() has %d messages, want 2", len(stored))
+	}
+	for offset, want := range []string{"first", "second"} {
+		got := stored[offset]
+		if string(got.Value) != want || got.TopicPartition.Offset != kafka.Offset(offset) || got.TopicPartition.Partition != 0 {
+			t.Fatalf("message %d = %q at %v, want %q at offset %d", offset, got.Value, got.TopicPartition, want, offset)
+		}
+	}
+	if string(stored[0].Key) != "key" || len(stored[0].Headers) != 1 {
+		t.Fatalf("message 0 lost its key or headers: %q %v", stored[0].Key, stored[0].Headers)
+	}
+	if len(bus.Messages("payments")) != 0 {
+		t.Fatalf("an unknown topic has messages")
+	}
+
+	empty := ""
+	if err := bus.Publish(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &empty}}); err == nil {
+		t.Fatalf("Publish() without a topic succeeded")
+	}
+	if err := bus.Publish(&kafka.Message{}); err == nil {
+		t.Fatalf("Publish() with a nil topic succeeded")
+	}
+}
+
+func TestMemoryConsumerConsumesInOrder(t *testing.T) {
+	bus := NewMemoryBus()
+	handler := &recorder{}
+	consumer := bus.NewConsumer(ConsumerOptions{}, quietLog)
+	consumer.SetHandler(handler)
+
+	publishValues(t, bus, "orders", "a", "b", "c")
+	stop := subscribe(t, consumer, "orders", "payments")
+	publishValues(t, bus, "orders", "d", "e")
+	publishValues(t, bus, "payments", "p")
+
+	want := []string{"a", "b", "c", "d", "e"}
+	waitFor(t, "every message", func() bool { return len(handler.handled()) == len(want)+1 })
+	stop()
+
+	var orders []string
+	for _, value := range handler.handled() {
+		if value != "p" {
+			orders = append(orders, value)
+		}
+	}
+	if !equal(orders, want) {
+		t.Fatalf("handled %v, want %v", orders, want)
+	}
+}
+
+func TestMemoryConsumerResumesFromCommittedOffset(t *testing.T) {
+	bus := NewMemoryBus()
+	first := &recorder{}
+	consumer := bus.NewConsumer(ConsumerOptions{}, quietLog)
+	consumer.SetHandler(first)
+
+	publishValues(t, bus, "orders", "a", "b")
+	stop := subscribe(t, consumer, "orders")
+	waitFor(t, "the first messages", func() bool { return len(first.handled()) == 2 })
+	stop()
+	if offset := bus.offset("orders"); offset != 2 {
+		t.Fatalf("committed offset = %d, want 2", offset)
+	}
+
+	publishValues(t, bus, "orders", "c")
+	second := &recorder{}
+	restarted := bus.NewConsumer(ConsumerOptions{}, quietLog)
+	restarted.SetHandler(second)
+	stop = subscribe(t, restarted, "orders")
+	waitFor(t, "the message published while stopped", func() bool { return len(second.handled()) == 1 })
+	stop()
+
+	if got := second.handled(); !equal(got, []string{"c"}) {
+		t.Fatalf("restarted consumer handled %v, want [c]", got)
+	}
+	if offset := bus.offset("orders"); offset != 3 {
+		t.Fatalf("committed offset = %d, want 3", offset)
+	}
+}
+
+func TestMemoryConsumerGroupHandlesEachMessageOnce(t *testing.T) {
+	bus := NewMemoryBus()
+	first, second := &recorder{}, &recorder{}
+	consumerA := bus.NewConsumer(ConsumerOptions{}, quietLog)
+	consumerA.SetHandler(first)
+	consumerB := bus.NewConsumer(ConsumerOptions{}, quietLog)
+	consumerB.SetHandler(second)
+
+	stopA := subscribe(t, consumerA, "orders")
+	stopB := subscribe(t, consumerB, "orders")
+	batch := values(50, "m")
+	publishValues(t, bus, "orders", batch...)
+	waitFor(t, "the first batch", func() bool { return len(first.handled())+len(second.handled()) == len(batch) })
+
+	// the topic is assigned to one member, it handles the whole batch in order
+	owner, idle, stopOwner, stopIdle := first, second, stopA, stopB
+	if len(first.handled()) == 0 {
+		owner, idle, stopOwner, stopIdle = second, first, stopB, stopA
+	}
+	if got := owner.handled(); !equal(got, batch) {
+		t.Fatalf("assigned consumer handled %v, want %v", got, batch)
+	}
+	if got := idle.handled(); len(got) != 0 {
+		t.Fatalf("the other member handled %v, want nothing", got)
+	}
+
+	// once the owner leaves the other member takes over after the committed offset
+	stopOwner()
+	more := values(10, "n")
+	publishValues(t, bus, "orders", more...)
+	waitFor(t, "the takeover", func() bool { return len(idle.handled()) == len(more) })
+	stopIdle()
+
+	if got := idle.handled(); !equal(got, more) {
+		t.Fatalf("consumer taking over handled %v, want %v", got, more)
+	}
+	if got := owner.handled(); len(got) != len(batch) {
+		t.Fatalf("stopped consumer handled %d messages, want %d", len(got), len(batch))
+	}
+}
+
+func TestMemoryConsumerRetriesAndDeadLetters(t *testing.T) {
+	bus := NewMemoryBus()
+	handler := &recorder{fail: func(value string, attempt int) error {
+		switch {
+		case value == "flaky" && attempt < 3:
+			return errors.New("temporary")
+		case value == "broken":
+			return Permanent(errors.New("cannot handle"))
+		case value == "failing":
+			return errors.New("always")
+		}
+		return nil
+	}}
+	consumer := bus.NewConsumer(ConsumerOptions{Retries: 2, Backoff: time.Millisecond}, quietLog)
+	consumer.SetHandler(handler)
+
+	publishValues(t, bus, "orders", "flaky", "broken", "failing", "ok")
+	stop := subscribe(t, consumer, "orders")
+	waitFor(t, "the last message", func() bool {
+		handled := handler.handled()
+		return len(handled) > 0 && handled[len(handled)-1] == "ok"
+	})
+	stop()
+
+	if got := handler.handled(); !equal(got, []string{"flaky", "ok"}) {
+		t.Fatalf("handled %v, want [flaky ok]", got)
+	}
+	handler.mu.Lock()
+	tries := map[string]int{"flaky": handler.tries["flaky"], "broken": handler.tries["broken"], "failing": handler.tries["failing"]}
+	handler.mu.Unlock()
+	if want := map[string]int{"flaky": 3, "broken": 1, "failing": 3}; fmt.Sprint(tries) != fmt.Sprint(want) {
+		t.Fatalf("attempts = %v, want %v", tries, want)
+	}
+
+	dead := bus.Messages("orders.dlq")
+	if len(dead) != 2 {
+		t.Fatalf("dead letter topic has %d messages, want 2", len(dead))
+	}
+	for i, want := range []struct{ value, attempts string }{{"broken", "1"}, {"failing", "3"}} {
+		headers := make(map[string]string)
+		for _, header := range dead[i].Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		if string(dead[i].Value) != want.value || headers[HeaderDeadLetterTopic] != "orders" || headers[HeaderDeadLetterAttempts] != want.attempts {
+			t.Fatalf("dead letter %d = %q with headers %v, want %q after %s attempts", i, dead[i].Value, headers, want.value, want.attempts)
+		}
+	}
+}
+
+func TestMemoryConsumerRequiresHandler(t *testing.T) {
+	consumer := NewMemoryBus().NewConsumer(ConsumerOptions{}, quietLog)
+	if err := consumer.Subscribe(context.Background(), "orders"); err == nil {
+		t.Fatalf("Subscribe() without a handler succeeded")
+	}
+}
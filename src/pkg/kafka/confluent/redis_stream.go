@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/src/pkg/log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+const (
+	streamFieldKey    = "key"
+	streamFieldValue  = "value"
+	streamHeaderField = "h:"
+
+	// streamClaimIdle is how long a message stays pending with a consumer that
+	// went away before another one takes it over
+	streamClaimIdle = time.Minute
+)
+
+// RedisStreamBus carries the topics as Redis streams, the stream of a topic is
+// the topic name with Prefix. Consumers of one group share the work, a message
+// is acknowledged once it is handled.
+type RedisStreamBus struct {
+	Redis  redis.UniversalClient
+	Prefix string
+	// MaxLen trims each stream to about that many messages, 0 keeps everything
+	MaxLen int64
+}
+
+func NewRedisStreamBus(redisClient redis.UniversalClient, prefix string, maxLen int64) *RedisStreamBus {
+	return &RedisStreamBus{
+		Redis:  redisClient,
+		Prefix: prefix,
+		MaxLen: maxLen,
+	}
+}
+
+func (b *RedisStreamBus) stream(topic string) string {
+	return b.Prefix + topic
+}
+
+func (b *RedisStreamBus) Publish(message *kafka.Message) error {
+	if message.TopicPartition.Topic == nil || *message.TopicPartition.Topic == "" {
+		return errors.New("redis stream bus: message has no topic")
+	}
+	values := []interface{}{streamFieldKey, message.Key, streamFieldValue, message.Value}
+	for _, header := range message.Headers {
+		values = append(values, streamHeaderField+header.Key, header.Value)
+	}
+	return b.Redis.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: b.stream(*message.TopicPartition.Topic),
+		MaxLen: b.MaxLen,
+		Approx: b.MaxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+func (b *RedisStreamBus) PublishChannel(topic string, message []byte) {
+	_ = b.Publish(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          message,
+	})
+}
+
+// NewConsumer returns a consumer in the group, messages failing every retry go
+// to the dead letter stream.
+func (b *RedisStreamBus) NewConsumer(group string, options ConsumerOptions, log log.Log) Consumer {
+	options.DeadLetterProducer = b
+	options.setDefaults()
+	hostname, _ := os.Hostname()
+	return &redisStreamConsumer{
+		bus:     b,
+		group:   group,
+		name:    fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		options: options,
+		logger:  log,
+	}
+}
+
+type redisStreamConsumer struct {
+	bus     *RedisStreamBus
+	group   string
+	name    string
+	handler ConsumerHandler
+	options ConsumerOptions
+	logger  log.Log
+}
+
+func (c *redisStreamConsumer) SetHandler(handler ConsumerHandler) {
+	c.handler = handler
+}
+
+// Subscribe handles each topic in its own goroutine until ctx is done. Messages
+// left pending by this consumer or by one that stopped are handled first.
+func (c *redisStreamConsumer) Subscribe(ctx context.Context, topics ...string) error {
+	joinTopic := strings.Join(topics, ", ")
+	if c.handler == nil {
+		return fmt.Errorf("redis stream bus: no consumer handler for topics [%s]", joinTopic)
+	}
+	for _, topic := range topics {
+		err := c.bus.Redis.XGroupCreateMkStream(ctx, c.bus.stream(topic), c.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
+	c.logger.Info("redis-stream-consumer", fmt.Sprintf("Consuming topics [%s] as %s", joinTopic, c.name), "Subscribe", c.group)
+
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			c.consume(ctx, topic)
+		}(topic)
+	}
+	wg.Wait()
+	c.logger.Info("redis-stream-consumer", fmt.Sprintf("Stopped consuming topics [%s]", joinTopic), "Subscribe", c.group)
+	return nil
+}
+
+func (c *redisStreamConsumer) consume(ctx context.Context, topic string) {
+	d := &delivery{handler: c.handler, options: c.options, logger: c.logger}
+	handlerCtx := context.WithoutCancel(ctx)
+	stream := c.bus.stream(topic)
+	// "0" reads back what this consumer holds pending, ">" the new messages
+	start := "0"
+	claimAt := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(claimAt) > streamClaimIdle {
+			c.claim(ctx, stream)
+			claimAt = time.Now()
+			start = "0"
+		}
+		streams, err := c.bus.Redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.name,
+			Streams:  []string{stream, start},
+			Count:    10,
+			Block:    time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			c.logger.Error("redis-stream-consumer", fmt.Sprintf("Failed read stream: %v", err), "consume", stream)
+			sleep(ctx.Done(), c.options.Backoff)
+			continue
+		}
+		read := 0
+		for _, s := range streams {
+			for _, entry := range s.Messages {
+				read++
+				if !d.process(handlerCtx, ctx.Done(), c.message(topic, entry)) {
+					return
+				}
+				if err := c.bus.Redis.XAck(handlerCtx, stream, c.group, entry.ID).Err(); err != nil {
+					c.logger.Error("redis-stream-consumer", fmt.Sprintf("Failed ack message: %v", err), "consume", entry.ID)
+				}
+			}
+		}
+		if start == "0" && read == 0 {
+			start = ">"
+		}
+	}
+}
+
+// claim takes over the messages another consumer of the group left pending.
+func (c *redisStreamConsumer) claim(ctx context.Context, stream string) {
+	cursor := "0-0"
+	for {
+		_, next, err := c.bus.Redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    c.group,
+			MinIdle:  streamClaimIdle,
+			Start:    cursor,
+			Count:    100,
+			Consumer: c.name,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("redis-stream-consumer", fmt.Sprintf("Failed claim pending messages: %v", err), "claim", stream)
+			}
+			return
+		}
+		if next == "0-0" {
+			return
+		}
+		cursor = next
+	}
+}
+
+func (c *redisStreamConsumer) message(topic string, entry redis.XMessage) *kafka.Message {
+	id := entry.ID
+	message := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.OffsetInvalid, Metadata: &id},
+	}
+	for field, value := range entry.Values {
+		raw := []byte(fmt.Sprint(value))
+		switch {
+		case field == streamFieldKey:
+			message.Key = raw
+		case field == streamFieldValue:
+			message.Value = raw
+		case strings.HasPrefix(field, streamHeaderField):
+			message.Headers = append(message.Headers, kafka.Header{Key: strings.TrimPrefix(field, streamHeaderField), Value: raw})
+		}
+	}
+	return message
+}
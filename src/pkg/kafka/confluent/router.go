@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// Router hands each message to the handler of its topic, so one consumer group
+// serves every topic of the service.
+type Router struct {
+	handlers map[string]ConsumerHandler
+}
+
+func NewRouter() *Router {
+	return &Router{handlers: make(map[string]ConsumerHandler)}
+}
+
+func (r *Router) Handle(topic string, handler ConsumerHandler) *Router {
+	r.handlers[topic] = handler
+	return r
+}
+
+// Topics lists the routed topics to subscribe to.
+func (r *Router) Topics() []string {
+	topics := make([]string, 0, len(r.handlers))
+	for topic := range r.handlers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (r *Router) HandleMessage(ctx context.Context, message *k.Message) error {
+	handler, ok := r.handlers[*message.TopicPartition.Topic]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for topic %s", *message.TopicPartition.Topic))
+	}
+	return handler.HandleMessage(ctx, message)
+}
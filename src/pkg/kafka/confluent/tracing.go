@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"order-service/src/pkg/tracing"
+	"strings"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// InjectTrace sets the request id and trace context headers of the message,
+// replacing the ones it already has.
+func InjectTrace(message *kafka.Message, trace tracing.Trace) {
+	trace.Inject(func(key, value string) {
+		key = strings.ToLower(key)
+		for i := range message.Headers {
+			if strings.EqualFold(message.Headers[i].Key, key) {
+				message.Headers[i].Value = []byte(value)
+				return
+			}
+		}
+		message.Headers = append(message.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	})
+}
+
+// ExtractTrace continues the trace of the message in a new span, a message
+// without trace headers starts a new trace.
+func ExtractTrace(message *kafka.Message) tracing.Trace {
+	return tracing.Extract(headerOf(message))
+}
+
+// MessageTrace returns the trace the message carries, ok is false when it has
+// no trace headers.
+func MessageTrace(message *kafka.Message) (tracing.Trace, bool) {
+	return tracing.Parse(headerOf(message))
+}
+
+func headerOf(message *kafka.Message) func(key string) string {
+	return func(key string) string {
+		for _, header := range message.Headers {
+			if strings.EqualFold(header.Key, key) {
+				return string(header.Value)
+			}
+		}
+		return ""
+	}
+}
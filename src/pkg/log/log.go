@@ -1,8 +1,10 @@
 package log
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"order-service/src/pkg/tracing"
 	"runtime"
 	"time"
 
@@ -15,6 +17,8 @@ type Log struct {
 	AppName  string
 	LogLevel int
 	Logger   *logrus.Logger
+	// trace is set by WithContext, its ids are added to every entry
+	trace tracing.Trace
 	// future: add logstash/kafka client fields here
 }
 
@@ -42,6 +46,35 @@ func GetLogger() Log {
 	return logger
 }
 
+// WithContext returns the logger adding the request id and trace context of
+// ctx to its entries, the logger itself when ctx carries none.
+func (l Log) WithContext(ctx context.Context) Log {
+	if trace, ok := tracing.FromContext(ctx); ok {
+		l.trace = trace
+	}
+	return l
+}
+
+// withTrace adds the ids of the trace to the fields.
+func (l Log) withTrace(fields logrus.Fields) logrus.Fields {
+	if l.trace.RequestID != "" {
+		fields["request_id"] = l.trace.RequestID
+	}
+	if l.trace.TraceID != "" {
+		fields["trace_id"] = l.trace.TraceID
+		fields["span_id"] = l.trace.SpanID
+	}
+	return fields
+}
+
+// traceMeta is the request id in the console line.
+func (l Log) traceMeta() string {
+	if l.trace.RequestID == "" {
+		return ""
+	}
+	return fmt.Sprintf(" - Request: %s - Trace: %s", l.trace.RequestID, l.trace.TraceID)
+}
+
 // internal helper to create logrus instance
 func newLogrusLogger(v *viper.Viper) *logrus.Logger {
 	l := logrus.New()
@@ -60,17 +93,17 @@ func newLogrusLogger(v *viper.Viper) *logrus.Logger {
 func (l Log) Info(context, message, scope, meta string) {
 	if l.LogLevel <= 1 {
 		_, file, line, _ := runtime.Caller(1)
-		msg := fmt.Sprintf("[INFO] Service: %s - Context: %s - Message: %s - Scope: %s - Meta: %s - At: %s:%d",
-			l.AppName, context, message, scope, meta, file, line)
+		msg := fmt.Sprintf("[INFO] Service: %s - Context: %s - Message: %s - Scope: %s - Meta: %s%s - At: %s:%d",
+			l.AppName, context, message, scope, meta, l.traceMeta(), file, line)
 		println(msg)
-		l.Logger.WithFields(logrus.Fields{
+		l.Logger.WithFields(l.withTrace(logrus.Fields{
 			"service": l.AppName,
 			"context": context,
 			"scope":   scope,
 			"meta":    meta,
 			"file":    file,
 			"line":    line,
-		}).Info(message)
+		})).Info(message)
 	}
 }
 
@@ -80,10 +113,10 @@ func (l Log) Error(context, message, scope, meta string) {
 	if l.LogLevel <= 2 {
 		_, file, line, _ := runtime.Caller(1)
 		_, file2, line2, _ := runtime.Caller(2)
-		msg := fmt.Sprintf("[ERROR] Context: %s - Message: %s - Scope: %s - Meta: %s - At Level1: %s:%d - At Level2: %s:%d",
-			context, message, scope, meta, file, line, file2, line2)
+		msg := fmt.Sprintf("[ERROR] Context: %s - Message: %s - Scope: %s - Meta: %s%s - At Level1: %s:%d - At Level2: %s:%d",
+			context, message, scope, meta, l.traceMeta(), file, line, file2, line2)
 		println(msg)
-		l.Logger.WithFields(logrus.Fields{
+		l.Logger.WithFields(l.withTrace(logrus.Fields{
 			"service": l.AppName,
 			"context": context,
 			"scope":   scope,
@@ -92,7 +125,7 @@ func (l Log) Error(context, message, scope, meta string) {
 			"line1":   line,
 			"file2":   file2,
 			"line2":   line2,
-		}).Error(message)
+		})).Error(message)
 	}
 }
 
@@ -101,17 +134,17 @@ func (l Log) Error(context, message, scope, meta string) {
 func (l Log) Slow(context, message, scope, meta string) {
 	if l.LogLevel <= 1 {
 		_, file, line, _ := runtime.Caller(2)
-		msg := fmt.Sprintf("[SLOW] Context: %s - Message: %s - Scope: %s - Meta: %s - At: %s:%d",
-			context, message, scope, meta, file, line)
+		msg := fmt.Sprintf("[SLOW] Context: %s - Message: %s - Scope: %s - Meta: %s%s - At: %s:%d",
+			context, message, scope, meta, l.traceMeta(), file, line)
 		println(msg)
-		l.Logger.WithFields(logrus.Fields{
+		l.Logger.WithFields(l.withTrace(logrus.Fields{
 			"service": l.AppName,
 			"context": context,
 			"scope":   scope,
 			"meta":    meta,
 			"file":    file,
 			"line":    line,
-		}).Info("[SLOW] " + message)
+		})).Info("[SLOW] " + message)
 	}
 }
 
@@ -0,0 +1,126 @@
+package matching
+
+import "math"
+
+// Infeasible marks a pair that must never be assigned
+var Infeasible = math.Inf(1)
+
+// Assign solves the rectangular assignment problem with the Hungarian algorithm.
+// cost[i][j] is the cost of giving column j to row i. The result holds the column
+// assigned to every row, or -1 when the row is left unassigned because there are
+// fewer columns than rows or every remaining column is Infeasible for it.
+// The solver is deterministic, equal inputs always give equal assignments.
+func Assign(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	result := make([]int, rows)
+	for i := range result {
+		result[i] = -1
+	}
+	if cols == 0 {
+		return result
+	}
+
+	// infeasible pairs get a penalty larger than any feasible assignment so the
+	// solver only picks them when nothing else is left, they are dropped afterwards
+	penalty := 1.0
+	for _, row := range cost {
+		for _, c := range row {
+			if !math.IsInf(c, 1) {
+				penalty += math.Abs(c)
+			}
+		}
+	}
+	at := func(i, j int) float64 {
+		c := cost[i][j]
+		if math.IsInf(c, 1) {
+			return penalty
+		}
+		return c
+	}
+
+	transposed := rows > cols
+	n, m := rows, cols
+	if transposed {
+		n, m = cols, rows
+	}
+	get := func(i, j int) float64 {
+		if transposed {
+			return at(j, i)
+		}
+		return at(i, j)
+	}
+
+	// e-maxx formulation with potentials, rows and columns are 1-indexed
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				cur := get(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+			if j0 == 0 {
+				break
+			}
+		}
+	}
+
+	for j := 1; j <= m; j++ {
+		if p[j] == 0 {
+			continue
+		}
+		row, col := p[j]-1, j-1
+		if transposed {
+			row, col = col, row
+		}
+		if math.IsInf(cost[row][col], 1) {
+			continue
+		}
+		result[row] = col
+	}
+	return result
+}
@@ -0,0 +1,240 @@
+package matching
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAssign(t *testing.T) {
+	inf := Infeasible
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{
+			name: "empty",
+			cost: nil,
+			want: nil,
+		},
+		{
+			name: "no columns",
+			cost: [][]float64{{}, {}},
+			want: []int{-1, -1},
+		},
+		{
+			name: "single",
+			cost: [][]float64{{7}},
+			want: []int{0},
+		},
+		{
+			name: "square",
+			cost: [][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			want: []int{1, 0, 2},
+		},
+		{
+			name: "square prefers the cheaper total over the cheapest pair",
+			cost: [][]float64{
+				{1, 2},
+				{1, 10},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "more columns than rows",
+			cost: [][]float64{
+				{9, 2, 7, 8},
+				{6, 4, 3, 7},
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "more rows than columns leaves the costliest row out",
+			cost: [][]float64{
+				{5, 9},
+				{1, 8},
+				{7, 2},
+			},
+			want: []int{-1, 0, 1},
+		},
+		{
+			name: "infeasible pair is avoided",
+			cost: [][]float64{
+				{inf, 1},
+				{1, 100},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "row with only infeasible columns stays unassigned",
+			cost: [][]float64{
+				{inf, inf},
+				{3, 1},
+			},
+			want: []int{-1, 1},
+		},
+		{
+			name: "every pair infeasible",
+			cost: [][]float64{
+				{inf, inf},
+				{inf, inf},
+			},
+			want: []int{-1, -1},
+		},
+		{
+			name: "feasible count wins over cost",
+			cost: [][]float64{
+				{1, 50},
+				{1, inf},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "negative costs",
+			cost: [][]float64{
+				{-5, 0},
+				{0, -1},
+			},
+			want: []int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Assign(tt.cost)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Assign() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignTiesAreDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{
+			name: "all equal square",
+			cost: [][]float64{
+				{1, 1, 1},
+				{1, 1, 1},
+				{1, 1, 1},
+			},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "all equal wide",
+			cost: [][]float64{
+				{2, 2, 2},
+				{2, 2, 2},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "all equal tall",
+			cost: [][]float64{
+				{3, 3},
+				{3, 3},
+				{3, 3},
+			},
+			want: []int{0, 1, -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for run := 0; run < 20; run++ {
+				got := Assign(tt.cost)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("run %d: Assign() = %v, want %v", run, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAssignMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	for iteration := 0; iteration < 500; iteration++ {
+		rows, cols := 1+rng.Intn(5), 1+rng.Intn(5)
+		cost := make([][]float64, rows)
+		for i := range cost {
+			cost[i] = make([]float64, cols)
+			for j := range cost[i] {
+				if rng.Float64() < 0.15 {
+					cost[i][j] = Infeasible
+					continue
+				}
+				// small integers make ties frequent
+				cost[i][j] = float64(rng.Intn(10))
+			}
+		}
+
+		got := Assign(cost)
+		if len(got) != rows {
+			t.Fatalf("%v: got %d rows, want %d", cost, len(got), rows)
+		}
+		usedCols := make(map[int]bool)
+		for i, j := range got {
+			if j == -1 {
+				continue
+			}
+			if j < 0 || j >= cols || usedCols[j] || math.IsInf(cost[i][j], 1) {
+				t.Fatalf("%v: invalid assignment %v", cost, got)
+			}
+			usedCols[j] = true
+		}
+
+		gotCount, gotCost := score(cost, got)
+		wantCount, wantCost := bruteForce(cost)
+		if gotCount != wantCount || math.Abs(gotCost-wantCost) > 1e-9 {
+			t.Fatalf("%v: Assign() = %v with %d pairs costing %v, best is %d pairs costing %v",
+				cost, got, gotCount, gotCost, wantCount, wantCost)
+		}
+	}
+}
+
+func score(cost [][]float64, assignment []int) (int, float64) {
+	count, total := 0, 0.0
+	for i, j := range assignment {
+		if j >= 0 {
+			count++
+			total += cost[i][j]
+		}
+	}
+	return count, total
+}
+
+// bruteForce returns the most feasible pairs any assignment reaches and the
+// lowest cost among the assignments reaching it.
+func bruteForce(cost [][]float64) (int, float64) {
+	cols := len(cost[0])
+	bestCount, bestCost := -1, math.Inf(1)
+	used := make([]bool, cols)
+	var walk func(row, count int, total float64)
+	walk = func(row, count int, total float64) {
+		if row == len(cost) {
+			if count > bestCount || (count == bestCount && total < bestCost) {
+				bestCount, bestCost = count, total
+			}
+			return
+		}
+		walk(row+1, count, total)
+		for j := 0; j < cols; j++ {
+			if used[j] || math.IsInf(cost[row][j], 1) {
+				continue
+			}
+			used[j] = true
+			walk(row+1, count+1, total+cost[row][j])
+			used[j] = false
+		}
+	}
+	walk(0, 0, 0)
+	return bestCount, bestCost
+}
@@ -0,0 +1,151 @@
+// Package pdf writes simple text documents as PDF using the standard Helvetica
+// fonts, enough for receipts and statements without an external renderer.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	// PageWidth and PageHeight are A4 in points.
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+type text struct {
+	x, y  float64
+	size  float64
+	bold  bool
+	value string
+}
+
+type line struct {
+	x1, y1, x2, y2 float64
+}
+
+type page struct {
+	texts []text
+	lines []line
+}
+
+// Document is a PDF under construction. Coordinates are in points from the top
+// left corner of the page.
+type Document struct {
+	pages []*page
+}
+
+func New() *Document {
+	d := &Document{}
+	d.AddPage()
+	return d
+}
+
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &page{})
+}
+
+func (d *Document) current() *page {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text writes a single line of text with its baseline at y.
+func (d *Document) Text(x, y, size float64, bold bool, value string) {
+	d.current().texts = append(d.current().texts, text{x: x, y: y, size: size, bold: bold, value: value})
+}
+
+// TextRight writes a line of text ending at x.
+func (d *Document) TextRight(x, y, size float64, bold bool, value string) {
+	d.Text(x-TextWidth(value, size, bold), y, size, bold, value)
+}
+
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	d.current().lines = append(d.current().lines, line{x1: x1, y1: y1, x2: x2, y2: y2})
+}
+
+// TextWidth estimates the width of a text in points. Helvetica averages a bit
+// over half the font size per glyph, the bold face slightly more.
+func TextWidth(value string, size float64, bold bool) float64 {
+	factor := 0.52
+	if bold {
+		factor = 0.56
+	}
+	return float64(len([]rune(value))) * size * factor
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		id := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", id, body)
+		return id
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1 catalog, 2 page tree, 3 and 4 fonts, pages follow
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	offsets = append(offsets, 0) // the page tree is written once the page ids are known
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	var kids []string
+	for _, p := range d.pages {
+		content := p.content()
+		stream := object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		pageID := object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+	offsets[1] = buf.Len()
+	fmt.Fprintf(&buf, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(kids))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func (p *page) content() string {
+	var b strings.Builder
+	for _, l := range p.lines {
+		fmt.Fprintf(&b, "0.5 w %.2f %.2f m %.2f %.2f l S\n", l.x1, PageHeight-l.y1, l.x2, PageHeight-l.y2)
+	}
+	for _, t := range p.texts {
+		font := "F1"
+		if t.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&b, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, t.size, t.x, PageHeight-t.y, escape(t.value))
+	}
+	return b.String()
+}
+
+// escape quotes a string literal, characters outside Latin-1 have no glyph in
+// the standard fonts and are replaced.
+func escape(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 128:
+			b.WriteRune(r)
+		case r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
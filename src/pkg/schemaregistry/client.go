@@ -0,0 +1,108 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Client talks to a Confluent Schema Registry, schemas fetched by id are cached
+// since a registered schema never changes.
+type Client struct {
+	URL      string
+	Username string
+	Password string
+	HTTP     *http.Client
+
+	mu      sync.Mutex
+	schemas map[int]string
+}
+
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		URL:      strings.TrimRight(baseURL, "/"),
+		Username: username,
+		Password: password,
+		HTTP:     &http.Client{Timeout: 10 * time.Second},
+		schemas:  make(map[int]string),
+	}
+}
+
+func (c *Client) Register(ctx context.Context, subject, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema, "schemaType": SchemaTypeAvro})
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		ID int `json:"id"`
+	}
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
+	if err := c.do(ctx, http.MethodPost, path, body, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+func (c *Client) Schema(ctx context.Context, id int) (string, error) {
+	c.mu.Lock()
+	schema, ok := c.schemas[id]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	var result struct {
+		Schema string `json:"schema"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &result); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.schemas[id] = result.Schema
+	c.mu.Unlock()
+	return result.Schema, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("schemaregistry: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var registryErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		if json.Unmarshal(raw, &registryErr) == nil && registryErr.Message != "" {
+			return fmt.Errorf("schemaregistry: %s %s: %d %s", method, path, registryErr.ErrorCode, registryErr.Message)
+		}
+		return fmt.Errorf("schemaregistry: %s %s: status %d", method, path, resp.StatusCode)
+	}
+	return json.Unmarshal(raw, result)
+}
@@ -0,0 +1,111 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type fileSchema struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+type fileState struct {
+	Schemas []fileSchema `json:"schemas"`
+}
+
+// FileRegistry keeps the registered schemas in one JSON file. It hands out ids
+// and versions the way the registry does, a schema already known under another
+// subject keeps its id, so messages written against it decode the same way.
+type FileRegistry struct {
+	mu    sync.Mutex
+	path  string
+	state fileState
+}
+
+// NewFileRegistry loads the registry kept at path, a missing file starts empty.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	r := &FileRegistry{path: path}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &r.state); err != nil {
+		return nil, fmt.Errorf("schemaregistry: read %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *FileRegistry) Register(ctx context.Context, subject, schema string) (int, error) {
+	schema, err := normalize(schema)
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, version := 0, 0
+	for _, s := range r.state.Schemas {
+		if s.Schema == schema {
+			if s.Subject == subject {
+				return s.ID, nil
+			}
+			id = s.ID
+		}
+		if s.Subject == subject && s.Version > version {
+			version = s.Version
+		}
+	}
+	if id == 0 {
+		for _, s := range r.state.Schemas {
+			if s.ID > id {
+				id = s.ID
+			}
+		}
+		id++
+	}
+	r.state.Schemas = append(r.state.Schemas, fileSchema{ID: id, Subject: subject, Version: version + 1, Schema: schema})
+	if err := r.save(); err != nil {
+		r.state.Schemas = r.state.Schemas[:len(r.state.Schemas)-1]
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *FileRegistry) Schema(ctx context.Context, id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.state.Schemas {
+		if s.ID == id {
+			return s.Schema, nil
+		}
+	}
+	return "", fmt.Errorf("schemaregistry: schema %d not found", id)
+}
+
+// save replaces the file in one rename so a crash never leaves it half written.
+func (r *FileRegistry) save() error {
+	raw, err := json.MarshalIndent(r.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,148 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		payload []byte
+	}{
+		{name: "empty payload", id: 1, payload: []byte{}},
+		{name: "small id", id: 7, payload: []byte("payload")},
+		{name: "id over one byte", id: 300, payload: []byte{0, 1, 2}},
+		{name: "largest id", id: 1<<32 - 1, payload: []byte{0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			framed := Encode(tt.id, tt.payload)
+			if framed[0] != MagicByte || len(framed) != 5+len(tt.payload) {
+				t.Fatalf("Encode() = %v, want the magic byte, a 4 byte id and the payload", framed)
+			}
+			id, payload, err := Decode(framed)
+			if err != nil {
+				t.Fatalf("Decode() = %v", err)
+			}
+			if id != tt.id || !bytes.Equal(payload, tt.payload) {
+				t.Fatalf("Decode() = %d %v, want %d %v", id, payload, tt.id, tt.payload)
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsUnframed(t *testing.T) {
+	for _, value := range [][]byte{nil, {0, 0, 0, 1}, []byte(`{"id":1}`), {1, 0, 0, 0, 1}} {
+		if _, _, err := Decode(value); err == nil {
+			t.Fatalf("Decode(%v) succeeded, want an error", value)
+		}
+	}
+}
+
+const (
+	schemaA = `{"type":"record","name":"A","fields":[{"name":"id","type":"string"}]}`
+	schemaB = `{"type":"record","name":"B","fields":[{"name":"n","type":"long"}]}`
+)
+
+func TestFileRegistryRegister(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatalf("NewFileRegistry() = %v", err)
+	}
+
+	first := mustRegister(t, registry, "orders-value", schemaA)
+	if again := mustRegister(t, registry, "orders-value", schemaA); again != first {
+		t.Fatalf("registering the same schema again = %d, want %d", again, first)
+	}
+	spaced := "{\n  \"type\": \"record\", \"name\": \"A\",\n  \"fields\": [{\"name\": \"id\", \"type\": \"string\"}]\n}"
+	if reformatted := mustRegister(t, registry, "orders-value", spaced); reformatted != first {
+		t.Fatalf("registering the schema formatted differently = %d, want %d", reformatted, first)
+	}
+	if otherSubject := mustRegister(t, registry, "payments-value", schemaA); otherSubject != first {
+		t.Fatalf("registering the schema under another subject = %d, want %d", otherSubject, first)
+	}
+	second := mustRegister(t, registry, "orders-value", schemaB)
+	if second == first {
+		t.Fatalf("a new schema got the id %d of an existing one", second)
+	}
+
+	if schema, err := registry.Schema(ctx, second); err != nil || schema != schemaB {
+		t.Fatalf("Schema(%d) = %q %v, want %q", second, schema, err, schemaB)
+	}
+	if _, err := registry.Schema(ctx, second+1); err == nil {
+		t.Fatalf("Schema(%d) of an unknown id succeeded", second+1)
+	}
+	if _, err := registry.Register(ctx, "orders-value", "not json"); err == nil {
+		t.Fatalf("registering an invalid schema succeeded")
+	}
+
+	versions := make(map[string][]int)
+	for _, s := range registry.state.Schemas {
+		versions[s.Subject] = append(versions[s.Subject], s.Version)
+	}
+	if got := versions["orders-value"]; len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("orders-value versions = %v, want [1 2]", got)
+	}
+	if got := versions["payments-value"]; len(got) != 1 || got[0] != 1 {
+		t.Fatalf("payments-value versions = %v, want [1]", got)
+	}
+}
+
+func TestFileRegistryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "registry.json")
+	registry, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("NewFileRegistry() = %v", err)
+	}
+	idA := mustRegister(t, registry, "orders-value", schemaA)
+	idB := mustRegister(t, registry, "orders-value", schemaB)
+
+	reloaded, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("reloading the registry = %v", err)
+	}
+	for id, want := range map[int]string{idA: schemaA, idB: schemaB} {
+		if schema, err := reloaded.Schema(ctx, id); err != nil || schema != want {
+			t.Fatalf("Schema(%d) after reload = %q %v, want %q", id, schema, err, want)
+		}
+	}
+	if id := mustRegister(t, reloaded, "orders-value", schemaA); id != idA {
+		t.Fatalf("registering a known schema after reload = %d, want %d", id, idA)
+	}
+	schemaC := `{"type":"record","name":"C","fields":[]}`
+	if id := mustRegister(t, reloaded, "orders-value", schemaC); id <= idB {
+		t.Fatalf("a new schema after reload got id %d, want one above %d", id, idB)
+	}
+	if len(reloaded.state.Schemas) != 3 {
+		t.Fatalf("registry holds %d schemas, want 3", len(reloaded.state.Schemas))
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("the temporary file was left behind: %v", err)
+	}
+}
+
+func TestNewFileRegistryRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileRegistry(path); err == nil {
+		t.Fatalf("NewFileRegistry() of a corrupt file succeeded")
+	}
+}
+
+func mustRegister(t *testing.T, registry *FileRegistry, subject, schema string) int {
+	t.Helper()
+	id, err := registry.Register(context.Background(), subject, schema)
+	if err != nil {
+		t.Fatalf("Register(%s) = %v", subject, err)
+	}
+	return id
+}
@@ -0,0 +1,58 @@
+// Package schemaregistry registers message schemas and frames payloads in the
+// Confluent Schema Registry wire format, with a client for the registry and a
+// file based stand-in for local development.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// MagicByte leads every message in the wire format, followed by the schema id as
+// a big endian uint32 and the encoded payload.
+const MagicByte byte = 0
+
+const SchemaTypeAvro = "AVRO"
+
+type Registry interface {
+	// Register returns the id of the schema under the subject, registering it as
+	// a new version when the subject does not have it yet.
+	Register(ctx context.Context, subject, schema string) (int, error)
+	// Schema returns the schema registered with the id.
+	Schema(ctx context.Context, id int) (string, error)
+}
+
+// ValueSubject is the subject of the value schema of a topic under the default
+// topic name strategy.
+func ValueSubject(topic string) string {
+	return topic + "-value"
+}
+
+// Encode frames a payload written with the schema id.
+func Encode(id int, payload []byte) []byte {
+	buf := make([]byte, 5, 5+len(payload))
+	buf[0] = MagicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(id))
+	return append(buf, payload...)
+}
+
+// Decode splits a framed message into the schema id and the payload.
+func Decode(value []byte) (int, []byte, error) {
+	if len(value) < 5 || value[0] != MagicByte {
+		return 0, nil, fmt.Errorf("schemaregistry: message is not in the wire format")
+	}
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
+}
+
+// normalize compacts a JSON schema so the same schema formatted differently is
+// registered once.
+func normalize(schema string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(schema)); err != nil {
+		return "", fmt.Errorf("schemaregistry: schema is not valid JSON: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -10,4 +10,5 @@ type Claim struct {
 type Metadata struct {
 	UserID   string `json:"user_id"`
 	FullName string `json:"full_name"`
+	Segment  string `json:"segment,omitempty"`
 }
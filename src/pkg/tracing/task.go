@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TaskField is the field of a JSON task payload carrying the request id and the
+// trace context, asynq tasks have no headers of their own.
+const TaskField = "_trace"
+
+// InjectPayload adds a new span of the trace of ctx to a JSON object payload.
+// The payload is returned unchanged when ctx carries no trace or it is not a
+// JSON object.
+func InjectPayload(ctx context.Context, payload []byte) []byte {
+	trace, ok := FromContext(ctx)
+	if !ok {
+		return payload
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || fields == nil {
+		return payload
+	}
+	headers := make(map[string]string)
+	trace.Child().Inject(func(key, value string) {
+		headers[key] = value
+	})
+	raw, err := json.Marshal(headers)
+	if err != nil {
+		return payload
+	}
+	fields[TaskField] = raw
+	injected, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return injected
+}
+
+// ExtractPayload continues the trace a payload was injected with in a new span,
+// ok is false when it carries none.
+func ExtractPayload(payload []byte) (Trace, bool) {
+	var carrier struct {
+		Trace map[string]string `json:"_trace"`
+	}
+	if err := json.Unmarshal(payload, &carrier); err != nil || len(carrier.Trace) == 0 {
+		return Trace{}, false
+	}
+	return Extract(func(key string) string {
+		return carrier.Trace[key]
+	}), true
+}
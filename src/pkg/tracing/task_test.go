@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	parent := New()
+	parent.State = "vendor=1"
+	payload, _ := json.Marshal(map[string]interface{}{"orderId": "order-1", "attempt": 2})
+
+	injected := InjectPayload(WithTrace(context.Background(), parent), payload)
+
+	var task struct {
+		OrderID string `json:"orderId"`
+		Attempt int    `json:"attempt"`
+	}
+	if err := json.Unmarshal(injected, &task); err != nil || task.OrderID != "order-1" || task.Attempt != 2 {
+		t.Fatalf("injected payload = %s, want the task fields kept", injected)
+	}
+	trace, ok := ExtractPayload(injected)
+	if !ok {
+		t.Fatalf("ExtractPayload(%s) found no trace", injected)
+	}
+	if trace.RequestID != parent.RequestID || trace.TraceID != parent.TraceID || trace.State != parent.State {
+		t.Fatalf("extracted %+v, want the request id, trace id and state of %+v", trace, parent)
+	}
+	if trace.SpanID == parent.SpanID {
+		t.Fatalf("extracted trace reuses the parent span %s", parent.SpanID)
+	}
+}
+
+func TestInjectPayloadLeavesPayloadWithoutTrace(t *testing.T) {
+	traced := WithTrace(context.Background(), New())
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		payload []byte
+	}{
+		{name: "no trace in ctx", ctx: context.Background(), payload: []byte(`{"orderId":"order-1"}`)},
+		{name: "nil payload", ctx: traced, payload: nil},
+		{name: "not an object", ctx: traced, payload: []byte(`["order-1"]`)},
+		{name: "null", ctx: traced, payload: []byte(`null`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := InjectPayload(tt.ctx, tt.payload)
+			if string(got) != string(tt.payload) {
+				t.Fatalf("InjectPayload() = %s, want %s", got, tt.payload)
+			}
+			if _, ok := ExtractPayload(got); ok {
+				t.Fatalf("ExtractPayload(%s) found a trace", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package tracing carries the request id and the W3C trace context of a request
+// across HTTP, asynq tasks and Kafka messages.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+type contextKey struct{}
+
+// ContextKey is the key of the trace in a context. It is exported for contexts
+// only offering a setter, such as the fasthttp request context.
+var ContextKey = contextKey{}
+
+// Trace is the request id with the trace context of the current span.
+type Trace struct {
+	RequestID string
+	TraceID   string
+	SpanID    string
+	Flags     string
+	State     string
+}
+
+// New starts a trace with a new request id, sampled.
+func New() Trace {
+	return Trace{
+		RequestID: uuid.NewString(),
+		TraceID:   randomHex(16),
+		SpanID:    randomHex(8),
+		Flags:     "01",
+	}
+}
+
+// Parse reads the trace carried by the request id and traceparent header
+// values returned by get, ok is false when there is no valid traceparent.
+func Parse(get func(key string) string) (Trace, bool) {
+	trace, ok := ParseTraceparent(get(HeaderTraceparent))
+	if !ok {
+		return Trace{}, false
+	}
+	trace.State = get(HeaderTracestate)
+	trace.RequestID = requestID(get)
+	if trace.RequestID == "" {
+		trace.RequestID = uuid.NewString()
+	}
+	return trace, true
+}
+
+// Extract continues the trace read through get in a new span. Without a valid
+// traceparent it starts a new trace, keeping the request id when there is one.
+func Extract(get func(key string) string) Trace {
+	if trace, ok := Parse(get); ok {
+		return trace.Child()
+	}
+	trace := New()
+	if id := requestID(get); id != "" {
+		trace.RequestID = id
+	}
+	return trace
+}
+
+// requestID is the request id header value, ignored when it is too long to be
+// one.
+func requestID(get func(key string) string) string {
+	id := strings.TrimSpace(get(HeaderRequestID))
+	if len(id) > 128 {
+		return ""
+	}
+	return id
+}
+
+// Child returns a new span of the trace.
+func (t Trace) Child() Trace {
+	t.SpanID = randomHex(8)
+	return t
+}
+
+// Inject writes the request id and the trace context through set.
+func (t Trace) Inject(set func(key, value string)) {
+	set(HeaderRequestID, t.RequestID)
+	set(HeaderTraceparent, t.Traceparent())
+	if t.State != "" {
+		set(HeaderTracestate, t.State)
+	}
+}
+
+// Traceparent formats the trace as a version 00 traceparent header.
+func (t Trace) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%s", t.TraceID, t.SpanID, t.Flags)
+}
+
+// ParseTraceparent reads a traceparent header, ok is false when it is malformed
+// or carries the all zero trace or parent id.
+func ParseTraceparent(value string) (Trace, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || parts[0] == "ff" || !isHex(parts[0], 2) {
+		return Trace{}, false
+	}
+	// version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return Trace{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) {
+		return Trace{}, false
+	}
+	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return Trace{}, false
+	}
+	return Trace{TraceID: traceID, SpanID: spanID, Flags: flags}, true
+}
+
+func WithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, ContextKey, trace)
+}
+
+// FromContext returns the trace of the context, ok is false when there is none.
+func FromContext(ctx context.Context) (Trace, bool) {
+	if ctx == nil {
+		return Trace{}, false
+	}
+	trace, ok := ctx.Value(ContextKey).(Trace)
+	return trace, ok
+}
+
+func isHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, r := range value {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -22,6 +22,9 @@ var entityPrefixes = map[string]string{
 	"order":   "ORD",
 	"wallet":  "WLT",
 	"payment": "PAY",
+	"shared":  "SHR",
+	"company": "CMP",
+	"event":   "EVT",
 }
 
 // ConvertString to convert any data type to String
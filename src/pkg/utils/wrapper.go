@@ -59,6 +59,31 @@ func Response(data interface{}, message string, code int, c *fiber.Ctx) error {
 	return c.Status(code).JSON(result)
 }
 
+// ResponseWithMeta is Response with a meta block, e.g. constants.MetaData for paginated lists.
+func ResponseWithMeta(data interface{}, meta interface{}, message string, code int, c *fiber.Ctx) error {
+	auditMeta := Meta{
+		Date:          time.Now(),
+		Url:           c.Path(),
+		Method:        c.Method(),
+		Code:          fmt.Sprintf("%v", code),
+		ContentLength: len(c.Body()),
+		Ip:            c.IP(),
+	}
+	byteMeta, _ := json.Marshal(auditMeta)
+
+	log.GetLogger().Info("service-info", "Logging service...", "audit-log", string(byteMeta))
+
+	result := BaseWrapperModel{
+		Success: code < http.StatusBadRequest,
+		Data:    data,
+		Message: message,
+		Code:    code,
+		Meta:    meta,
+	}
+
+	return c.Status(code).JSON(result)
+}
+
 func ResponseError(err interface{}, c *fiber.Ctx) error {
 	errObj := getErrorStatusCode(err)
 